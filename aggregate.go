@@ -3,10 +3,26 @@ package go_orm
 // Aggregate  聚合函数 AVG,SUM,COUNT,MAX,MIN
 type Aggregate struct {
 	fn    string
-	arg   string
+	arg   Expression
 	alias string
 }
 
+// aggregate col 是字符串时表示字段名，也可以是表达式，例如 Sum(Case()...)
+func aggregate(fn string, col any) Aggregate {
+	return Aggregate{
+		fn:  fn,
+		arg: columnOf(col),
+	}
+}
+
+// columnOf 字符串当作字段名，其余的和 valueOf 相同
+func columnOf(col any) Expression {
+	if name, ok := col.(string); ok {
+		return Column{name: name}
+	}
+	return valueOf(col)
+}
+
 func (a Aggregate) selectable() {
 
 }
@@ -25,33 +41,44 @@ func (a Aggregate) Eq(arg any) Predicate {
 		right: valueOf(arg),
 	}
 }
-func Avg(col string) Aggregate {
-	return Aggregate{
-		fn:  "AVG",
-		arg: col,
+func (a Aggregate) Lt(arg any) Predicate {
+	return Predicate{
+		left:  a,
+		op:    opLt,
+		right: valueOf(arg),
 	}
 }
-func Sum(col string) Aggregate {
-	return Aggregate{
-		fn:  "SUM",
-		arg: col,
+func (a Aggregate) Gt(arg any) Predicate {
+	return Predicate{
+		left:  a,
+		op:    opGt,
+		right: valueOf(arg),
 	}
 }
-func Count(col string) Aggregate {
-	return Aggregate{
-		fn:  "COUNT",
-		arg: col,
-	}
+func Avg(col any) Aggregate {
+	return aggregate("AVG", col)
 }
-func Max(col string) Aggregate {
-	return Aggregate{
-		fn:  "MAX",
-		arg: col,
-	}
+func Sum(col any) Aggregate {
+	return aggregate("SUM", col)
 }
-func Min(col string) Aggregate {
-	return Aggregate{
-		fn:  "MIN",
-		arg: col,
-	}
+func Count(col any) Aggregate {
+	return aggregate("COUNT", col)
+}
+func Max(col any) Aggregate {
+	return aggregate("MAX", col)
+}
+func Min(col any) Aggregate {
+	return aggregate("MIN", col)
+}
+
+// distinct 聚合函数中的 DISTINCT
+type distinct struct {
+	arg Expression
+}
+
+func (distinct) expr() {}
+
+// Distinct 用于聚合函数，例如 Count(Distinct(C("UserId")))，col 是字符串时表示字段名
+func Distinct(col any) Expression {
+	return distinct{arg: columnOf(col)}
 }
@@ -0,0 +1,72 @@
+package go_orm
+
+import (
+	"database/sql"
+	"github.com/Andras5014/go-orm/model"
+	"reflect"
+	"time"
+)
+
+// timeValue 按照字段类型构造自动时间字段的值
+func timeValue(fd *model.Field, now time.Time) any {
+	switch fd.Typ.Kind() {
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		ts := now.Unix()
+		if fd.TimeUnit == model.TimeUnitMilli {
+			ts = now.UnixMilli()
+		}
+		return reflect.ValueOf(ts).Convert(fd.Typ).Interface()
+	}
+	switch fd.Typ {
+	case reflect.TypeOf(&time.Time{}):
+		return &now
+	case reflect.TypeOf(sql.NullTime{}):
+		return sql.NullTime{Time: now, Valid: true}
+	case reflect.TypeOf(&sql.NullTime{}):
+		return &sql.NullTime{Time: now, Valid: true}
+	default:
+		return now
+	}
+}
+
+// fillAutoTime 为实体中零值的自动时间字段填充当前时间
+func fillAutoTime(m *model.Model, entity any, now time.Time) {
+	val := reflect.ValueOf(entity).Elem()
+	for _, fd := range m.Fields {
+		if !fd.AutoCreateTime && !fd.AutoUpdateTime {
+			continue
+		}
+		fdVal := val.FieldByName(fd.GoName)
+		if fdVal.IsZero() {
+			fdVal.Set(reflect.ValueOf(timeValue(fd, now)))
+		}
+	}
+}
+
+// autoUpdateAssigns 补充自动更新时间字段的赋值，已经显式赋值的字段不会覆盖
+func autoUpdateAssigns(m *model.Model, assigns []Assignable, now time.Time) []Assignable {
+	assigned := make(map[string]struct{}, len(assigns))
+	for _, assign := range assigns {
+		switch a := assign.(type) {
+		case Assignment:
+			assigned[a.col] = struct{}{}
+		case Column:
+			assigned[a.name] = struct{}{}
+		}
+	}
+	res := assigns
+	for _, fd := range m.Fields {
+		if !fd.AutoUpdateTime {
+			continue
+		}
+		if _, ok := assigned[fd.GoName]; ok {
+			continue
+		}
+		if len(res) == len(assigns) {
+			// 避免修改调用者的切片
+			res = append(make([]Assignable, 0, len(assigns)+1), assigns...)
+		}
+		res = append(res, Assign(fd.GoName, timeValue(fd, now)))
+	}
+	return res
+}
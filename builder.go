@@ -2,6 +2,8 @@ package go_orm
 
 import (
 	"github.com/Andras5014/go-orm/internal/errs"
+	"github.com/Andras5014/go-orm/model"
+	"github.com/Andras5014/go-orm/serializer"
 	"strings"
 )
 
@@ -10,6 +12,17 @@ type builder struct {
 	sb     strings.Builder
 	args   []any
 	quoter byte
+	// allowFullTableScan 显式允许没有 WHERE 或者 LIMIT 的语句
+	allowFullTableScan bool
+	// tenant 本次查询的租户，由 bindTenant 从 context 中解析
+	tenant any
+	// ctes 语句开头的公共表表达式
+	ctes []cte
+	// argCols 每个参数对应的列名，argCol 是当前正在构造的参数对应的列
+	argCols []string
+	argCol  string
+	// colTable 不为空时列名前加上表名，例如 PostgreSQL upsert 中引用已经存在的行
+	colTable string
 }
 
 func (b *builder) quote(name string) {
@@ -34,6 +47,9 @@ func (b *builder) buildExpression(expr Expression) error {
 	case nil:
 		return nil
 	case Predicate:
+		if fd := b.encryptedField(exp); fd != nil {
+			return b.buildEncryptedPredicate(fd, exp)
+		}
 		// 构建 p.left
 		// 构建 p.op
 		// 构建 p.right
@@ -51,14 +67,25 @@ func (b *builder) buildExpression(expr Expression) error {
 		if exp.op != "" {
 			b.sb.WriteByte(' ')
 			b.sb.WriteString(exp.op.String())
-			b.sb.WriteByte(' ')
+			if exp.right != nil {
+				b.sb.WriteByte(' ')
+			}
 		}
 
 		_, ok = exp.right.(Predicate)
 		if ok {
 			b.sb.WriteByte('(')
 		}
-		if err := b.buildExpression(exp.right); err != nil {
+		// 右边的参数对应左边的列，例如 `age` = ?
+		col := b.argCol
+		if c, isCol := exp.left.(Column); isCol {
+			if fd, has := b.model.FieldMap[c.name]; has {
+				b.argCol = fd.ColName
+			}
+		}
+		err := b.buildExpression(exp.right)
+		b.argCol = col
+		if err != nil {
 			return err
 		}
 		if ok {
@@ -67,34 +94,279 @@ func (b *builder) buildExpression(expr Expression) error {
 
 	case Column:
 		exp.alias = ""
-		return b.buildColumn(Column{name: exp.name})
+		return b.buildColumn(Column{name: exp.name, table: exp.table})
 	case value:
 		b.addArg(exp.arg)
 		b.sb.WriteString("?")
 	case RawExpr:
 		b.sb.WriteString(exp.raw)
 		b.addArg(exp.args...)
-	case Aggregate:
-		b.sb.WriteString(exp.fn)
-		b.sb.WriteString("(`")
-		fd, ok := b.model.FieldMap[exp.arg]
+	case MathExpr:
+		return b.buildMathExpr(exp)
+	case FuncExpr:
+		return b.buildFuncExpr(exp)
+	case ExcludedColumn:
+		fd, ok := b.model.FieldMap[exp.name]
 		if !ok {
-			return errs.NewErrUnknownColumn(exp.arg)
+			return errs.NewErrUnknownField(exp.name)
+		}
+		b.dialect.buildExcluded(b, fd)
+	case Aggregate:
+		return b.buildAggregate(exp)
+	case distinct:
+		b.sb.WriteString("DISTINCT ")
+		return b.buildExpression(exp.arg)
+	case CaseExpr:
+		return b.buildCase(exp)
+	case WindowExpr:
+		return b.buildWindow(exp)
+	case subqueryExpr:
+		b.sb.WriteByte('(')
+		if err := b.buildSubquery(exp.q); err != nil {
+			return err
 		}
-		b.sb.WriteString(fd.ColName)
-		b.sb.WriteString("`)")
+		b.sb.WriteByte(')')
 
 	default:
 		return errs.NewErrUnsupportedExpr(exp)
 	}
 	return nil
 }
+
+// buildMathExpr 构造算术表达式，嵌套的算术表达式加上括号
+func (b *builder) buildMathExpr(m MathExpr) error {
+	if err := b.buildOperand(m.left); err != nil {
+		return err
+	}
+	b.sb.WriteByte(' ')
+	b.sb.WriteString(m.op.String())
+	b.sb.WriteByte(' ')
+	return b.buildOperand(m.right)
+}
+
+func (b *builder) buildOperand(expr Expression) error {
+	var ok bool
+	switch e := expr.(type) {
+	case MathExpr:
+		ok = true
+	case FuncExpr:
+		ok = e.concat
+	}
+	if ok {
+		b.sb.WriteByte('(')
+	}
+	if err := b.buildExpression(expr); err != nil {
+		return err
+	}
+	if ok {
+		b.sb.WriteByte(')')
+	}
+	return nil
+}
+
+func (b *builder) buildFuncExpr(f FuncExpr) error {
+	if f.keyword {
+		b.sb.WriteString(f.name)
+		return nil
+	}
+	if f.concat {
+		return b.dialect.buildConcat(b, f.args)
+	}
+	b.sb.WriteString(f.name)
+	b.sb.WriteByte('(')
+	if err := b.buildExpressions(f.args); err != nil {
+		return err
+	}
+	b.sb.WriteByte(')')
+	return nil
+}
+
+// buildExpressions 构造逗号分隔的表达式列表
+func (b *builder) buildExpressions(exprs []Expression) error {
+	for i, expr := range exprs {
+		if i > 0 {
+			b.sb.WriteByte(',')
+		}
+		if err := b.buildExpression(expr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *builder) buildAggregate(a Aggregate) error {
+	b.sb.WriteString(a.fn)
+	b.sb.WriteByte('(')
+	if err := b.buildExpression(a.arg); err != nil {
+		return err
+	}
+	b.sb.WriteByte(')')
+	return nil
+}
+
+func (b *builder) buildCase(c CaseExpr) error {
+	if len(c.whens) == 0 {
+		return errs.ErrEmptyCase
+	}
+	b.sb.WriteString("CASE")
+	for _, w := range c.whens {
+		b.sb.WriteString(" WHEN ")
+		if err := b.buildExpression(w.cond); err != nil {
+			return err
+		}
+		b.sb.WriteString(" THEN ")
+		if err := b.buildExpression(w.then); err != nil {
+			return err
+		}
+	}
+	if c.els != nil {
+		b.sb.WriteString(" ELSE ")
+		if err := b.buildExpression(c.els); err != nil {
+			return err
+		}
+	}
+	b.sb.WriteString(" END")
+	return nil
+}
+
+func (b *builder) buildWindow(w WindowExpr) error {
+	if err := b.buildExpression(w.fn); err != nil {
+		return err
+	}
+	b.sb.WriteString(" OVER (")
+	space := false
+	if len(w.window.partitionBys) > 0 {
+		b.sb.WriteString("PARTITION BY ")
+		if err := b.buildExpressions(w.window.partitionBys); err != nil {
+			return err
+		}
+		space = true
+	}
+	if len(w.window.orderBys) > 0 {
+		if space {
+			b.sb.WriteByte(' ')
+		}
+		b.sb.WriteString("ORDER BY ")
+		for i, ob := range w.window.orderBys {
+			if i > 0 {
+				b.sb.WriteByte(',')
+			}
+			if err := b.buildColumn(ob.col); err != nil {
+				return err
+			}
+			b.sb.WriteByte(' ')
+			b.sb.WriteString(ob.order)
+		}
+		space = true
+	}
+	if w.window.frame != "" {
+		if space {
+			b.sb.WriteByte(' ')
+		}
+		b.sb.WriteString(w.window.frame)
+	}
+	b.sb.WriteByte(')')
+	return nil
+}
+
+// buildAs 写入别名，别名是字段名时使用对应的列名，查询结果可以映射回结构体
+func (b *builder) buildAs(alias string) {
+	if alias == "" {
+		return
+	}
+	if fd, ok := b.model.FieldMap[alias]; ok {
+		alias = fd.ColName
+	}
+	b.sb.WriteString(" AS ")
+	b.quote(alias)
+}
+
+// buildAssignment 构造 col = val，Updater 和 upsert 使用同样的格式
+func (b *builder) buildAssignment(a Assignment) error {
+	fd, ok := b.model.FieldMap[a.col]
+	if !ok {
+		return errs.NewErrUnknownField(a.col)
+	}
+	b.quote(fd.ColName)
+	b.sb.WriteString(" = ")
+	return b.buildAssignValue(fd, a.val)
+}
+
+// buildAssignValue 构造赋值的值，val 是表达式时直接构造表达式
+// 表达式中的参数也对应赋值的列，例如 `age` = `age` + ?
+func (b *builder) buildAssignValue(fd *model.Field, val any) error {
+	prev := b.argCol
+	b.argCol = fd.ColName
+	defer func() {
+		b.argCol = prev
+	}()
+	if expr, ok := val.(Expression); ok {
+		return b.buildExpression(expr)
+	}
+	b.sb.WriteByte('?')
+	return b.addAssignArg(fd, val)
+}
+
+// scope 为谓词追加模型级别的自动过滤条件，例如软删除和租户
+// unscoped 只去掉软删除条件，租户条件始终生效
+// table 是 From 指定的表，JOIN 之类的写法中列名可能有歧义，自动追加的条件加上模型的表名
+func (b *builder) scope(ps []Predicate, unscoped bool, table string) []Predicate {
+	softDelete := !unscoped && b.model.SoftDeleteField != nil
+	tenant := b.model.TenantField != nil
+	if !softDelete && !tenant {
+		return ps
+	}
+	var qualifier string
+	if table != "" && tableName(table) == "" {
+		qualifier = b.model.TableName
+	}
+	res := make([]Predicate, 0, len(ps)+2)
+	res = append(res, ps...)
+	if tenant {
+		res = append(res, Column{name: b.model.TenantField.GoName, table: qualifier}.Eq(b.tenant))
+	}
+	if softDelete {
+		res = append(res, notDeleted(b.model.SoftDeleteField, qualifier))
+	}
+	return res
+}
+
 func (b *builder) buildColumn(c Column) error {
 	fd, ok := b.model.FieldMap[c.name]
 	if !ok {
 		return errs.NewErrUnknownColumn(c.name)
 	}
-	b.quote(fd.ColName)
+	if c.table != "" {
+		b.quote(c.table)
+		b.sb.WriteByte('.')
+		b.quote(fd.ColName)
+		return nil
+	}
+	b.quoteColumn(fd.ColName)
+	return nil
+}
+
+// quoteColumn 写入列名，colTable 不为空时加上表名
+func (b *builder) quoteColumn(col string) {
+	if b.colTable != "" {
+		b.quote(b.colTable)
+		b.sb.WriteByte('.')
+	}
+	b.quote(col)
+}
+
+// addAssignArg 添加赋值的参数，字段配置了 Serializer 时先编码
+func (b *builder) addAssignArg(fd *model.Field, val any) error {
+	if fd.Serializer != nil {
+		if _, ok := val.(Expression); !ok {
+			encoded, err := serializer.Encode(fd.Serializer, val)
+			if err != nil {
+				return err
+			}
+			val = encoded
+		}
+	}
+	b.addArg(val)
 	return nil
 }
 
@@ -104,6 +376,39 @@ func (b *builder) addArg(args ...any) {
 	}
 	if b.args == nil {
 		b.args = make([]any, 0, 8)
+		b.argCols = make([]string, 0, 8)
 	}
 	b.args = append(b.args, args...)
+	for range args {
+		b.argCols = append(b.argCols, b.argCol)
+	}
+}
+
+// addColumnArg 添加列 col 对应的参数
+func (b *builder) addColumnArg(col string, arg any) {
+	prev := b.argCol
+	b.argCol = col
+	b.addArg(arg)
+	b.argCol = prev
+}
+
+// addQueryArgs 添加子查询的参数，保留子查询中参数对应的列
+func (b *builder) addQueryArgs(q *Query) {
+	if len(q.Args) == 0 {
+		return
+	}
+	start := len(b.args)
+	b.addArg(q.Args...)
+	if len(q.ArgColumns) == len(q.Args) {
+		copy(b.argCols[start:], q.ArgColumns)
+	}
+}
+
+// query 返回构造好的查询
+func (b *builder) query() *Query {
+	return &Query{
+		SQL:        b.sb.String(),
+		Args:       b.args,
+		ArgColumns: b.argCols,
+	}
 }
@@ -0,0 +1,364 @@
+package go_orm
+
+import (
+	"bufio"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/Andras5014/go-orm/internal/errs"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// bulkBatchSize 不支持批量导入的数据库退化为分批 INSERT 时每批最多的行数
+	bulkBatchSize = 1000
+	// maxBulkParams 一条语句最多的参数个数，PostgreSQL 的上限是 65535
+	maxBulkParams = 65535
+)
+
+var bulkReaderID atomic.Int64
+
+// DBWithReaderHandler 注册 LOAD DATA LOCAL INFILE 'Reader::name' 读取的 io.Reader，
+// 设置之后 MySQL 的 BulkLoad 使用 LOAD DATA 导入，一般传入 go-sql-driver 的
+// mysql.RegisterReaderHandler 和 mysql.DeregisterReaderHandler
+func DBWithReaderHandler(register func(name string, handler func() io.Reader), deregister func(name string)) DBOption {
+	return func(db *DB) {
+		db.readerHandler = &readerHandler{
+			register:   register,
+			deregister: deregister,
+		}
+	}
+}
+
+type readerHandler struct {
+	register   func(name string, handler func() io.Reader)
+	deregister func(name string)
+}
+
+// BulkLoad 批量导入数据，seq 依次产出要导入的实体，返回导入的行数
+//   - MySQL 设置了 DBWithReaderHandler 时使用 LOAD DATA LOCAL INFILE，需要 DSN 中开启 allowAllFiles 或者 LOCAL INFILE 支持
+//   - PostgreSQL 使用 COPY FROM STDIN，依赖 lib/pq 支持的 Prepare("COPY ... FROM STDIN")；
+//     pgx 不支持这种用法，使用 pgx 驱动时和其余数据库一样分批 INSERT
+//   - 其余数据库在一个事务中分批 INSERT
+//
+// 和 Inserter 一样会填充自动时间、租户和盲索引字段，但是不会调用钩子
+// LOAD DATA 和 COPY 同样经过中间件，中间件收到的 QueryContext 的 Type 为 INSERT，Builder 为 *RawQuerier[T]，
+// 没有参数，也不实现 Snapshotter，因此 audit 中间件不会记录导入的数据；cache 中间件按照模型的表失效缓存
+func BulkLoad[T any](ctx context.Context, db *DB, seq func(yield func(*T) bool)) (int64, error) {
+	ins := NewInserter[T](db)
+	var err error
+	ins.model, err = ins.r.Get(new(T))
+	if err != nil {
+		return 0, err
+	}
+	if err = ins.bindTenant(ctx); err != nil {
+		return 0, err
+	}
+	switch {
+	case db.dialect == DialectMySQL && db.readerHandler != nil:
+		return loadData(ctx, db, ins, seq)
+	case db.dialect == DialectPostgreSQL && !isPgx(db.db.Driver()):
+		return copyFrom(ctx, db, ins, seq)
+	}
+	return batchInsert(ctx, db, ins, seq)
+}
+
+// isPgx pgx 的 database/sql 驱动不支持通过 Prepare 执行 COPY FROM STDIN
+func isPgx(drv driver.Driver) bool {
+	typ := reflect.TypeOf(drv)
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	return strings.HasPrefix(typ.PkgPath(), "github.com/jackc/pgx")
+}
+
+// bulkExec 让 LOAD DATA 和 COPY 经过中间件，load 执行中间件处理之后的 SQL
+func bulkExec[T any](ctx context.Context, db *DB, ins *Inserter[T], query string,
+	load func(ctx context.Context, query string) (int64, error)) (int64, error) {
+	qc := &QueryContext{
+		Type:    "INSERT",
+		Builder: RawQuery[T](db, query),
+		Model:   ins.model,
+		Session: db,
+		Dialect: db.dialect,
+		Tenant:  db.contextTenant(ctx),
+	}
+	var root Handler = func(ctx context.Context, qc *QueryContext) *QueryResult {
+		q, err := qc.Query()
+		if err != nil {
+			return &QueryResult{
+				Err: err,
+				Result: Result{
+					err: err,
+				},
+			}
+		}
+		n, err := load(ctx, q.SQL)
+		return &QueryResult{
+			Err: err,
+			Result: Result{
+				err: err,
+				res: driver.RowsAffected(n),
+			},
+		}
+	}
+	for i := len(db.middlewares) - 1; i >= 0; i-- {
+		root = db.middlewares[i](root)
+	}
+	res := root(ctx, qc)
+	if res.Err != nil {
+		return 0, res.Err
+	}
+	if r, ok := res.Result.(Result); ok {
+		return r.RowsAffected()
+	}
+	return 0, nil
+}
+
+// row 填充自动字段之后返回所有列的值
+func (i *Inserter[T]) row(v *T, now time.Time) ([]any, error) {
+	if err := i.fill(v, now); err != nil {
+		return nil, err
+	}
+	val := i.creator(i.model, v)
+	res := make([]any, 0, len(i.model.Fields))
+	for _, fd := range i.model.Fields {
+		arg, err := val.Field(fd.GoName)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, arg)
+	}
+	return res, nil
+}
+
+// bulkTarget 写入表名、options 和所有列
+func (i *Inserter[T]) bulkTarget(options string) string {
+	i.quoteTable(i.model.TableName)
+	i.sb.WriteString(options)
+	i.sb.WriteString(" (")
+	for idx, fd := range i.model.Fields {
+		if idx > 0 {
+			i.sb.WriteByte(',')
+		}
+		i.quote(fd.ColName)
+	}
+	i.sb.WriteByte(')')
+	return i.sb.String()
+}
+
+func loadData[T any](ctx context.Context, db *DB, ins *Inserter[T], seq func(yield func(*T) bool)) (int64, error) {
+	name := fmt.Sprintf("go_orm_bulk_%d", bulkReaderID.Add(1))
+	ins.sb.WriteString("LOAD DATA LOCAL INFILE 'Reader::" + name + "' INTO TABLE ")
+	query := ins.bulkTarget(` FIELDS TERMINATED BY '\t' ESCAPED BY '\\' LINES TERMINATED BY '\n'`)
+	return bulkExec(ctx, db, ins, query, func(ctx context.Context, query string) (int64, error) {
+		return loadReader(ctx, db, ins, name, query, seq)
+	})
+}
+
+// loadReader 注册 name 对应的 io.Reader 之后执行 LOAD DATA
+func loadReader[T any](ctx context.Context, db *DB, ins *Inserter[T], name string, query string,
+	seq func(yield func(*T) bool)) (int64, error) {
+	pr, pw := io.Pipe()
+	db.readerHandler.register(name, func() io.Reader {
+		return pr
+	})
+	defer db.readerHandler.deregister(name)
+
+	writeErr := make(chan error, 1)
+	go func() {
+		err := writeRows(pw, ins, seq)
+		_ = pw.CloseWithError(err)
+		writeErr <- err
+	}()
+	res, err := db.db.ExecContext(ctx, query)
+	// 驱动没有读完数据时，关闭之后写入的 goroutine 会退出
+	_ = pr.Close()
+	wErr := <-writeErr
+	if err != nil {
+		return 0, err
+	}
+	if wErr != nil && !errors.Is(wErr, io.ErrClosedPipe) {
+		return 0, wErr
+	}
+	return res.RowsAffected()
+}
+
+// writeRows 按照 LOAD DATA 默认的转义规则写入数据，NULL 写为 \N
+func writeRows[T any](w io.Writer, ins *Inserter[T], seq func(yield func(*T) bool)) error {
+	bw := bufio.NewWriter(w)
+	now := ins.clock()
+	var err error
+	seq(func(v *T) bool {
+		var row []any
+		row, err = ins.row(v, now)
+		if err != nil {
+			return false
+		}
+		for idx, arg := range row {
+			if idx > 0 {
+				_ = bw.WriteByte('\t')
+			}
+			var field string
+			var null bool
+			field, null, err = formatField(arg)
+			if err != nil {
+				return false
+			}
+			if null {
+				_, _ = bw.WriteString(`\N`)
+				continue
+			}
+			_, _ = bw.WriteString(escaper.Replace(field))
+		}
+		_, err = bw.WriteString("\n")
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`, "\x00", `\0`)
+
+func formatField(arg any) (string, bool, error) {
+	// 和 database/sql 一样，值为 nil 的指针视为 NULL
+	if rv := reflect.ValueOf(arg); rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return "", true, nil
+	}
+	if valuer, ok := arg.(driver.Valuer); ok {
+		val, err := valuer.Value()
+		if err != nil {
+			return "", false, err
+		}
+		arg = val
+	}
+	switch v := arg.(type) {
+	case nil:
+		return "", true, nil
+	case []byte:
+		if v == nil {
+			return "", true, nil
+		}
+		return string(v), false, nil
+	case string:
+		return v, false, nil
+	case bool:
+		if v {
+			return "1", false, nil
+		}
+		return "0", false, nil
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05.999999"), false, nil
+	case *time.Time:
+		return v.Format("2006-01-02 15:04:05.999999"), false, nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), false, nil
+	}
+	return fmt.Sprint(arg), false, nil
+}
+
+func copyFrom[T any](ctx context.Context, db *DB, ins *Inserter[T], seq func(yield func(*T) bool)) (int64, error) {
+	ins.sb.WriteString("COPY ")
+	query := ins.bulkTarget("") + " FROM STDIN"
+	return bulkExec(ctx, db, ins, query, func(ctx context.Context, query string) (int64, error) {
+		return copyIn(ctx, db, ins, query, seq)
+	})
+}
+
+// copyIn 在一个事务中执行 COPY，逐行发送数据
+func copyIn[T any](ctx context.Context, db *DB, ins *Inserter[T], query string,
+	seq func(yield func(*T) bool)) (cnt int64, err error) {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err == nil {
+			err = tx.Commit()
+			return
+		}
+		if rbErr := tx.Rollback(); rbErr != nil {
+			err = errs.NewErrFailedToRollback(err, rbErr, false)
+		}
+		cnt = 0
+	}()
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	now := ins.clock()
+	seq(func(v *T) bool {
+		var row []any
+		row, err = ins.row(v, now)
+		if err == nil {
+			_, err = stmt.ExecContext(ctx, row...)
+		}
+		if err != nil {
+			return false
+		}
+		cnt++
+		return true
+	})
+	if err != nil {
+		_ = stmt.Close()
+		return 0, err
+	}
+	// 不带参数执行一次表示数据结束
+	if _, err = stmt.ExecContext(ctx); err != nil {
+		_ = stmt.Close()
+		return 0, err
+	}
+	return cnt, stmt.Close()
+}
+
+// bulkBatchRows 每批的行数，列很多时减少行数，避免超过参数个数的上限
+func bulkBatchRows(fields int) int {
+	if fields == 0 {
+		return bulkBatchSize
+	}
+	return max(1, min(bulkBatchSize, maxBulkParams/fields))
+}
+
+func batchInsert[T any](ctx context.Context, db *DB, ins *Inserter[T], seq func(yield func(*T) bool)) (int64, error) {
+	var cnt int64
+	err := db.DoTx(ctx, func(ctx context.Context, tx *Tx) error {
+		size := bulkBatchRows(len(ins.model.Fields))
+		batch := make([]*T, 0, size)
+		flush := func() error {
+			if len(batch) == 0 {
+				return nil
+			}
+			i := NewInserter[T](tx).Values(batch...)
+			i.model = ins.model
+			i.tenant = ins.tenant
+			n, err := i.execOnce(ctx, tx).RowsAffected()
+			cnt += n
+			batch = batch[:0]
+			return err
+		}
+		var err error
+		seq(func(v *T) bool {
+			batch = append(batch, v)
+			if len(batch) == size {
+				err = flush()
+			}
+			return err == nil
+		})
+		if err != nil {
+			return err
+		}
+		return flush()
+	}, nil)
+	if err != nil {
+		return 0, err
+	}
+	return cnt, nil
+}
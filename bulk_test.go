@@ -0,0 +1,162 @@
+package go_orm
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"regexp"
+	"testing"
+)
+
+func models(n int) func(yield func(*TestModel) bool) {
+	return func(yield func(*TestModel) bool) {
+		for i := 1; i <= n; i++ {
+			if !yield(&TestModel{Id: int64(i), FirstName: "Tom", Age: 18}) {
+				return
+			}
+		}
+	}
+}
+
+func TestBulkLoad_SQLite(t *testing.T) {
+	db, err := Open("sqlite3", "file:"+t.Name()+"?mode=memory&cache=shared", DBWithDialect(DialectSQLite))
+	require.NoError(t, err)
+	ctx := context.Background()
+	err = RawQuery[TestModel](db, "CREATE TABLE test_model (id INTEGER PRIMARY KEY, first_name TEXT, age INTEGER, last_name TEXT)").Exec(ctx).Err()
+	require.NoError(t, err)
+
+	cnt, err := BulkLoad[TestModel](ctx, db, models(2500))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2500), cnt)
+	res, err := NewSelector[TestModel](db).GetMulti(ctx)
+	require.NoError(t, err)
+	assert.Len(t, res, 2500)
+
+	// 主键冲突时整体回滚
+	_, err = BulkLoad[TestModel](ctx, db, func(yield func(*TestModel) bool) {
+		_ = yield(&TestModel{Id: 3000}) && yield(&TestModel{Id: 1})
+	})
+	assert.Error(t, err)
+	_, err = NewSelector[TestModel](db).Where(C("Id").Eq(3000)).Get(ctx)
+	assert.Equal(t, ErrNoRows, err)
+}
+
+func TestBulkLoad_MySQL(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	var registered, deregistered []string
+	db, err := OpenDB(mockDB, DBWithReaderHandler(func(name string, handler func() io.Reader) {
+		registered = append(registered, name)
+	}, func(name string) {
+		deregistered = append(deregistered, name)
+	}))
+	require.NoError(t, err)
+	mock.ExpectExec(regexp.QuoteMeta("LOAD DATA LOCAL INFILE 'Reader::go_orm_bulk_") + `\d+` +
+		regexp.QuoteMeta("' INTO TABLE `test_model` FIELDS TERMINATED BY '\\t' ESCAPED BY '\\\\' LINES TERMINATED BY '\\n' (`id`,`first_name`,`age`,`last_name`)")).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	cnt, err := BulkLoad[TestModel](context.Background(), db, models(3))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), cnt)
+	require.Len(t, registered, 1)
+	assert.Equal(t, registered, deregistered)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBulkLoad_MySQLWithoutReaderHandler(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockDB)
+	require.NoError(t, err)
+	// 没有注册 io.Reader 时分批 INSERT
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `test_model`")).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+	cnt, err := BulkLoad[TestModel](context.Background(), db, models(3))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), cnt)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBulkLoad_Middleware(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	var qcs []*QueryContext
+	db, err := OpenDB(mockDB, DBWithDialect(DialectPostgreSQL), DBWithMiddlewares(
+		func(next Handler) Handler {
+			return func(ctx context.Context, qc *QueryContext) *QueryResult {
+				qcs = append(qcs, qc)
+				q, err := qc.Query()
+				require.NoError(t, err)
+				q.SQL += " /*app='bulk'*/"
+				return next(ctx, qc)
+			}
+		}))
+	require.NoError(t, err)
+	mock.ExpectBegin()
+	prep := mock.ExpectPrepare(`COPY "test_model" ("id","first_name","age","last_name") FROM STDIN /*app='bulk'*/`)
+	prep.ExpectExec().WithArgs(int64(1), "Tom", int8(18), nil).WillReturnResult(sqlmock.NewResult(0, 0))
+	prep.ExpectExec().WithoutArgs().WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	cnt, err := BulkLoad[TestModel](context.Background(), db, models(1))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), cnt)
+	require.Len(t, qcs, 1)
+	assert.Equal(t, "INSERT", qcs[0].Type)
+	assert.Equal(t, "test_model", qcs[0].Model.TableName)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// 中间件拒绝时不会执行 COPY
+	rejected := errors.New("rejected")
+	db.middlewares = []Middleware{func(next Handler) Handler {
+		return func(ctx context.Context, qc *QueryContext) *QueryResult {
+			return &QueryResult{Err: rejected}
+		}
+	}}
+	_, err = BulkLoad[TestModel](context.Background(), db, models(1))
+	assert.Equal(t, rejected, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBulkLoad_writeRows(t *testing.T) {
+	db := memoryDB(t)
+	ins := NewInserter[TestModel](db)
+	var err error
+	ins.model, err = ins.r.Get(&TestModel{})
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	err = writeRows(&buf, ins, func(yield func(*TestModel) bool) {
+		_ = yield(&TestModel{Id: 1, FirstName: "a\tb\\c\nd", Age: 18}) &&
+			yield(&TestModel{Id: 2, LastName: &sql.NullString{String: "x", Valid: true}})
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "1\ta\\tb\\\\c\\nd\t18\t\\N\n2\t\t0\tx\n", buf.String())
+}
+
+func TestBulkLoad_PostgreSQL(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	db, err := OpenDB(mockDB, DBWithDialect(DialectPostgreSQL))
+	require.NoError(t, err)
+	mock.ExpectBegin()
+	prep := mock.ExpectPrepare(`COPY "test_model" ("id","first_name","age","last_name") FROM STDIN`)
+	prep.ExpectExec().WithArgs(int64(1), "Tom", int8(18), nil).WillReturnResult(sqlmock.NewResult(0, 0))
+	prep.ExpectExec().WithArgs(int64(2), "Tom", int8(18), nil).WillReturnResult(sqlmock.NewResult(0, 0))
+	prep.ExpectExec().WithoutArgs().WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	cnt, err := BulkLoad[TestModel](context.Background(), db, models(2))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), cnt)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBulkLoad_batchRows(t *testing.T) {
+	assert.Equal(t, 1000, bulkBatchRows(4))
+	assert.Equal(t, 1000, bulkBatchRows(65))
+	assert.Equal(t, 992, bulkBatchRows(66))
+	assert.Equal(t, 1, bulkBatchRows(70000))
+}
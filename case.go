@@ -0,0 +1,48 @@
+package go_orm
+
+// CaseExpr CASE WHEN 表达式，例如
+// Case().When(C("Age").Gt(18), "adult").Else("child")
+type CaseExpr struct {
+	whens []when
+	els   Expression
+	alias string
+}
+
+type when struct {
+	cond Predicate
+	then Expression
+}
+
+func Case() CaseExpr {
+	return CaseExpr{}
+}
+
+// When 追加一个分支，val 可以是值或者表达式
+func (c CaseExpr) When(p Predicate, val any) CaseExpr {
+	// 复制一份，避免多个 CaseExpr 共享底层数组
+	whens := make([]when, 0, len(c.whens)+1)
+	whens = append(whens, c.whens...)
+	c.whens = append(whens, when{cond: p, then: valueOf(val)})
+	return c
+}
+
+func (c CaseExpr) Else(val any) CaseExpr {
+	c.els = valueOf(val)
+	return c
+}
+
+func (c CaseExpr) As(alias string) CaseExpr {
+	c.alias = alias
+	return c
+}
+
+func (CaseExpr) expr()       {}
+func (CaseExpr) selectable() {}
+
+func (c CaseExpr) Eq(arg any) Predicate {
+	return Predicate{
+		left:  c,
+		op:    opEq,
+		right: valueOf(arg),
+	}
+}
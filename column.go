@@ -3,6 +3,8 @@ package go_orm
 type Column struct {
 	name  string
 	alias string
+	// table 不为空时列名前加上表名，例如 From 是 JOIN 时自动追加的软删除和租户条件
+	table string
 }
 
 func C(name string) Column {
@@ -16,6 +18,40 @@ func (c Column) Eq(arg any) Predicate {
 		right: valueOf(arg),
 	}
 }
+func (c Column) Add(val any) MathExpr {
+	return mathExpr(c, opAdd, val)
+}
+
+func (c Column) Sub(val any) MathExpr {
+	return mathExpr(c, opSub, val)
+}
+
+func (c Column) Mul(val any) MathExpr {
+	return mathExpr(c, opMul, val)
+}
+
+func (c Column) Div(val any) MathExpr {
+	return mathExpr(c, opDiv, val)
+}
+
+func (c Column) Mod(val any) MathExpr {
+	return mathExpr(c, opMod, val)
+}
+
+// Concat 拼接字符串，等价于 Concat(c, vals...)
+func (c Column) Concat(vals ...any) FuncExpr {
+	return Concat(append([]any{c}, vals...)...)
+}
+
+// InQuery 构造 col IN (子查询)，子查询可以引用 With 定义的公共表表达式
+func (c Column) InQuery(q QueryBuilder) Predicate {
+	return Predicate{
+		left:  c,
+		op:    opIn,
+		right: subqueryExpr{q: q},
+	}
+}
+
 func (c Column) assign() {
 
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"github.com/Andras5014/go-orm/internal/valuer"
 	"github.com/Andras5014/go-orm/model"
+	"time"
 )
 
 type core struct {
@@ -12,9 +13,27 @@ type core struct {
 	creator     valuer.Creator
 	r           model.Registry
 	middlewares []Middleware
+	// clock 获取当前时间，用于自动时间字段和软删除
+	clock func() time.Time
+	// tenantResolver 从 context 中解析租户
+	tenantResolver TenantResolver
+	// tenantSchema 不为 nil 时每个租户一个 schema
+	tenantSchema func(tenant any) string
+}
+
+// contextTenant 返回 context 中的租户，没有租户时返回 nil
+func (c core) contextTenant(ctx context.Context) any {
+	if c.tenantResolver == nil {
+		return nil
+	}
+	tenant, _ := c.tenantResolver(ctx)
+	return tenant
 }
 
 func get[T any](ctx context.Context, sess Session, c core, qc *QueryContext) *QueryResult {
+	qc.Session = sess
+	qc.Dialect = c.dialect
+	qc.Tenant = c.contextTenant(ctx)
 	var root Handler = func(ctx context.Context, qc *QueryContext) *QueryResult {
 		return getHandler[T](ctx, sess, c, qc)
 	}
@@ -25,7 +44,7 @@ func get[T any](ctx context.Context, sess Session, c core, qc *QueryContext) *Qu
 
 }
 func getHandler[T any](ctx context.Context, sess Session, c core, qc *QueryContext) *QueryResult {
-	q, err := qc.Builder.Build()
+	q, err := qc.Query()
 	// 构造sql失败
 	if err != nil {
 		return &QueryResult{
@@ -40,6 +59,9 @@ func getHandler[T any](ctx context.Context, sess Session, c core, qc *QueryConte
 			Err: err,
 		}
 	}
+	defer func() {
+		_ = rows.Close()
+	}()
 	if !rows.Next() {
 		return &QueryResult{
 			Err: ErrNoRows,
@@ -54,7 +76,54 @@ func getHandler[T any](ctx context.Context, sess Session, c core, qc *QueryConte
 		Result: tp,
 	}
 }
+func getMulti[T any](ctx context.Context, sess Session, c core, qc *QueryContext) *QueryResult {
+	qc.Session = sess
+	qc.Dialect = c.dialect
+	qc.Tenant = c.contextTenant(ctx)
+	var root Handler = func(ctx context.Context, qc *QueryContext) *QueryResult {
+		return getMultiHandler[T](ctx, sess, c, qc)
+	}
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		root = c.middlewares[i](root)
+	}
+	return root(ctx, qc)
+}
+func getMultiHandler[T any](ctx context.Context, sess Session, c core, qc *QueryContext) *QueryResult {
+	q, err := qc.Query()
+	if err != nil {
+		return &QueryResult{
+			Err: err,
+		}
+	}
+	rows, err := sess.queryContext(ctx, q.SQL, q.Args...)
+	if err != nil {
+		return &QueryResult{
+			Err: err,
+		}
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var res []*T
+	for rows.Next() {
+		tp := new(T)
+		val := c.creator(c.model, tp)
+		if err = val.SetColumns(rows); err != nil {
+			return &QueryResult{
+				Err: err,
+			}
+		}
+		res = append(res, tp)
+	}
+	return &QueryResult{
+		Err:    rows.Err(),
+		Result: res,
+	}
+}
 func exec(ctx context.Context, sess Session, c core, qc *QueryContext) *QueryResult {
+	qc.Session = sess
+	qc.Dialect = c.dialect
+	qc.Tenant = c.contextTenant(ctx)
 	var root Handler = func(ctx context.Context, qc *QueryContext) *QueryResult {
 		return execHandler(ctx, sess, c, qc)
 	}
@@ -65,7 +134,7 @@ func exec(ctx context.Context, sess Session, c core, qc *QueryContext) *QueryRes
 
 }
 func execHandler(ctx context.Context, sess Session, c core, qc *QueryContext) *QueryResult {
-	q, err := qc.Builder.Build()
+	q, err := qc.Query()
 	if err != nil {
 		return &QueryResult{
 			Err: err,
@@ -0,0 +1,111 @@
+package go_orm
+
+import (
+	"github.com/Andras5014/go-orm/internal/errs"
+	"github.com/Andras5014/go-orm/model"
+	"strings"
+)
+
+// cte 公共表表达式 WITH name AS (query)
+type cte struct {
+	name  string
+	query QueryBuilder
+	// recursive 不为 nil 时是递归 CTE，query 是初始部分
+	recursive QueryBuilder
+}
+
+// subquery 可以作为子查询的构造器，例如 Selector
+type subquery interface {
+	// buildSubquery 作为子查询构造，没有指定租户时使用外层查询的租户
+	buildSubquery(tenant any) (*Query, error)
+	subqueryModel() (*model.Model, error)
+}
+
+// subqueryExpr 子查询表达式，例如 C("Id").InQuery(...)
+type subqueryExpr struct {
+	q QueryBuilder
+}
+
+func (subqueryExpr) expr() {}
+
+// buildWith 在语句开头构造 WITH，参数在外层查询的参数之前
+func (b *builder) buildWith() error {
+	if len(b.ctes) == 0 {
+		return nil
+	}
+	b.sb.WriteString("WITH ")
+	for _, c := range b.ctes {
+		if c.recursive != nil {
+			b.sb.WriteString("RECURSIVE ")
+			break
+		}
+	}
+	names := make(map[string]struct{}, len(b.ctes))
+	for i, c := range b.ctes {
+		if c.name == "" {
+			return errs.ErrEmptyCTEName
+		}
+		if _, ok := names[c.name]; ok {
+			return errs.NewErrDuplicateCTE(c.name)
+		}
+		names[c.name] = struct{}{}
+		if i > 0 {
+			b.sb.WriteString(", ")
+		}
+		b.quote(c.name)
+		b.sb.WriteString(" AS (")
+		if err := b.buildSubquery(c.query); err != nil {
+			return err
+		}
+		if c.recursive != nil {
+			if err := checkRecursiveModel(c); err != nil {
+				return err
+			}
+			b.sb.WriteString(" UNION ALL ")
+			if err := b.buildSubquery(c.recursive); err != nil {
+				return err
+			}
+		}
+		b.sb.WriteByte(')')
+	}
+	b.sb.WriteByte(' ')
+	return nil
+}
+
+// checkRecursiveModel 递归 CTE 的初始部分和递归部分必须查询同一个模型
+func checkRecursiveModel(c cte) error {
+	anchor, ok1 := c.query.(subquery)
+	recursive, ok2 := c.recursive.(subquery)
+	if !ok1 || !ok2 {
+		return nil
+	}
+	am, err := anchor.subqueryModel()
+	if err != nil {
+		return err
+	}
+	rm, err := recursive.subqueryModel()
+	if err != nil {
+		return err
+	}
+	if am != rm {
+		return errs.NewErrCTEModelMismatch(c.name, am.TableName, rm.TableName)
+	}
+	return nil
+}
+
+// buildSubquery 构造子查询，去掉末尾的分号
+func (b *builder) buildSubquery(qb QueryBuilder) error {
+	var q *Query
+	var err error
+	if sq, ok := qb.(subquery); ok {
+		q, err = sq.buildSubquery(b.tenant)
+	} else {
+		q, err = qb.Build()
+	}
+	if err != nil {
+		return err
+	}
+	b.sb.WriteString(strings.TrimSuffix(q.SQL, ";"))
+	b.addQueryArgs(q)
+	return nil
+}
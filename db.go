@@ -9,6 +9,7 @@ import (
 	"github.com/Andras5014/go-orm/internal/valuer"
 	"github.com/Andras5014/go-orm/model"
 	"log"
+	"time"
 )
 
 type DBOption func(db *DB)
@@ -17,6 +18,10 @@ type DBOption func(db *DB)
 type DB struct {
 	core
 	db *sql.DB
+	// stmtCache 不为 nil 时使用预编译语句执行查询
+	stmtCache *stmtCache
+	// readerHandler 不为 nil 时 MySQL 的 BulkLoad 使用 LOAD DATA
+	readerHandler *readerHandler
 }
 
 func Open(driver string, dataSourceName string, opts ...DBOption) (*DB, error) {
@@ -33,6 +38,9 @@ func OpenDB(db *sql.DB, opts ...DBOption) (*DB, error) {
 			r:       model.NewRegistry(),
 			dialect: DialectMySQL,
 			creator: valuer.NewUnsafeValue,
+			clock:   time.Now,
+
+			tenantResolver: TenantFromContext,
 		},
 		db: db,
 	}
@@ -61,6 +69,13 @@ func DBUseReflect() DBOption {
 		db.creator = valuer.NewReflectValue
 	}
 }
+
+// DBWithClock 指定获取当前时间的方法，测试中可以用来固定时间
+func DBWithClock(clock func() time.Time) DBOption {
+	return func(db *DB) {
+		db.clock = clock
+	}
+}
 func MustOpenDB(driver string, dataSourceName string, opts ...DBOption) *DB {
 	db, err := Open(driver, dataSourceName, opts...)
 	if err != nil {
@@ -76,6 +91,7 @@ func (d *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
 	}
 	return &Tx{
 		tx: tx,
+		db: d,
 	}, nil
 }
 
@@ -116,25 +132,57 @@ func (d *DB) DoTx(ctx context.Context, fn func(ctx context.Context, tx *Tx) erro
 	defer func() {
 		if panicked || err != nil {
 			e := tx.Rollback()
-			err = errs.NewErrFailedToRollback(err, e, panicked)
+			if e != nil {
+				err = errs.NewErrFailedToRollback(err, e, panicked)
+			}
 		} else {
 			err = tx.Commit()
 		}
 	}()
 	err = fn(ctx, tx)
 	panicked = false
-	return nil
+	return err
 }
 func (d *DB) getCore() core {
 	return d.core
 }
 func (d *DB) queryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
-	return d.db.QueryContext(ctx, query, args...)
+	if d.stmtCache == nil {
+		return d.db.QueryContext(ctx, query, args...)
+	}
+	entry, err := d.stmtCache.acquire(ctx, d.db, query)
+	if err != nil {
+		return nil, err
+	}
+	defer d.stmtCache.release(entry)
+	return entry.stmt.QueryContext(ctx, args...)
 }
 
 func (d *DB) execContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
-	return d.db.ExecContext(ctx, query, args...)
+	if d.stmtCache == nil {
+		return d.db.ExecContext(ctx, query, args...)
+	}
+	entry, err := d.stmtCache.acquire(ctx, d.db, query)
+	if err != nil {
+		return nil, err
+	}
+	defer d.stmtCache.release(entry)
+	return entry.stmt.ExecContext(ctx, args...)
+}
+
+// Close 关闭缓存的预编译语句和数据库连接
+func (d *DB) Close() error {
+	if d.stmtCache != nil {
+		d.stmtCache.close()
+	}
+	return d.db.Close()
 }
+
+// Stats 返回连接池的统计信息
+func (d *DB) Stats() sql.DBStats {
+	return d.db.Stats()
+}
+
 func (d *DB) Wait() error {
 	err := d.db.Ping()
 	for errors.Is(err, driver.ErrBadConn) {
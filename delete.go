@@ -1,12 +1,19 @@
 package go_orm
 
-import "context"
+import (
+	"context"
+	"slices"
+)
 
 type Deleter[T any] struct {
 	builder
 	table string
 	where []Predicate
-	sess  Session
+	// unscoped 不追加软删除等自动过滤条件
+	unscoped bool
+	// hardDelete 即便模型支持软删除，也执行物理删除
+	hardDelete bool
+	sess       Session
 }
 
 // NewDeleter 开始构建一个 DELETE 查询
@@ -20,37 +27,57 @@ func NewDeleter[T any](sess Session) *Deleter[T] {
 		sess: sess,
 	}
 }
+
+// Build 在副本上构造 SQL，不会修改 Deleter 本身，可以重复调用，也可以并发调用
 func (d *Deleter[T]) Build() (*Query, error) {
+	b := *d
+	return b.build()
+}
+
+func (d *Deleter[T]) build() (*Query, error) {
 	m, err := d.r.Get(new(T))
 	if err != nil {
 		return nil, err
 	}
 	d.model = m
+	if err = d.checkTenant(); err != nil {
+		return nil, err
+	}
 
-	d.sb.WriteString("DELETE FROM ")
-	// 表名 如果没有指定表名，则使用类型名
-	if d.table == "" {
-		d.quote(d.model.TableName)
+	if err = d.buildWith(); err != nil {
+		return nil, err
+	}
+	// 模型支持软删除时，DELETE 改写为 UPDATE 软删除字段
+	softDelete := m.SoftDeleteField != nil && !d.hardDelete
+	if softDelete {
+		d.sb.WriteString("UPDATE ")
 	} else {
-		// 自己指定表名，不会自动加反引号， 因为可能是 db.table 这种形式
-		d.sb.WriteString(d.table)
+		d.sb.WriteString("DELETE FROM ")
+	}
+	// 表名 如果没有指定表名，则使用类型名
+	if err = d.buildTable(d.table); err != nil {
+		return nil, err
+	}
+	if softDelete {
+		d.sb.WriteString(" SET ")
+		d.quote(m.SoftDeleteField.ColName)
+		d.sb.WriteString(" = ?")
+		d.addColumnArg(m.SoftDeleteField.ColName, deletedValue(m.SoftDeleteField, d.clock()))
 	}
 
 	// 条件构造
-	if len(d.where) > 0 {
+	where := d.scope(d.where, d.unscoped, d.table)
+	if len(where) > 0 {
 		d.sb.WriteString(" WHERE ")
 
-		if err := d.buildPredicates(d.where); err != nil {
+		if err := d.buildPredicates(where); err != nil {
 			return nil, err
 		}
 
 	}
 
 	d.sb.WriteByte(';')
-	return &Query{
-		SQL:  d.sb.String(),
-		Args: d.args,
-	}, nil
+	return d.query(), nil
 
 }
 
@@ -63,15 +90,91 @@ func (d *Deleter[T]) Where(prs ...Predicate) *Deleter[T] {
 	return d
 }
 
+// With 添加公共表表达式 WITH name AS (q)，可以在子查询中把 name 当作表使用
+func (d *Deleter[T]) With(name string, q QueryBuilder) *Deleter[T] {
+	d.ctes = append(d.ctes, cte{name: name, query: q})
+	return d
+}
+
+// WithRecursive 添加递归公共表表达式 WITH RECURSIVE name AS (anchor UNION ALL recursive)
+func (d *Deleter[T]) WithRecursive(name string, anchor, recursive QueryBuilder) *Deleter[T] {
+	d.ctes = append(d.ctes, cte{name: name, query: anchor, recursive: recursive})
+	return d
+}
+
+// Unscoped 删除时不再自动过滤已经软删除的数据
+func (d *Deleter[T]) Unscoped() *Deleter[T] {
+	d.unscoped = true
+	return d
+}
+
+// HardDelete 强制物理删除
+func (d *Deleter[T]) HardDelete() *Deleter[T] {
+	d.hardDelete = true
+	return d
+}
+
+// AllowFullTableScan 显式允许没有 WHERE 的删除
+func (d *Deleter[T]) AllowFullTableScan() *Deleter[T] {
+	d.allowFullTableScan = true
+	return d
+}
+
+func (d *Deleter[T]) Statement() StatementInfo {
+	m, _ := d.r.Get(new(T))
+	exprs := make([]Expression, 0, len(d.where))
+	for _, p := range d.where {
+		exprs = append(exprs, p)
+	}
+	return StatementInfo{
+		HasWhere:             len(d.where) > 0,
+		FullTableScanAllowed: d.allowFullTableScan,
+		Tables:               d.tables(m, d.table, exprs...),
+	}
+}
+
+// Clone 复制一个 Deleter，副本和原来的 Deleter 互不影响
+func (d *Deleter[T]) Clone() *Deleter[T] {
+	res := *d
+	res.where = slices.Clone(d.where)
+	res.ctes = slices.Clone(d.ctes)
+	return &res
+}
+
 // Exec sql
 func (d *Deleter[T]) Exec(ctx context.Context) Result {
-	query, err := d.Build()
+	// 在副本上执行，同一个 Deleter 可以并发执行
+	cp := *d
+	d = &cp
+	entity := new(T)
+	if err := beforeDelete(ctx, d.sess, entity); err != nil {
+		return Result{err: err}
+	}
+	var err error
+	d.model, err = d.r.Get(entity)
 	if err != nil {
 		return Result{err: err}
 	}
-	res, err := d.sess.execContext(ctx, query.SQL, query.Args)
-	return Result{
-		err: err,
-		res: res,
+	if err = d.bindTenant(ctx); err != nil {
+		return Result{err: err}
+	}
+	res := exec(ctx, d.sess, d.core, &QueryContext{
+		Type:    "DELETE",
+		Builder: d,
+		Model:   d.model,
+	})
+	r := Result{err: res.Err}
+	if res.Result != nil {
+		r = res.Result.(Result)
+	}
+	if r.err != nil {
+		return r
+	}
+	if err = afterDelete(ctx, d.sess, entity); err != nil {
+		return Result{
+			err: err,
+			res: r.res,
+		}
 	}
+	return r
 }
@@ -1,9 +1,13 @@
 package go_orm
 
 import (
+	"github.com/Andras5014/go-orm/internal/errs"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"reflect"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestDeleter_Build(t *testing.T) {
@@ -26,26 +30,114 @@ func TestDeleter_Build(t *testing.T) {
 			name: "where",
 			d:    NewDeleter[TestModel](db).Where(C("Id").Eq(18)),
 			wantQuery: &Query{
-				SQL:  "DELETE FROM `test_model` WHERE `id` = ?;",
-				Args: []any{18},
+				SQL:        "DELETE FROM `test_model` WHERE `id` = ?;",
+				Args:       []any{18},
+				ArgColumns: []string{"id"},
+			},
+		},
+		{
+			name: "with",
+			d: NewDeleter[TestModel](db).
+				With("old", NewSelector[TestModel](db).Select(C("Id")).Where(C("Age").Gt(60))).
+				Where(C("Id").InQuery(NewSelector[TestModel](db).Select(C("Id")).From("`old`")), C("FirstName").Eq("Tom")),
+			wantQuery: &Query{
+				SQL: "WITH `old` AS (SELECT `id` FROM `test_model` WHERE `age` > ?) DELETE FROM `test_model`" +
+					" WHERE (`id` IN (SELECT `id` FROM `old`)) AND (`first_name` = ?);",
+				Args:       []any{60, "Tom"},
+				ArgColumns: []string{"age", "first_name"},
 			},
 		},
 		{
 			name: "multiple where",
 			d:    NewDeleter[TestModel](db).Where(C("Id").Eq(18).Or(C("Id").Eq(19))),
 			wantQuery: &Query{
-				SQL:  "DELETE FROM `test_model` WHERE (`id` = ?) OR (`id` = ?);",
-				Args: []any{18, 19},
+				SQL:        "DELETE FROM `test_model` WHERE (`id` = ?) OR (`id` = ?);",
+				Args:       []any{18, 19},
+				ArgColumns: []string{"id", "id"},
 			},
 		},
 		{
 			name: "raw where",
 			d:    NewDeleter[TestModel](db).Where(Raw("id = ?", 18).AsPredicate()),
 			wantQuery: &Query{
-				SQL:  "DELETE FROM `test_model` WHERE id = ?;",
-				Args: []any{18},
+				SQL:        "DELETE FROM `test_model` WHERE id = ?;",
+				Args:       []any{18},
+				ArgColumns: []string{""},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.d.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+}
+
+func TestDeleter_SoftDelete(t *testing.T) {
+	db, err := OpenDB(nil, DBWithDialect(DialectMySQL))
+	require.NoError(t, err)
+	testCases := []struct {
+		name      string
+		d         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "soft delete",
+			d:    NewDeleter[SoftDeleteFlagModel](db).Where(C("Id").Eq(18)),
+			wantQuery: &Query{
+				SQL:        "UPDATE `soft_delete_flag_model` SET `deleted` = ? WHERE (`id` = ?) AND (`deleted` = ?);",
+				Args:       []any{true, 18, false},
+				ArgColumns: []string{"deleted", "id", "deleted"},
+			},
+		},
+		{
+			name: "soft delete without where",
+			d:    NewDeleter[SoftDeleteFlagModel](db),
+			wantQuery: &Query{
+				SQL:        "UPDATE `soft_delete_flag_model` SET `deleted` = ? WHERE `deleted` = ?;",
+				Args:       []any{true, false},
+				ArgColumns: []string{"deleted", "deleted"},
+			},
+		},
+		{
+			name: "unscoped",
+			d:    NewDeleter[SoftDeleteFlagModel](db).Where(C("Id").Eq(18)).Unscoped(),
+			wantQuery: &Query{
+				SQL:        "UPDATE `soft_delete_flag_model` SET `deleted` = ? WHERE `id` = ?;",
+				Args:       []any{true, 18},
+				ArgColumns: []string{"deleted", "id"},
+			},
+		},
+		{
+			name: "hard delete",
+			d:    NewDeleter[SoftDeleteFlagModel](db).Where(C("Id").Eq(18)).HardDelete(),
+			wantQuery: &Query{
+				SQL:        "DELETE FROM `soft_delete_flag_model` WHERE (`id` = ?) AND (`deleted` = ?);",
+				Args:       []any{18, false},
+				ArgColumns: []string{"id", "deleted"},
+			},
+		},
+		{
+			name: "hard delete unscoped",
+			d:    NewDeleter[SoftDeleteFlagModel](db).Where(C("Id").Eq(18)).HardDelete().Unscoped(),
+			wantQuery: &Query{
+				SQL:        "DELETE FROM `soft_delete_flag_model` WHERE `id` = ?;",
+				Args:       []any{18},
+				ArgColumns: []string{"id"},
 			},
 		},
+		{
+			name:    "invalid soft delete type",
+			d:       NewDeleter[InvalidSoftDeleteModel](db),
+			wantErr: errs.NewErrUnsupportedSoftDeleteType(reflect.TypeOf("")),
+		},
 	}
 
 	for _, tc := range testCases {
@@ -59,3 +151,61 @@ func TestDeleter_Build(t *testing.T) {
 		})
 	}
 }
+
+func TestDeleter_SoftDeleteTime(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	db, err := OpenDB(nil, DBWithDialect(DialectMySQL), DBWithClock(func() time.Time {
+		return now
+	}))
+	require.NoError(t, err)
+	q, err := NewDeleter[SoftDeleteModel](db).Where(C("Id").Eq(18)).Build()
+	require.NoError(t, err)
+	assert.Equal(t, &Query{
+		SQL:        "UPDATE `soft_delete_model` SET `deleted_at` = ? WHERE (`id` = ?) AND (`deleted_at` IS NULL);",
+		Args:       []any{now, 18},
+		ArgColumns: []string{"deleted_at", "id"},
+	}, q)
+}
+
+type SoftDeleteModel struct {
+	Id        int64
+	FirstName string
+	DeletedAt *time.Time `orm:"soft_delete"`
+}
+
+type SoftDeleteFlagModel struct {
+	Id      int64
+	Deleted bool `orm:"soft_delete"`
+}
+
+type InvalidSoftDeleteModel struct {
+	Id        int64
+	DeletedAt string `orm:"soft_delete"`
+}
+
+func TestDeleter_Clone(t *testing.T) {
+	db := memoryDB(t)
+	base := NewDeleter[TestModel](db).Where(C("Age").Gt(18))
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			q, err := base.Build()
+			assert.NoError(t, err)
+			assert.Equal(t, &Query{
+				SQL:        "DELETE FROM `test_model` WHERE `age` > ?;",
+				Args:       []any{18},
+				ArgColumns: []string{"age"},
+			}, q)
+			q, err = base.Clone().Where(C("Id").Eq(i)).Build()
+			assert.NoError(t, err)
+			assert.Equal(t, &Query{
+				SQL:        "DELETE FROM `test_model` WHERE `id` = ?;",
+				Args:       []any{i},
+				ArgColumns: []string{"id"},
+			}, q)
+		}(i)
+	}
+	wg.Wait()
+}
@@ -2,6 +2,7 @@ package go_orm
 
 import (
 	"github.com/Andras5014/go-orm/internal/errs"
+	"github.com/Andras5014/go-orm/model"
 )
 
 var (
@@ -11,10 +12,18 @@ var (
 )
 
 type Dialect interface {
+	// Name 数据库的名字，和 OpenTelemetry 的 db.system 取值一致，例如 mysql、sqlite、postgresql
+	Name() string
 	// quoter 解决引号问题
 	quoter() byte
 
+	// buildInsert 构造 INSERT 关键字，upsert 可能为 nil
+	buildInsert(b *builder, upsert *Upsert)
 	buildUpsert(b *builder, upsert *Upsert) error
+	// buildExcluded 构造 upsert 时准备插入的值
+	buildExcluded(b *builder, fd *model.Field)
+	// buildConcat 构造字符串拼接
+	buildConcat(b *builder, args []Expression) error
 }
 
 type standardSQL struct {
@@ -24,39 +33,81 @@ func (s standardSQL) quoter() byte {
 	return '`'
 }
 
+func (s standardSQL) buildInsert(b *builder, upsert *Upsert) {
+	b.sb.WriteString("INSERT INTO ")
+}
+
 func (s standardSQL) buildUpsert(b *builder, upsert *Upsert) error {
-	b.sb.WriteString(" ON CONFLICT (")
-	for i, col := range upsert.conflictColumns {
+	return s.buildOnConflict(b, upsert, "")
+}
+
+// buildOnConflict 构造 ON CONFLICT，table 不为空时 SET 的值和 WHERE 中引用已经存在的行的列加上表名
+func (s standardSQL) buildOnConflict(b *builder, upsert *Upsert, table string) error {
+	b.sb.WriteString(" ON CONFLICT")
+	if len(upsert.conflictColumns) > 0 {
+		b.sb.WriteString(" (")
+		for i, col := range upsert.conflictColumns {
+			if i > 0 {
+				b.sb.WriteString(",")
+			}
+			err := b.buildColumn(Column{name: col})
+			if err != nil {
+				return err
+			}
+		}
+		b.sb.WriteByte(')')
+	}
+	if upsert.doNothing {
+		b.sb.WriteString(" DO NOTHING")
+		return nil
+	}
+	b.sb.WriteString(" DO UPDATE SET ")
+	b.colTable = table
+	defer func() {
+		b.colTable = ""
+	}()
+	if err := b.buildUpsertAssigns(upsert.assigns); err != nil {
+		return err
+	}
+	if len(upsert.where) > 0 {
+		b.sb.WriteString(" WHERE ")
+		return b.buildPredicates(upsert.where)
+	}
+	return nil
+}
+
+func (s standardSQL) buildExcluded(b *builder, fd *model.Field) {
+	b.sb.WriteString("EXCLUDED.")
+	b.quote(fd.ColName)
+}
+
+func (s standardSQL) buildConcat(b *builder, args []Expression) error {
+	for i, arg := range args {
 		if i > 0 {
-			b.sb.WriteString(",")
+			b.sb.WriteString(" || ")
 		}
-		err := b.buildColumn(Column{name: col})
-		if err != nil {
+		if err := b.buildOperand(arg); err != nil {
 			return err
 		}
 	}
-	b.sb.WriteString(") DO UPDATE SET ")
-	for i, assign := range upsert.assigns {
+	return nil
+}
+
+// buildUpsertAssigns 构造冲突时的赋值，Column 表示更新为准备插入的值
+func (b *builder) buildUpsertAssigns(assigns []Assignable) error {
+	for i, assign := range assigns {
 		if i > 0 {
 			b.sb.WriteString(",")
 		}
 		switch a := assign.(type) {
 		case Assignment:
-			fd, ok := b.model.FieldMap[a.col]
-			if !ok {
-				return errs.NewErrUnknownField(a.col)
+			if err := b.buildAssignment(a); err != nil {
+				return err
 			}
-			b.quote(fd.ColName)
-			b.sb.WriteString("=?")
-			b.addArg(a.val)
 		case Column:
-			fd, ok := b.model.FieldMap[a.name]
-			if !ok {
-				return errs.NewErrUnknownField(a.name)
+			if err := b.buildAssignment(Assign(a.name, Excluded(a.name))); err != nil {
+				return err
 			}
-			b.quote(fd.ColName)
-			b.sb.WriteString("=EXCLUDED.")
-			b.quote(fd.ColName)
 		default:
 			return errs.NewErrUnsupportedAssignable(assign)
 		}
@@ -68,38 +119,47 @@ type mysqlDialect struct {
 	standardSQL
 }
 
+func (m mysqlDialect) Name() string {
+	return "mysql"
+}
+
 func (m mysqlDialect) quoter() byte {
 	return '`'
 }
+
+func (m mysqlDialect) buildInsert(b *builder, upsert *Upsert) {
+	if upsert != nil && upsert.doNothing {
+		b.sb.WriteString("INSERT IGNORE INTO ")
+		return
+	}
+	b.sb.WriteString("INSERT INTO ")
+}
+
 func (m mysqlDialect) buildUpsert(b *builder, upsert *Upsert) error {
+	if upsert.doNothing {
+		// 已经使用 INSERT IGNORE
+		return nil
+	}
+	if len(upsert.where) > 0 {
+		return errs.ErrUnsupportedUpsertWhere
+	}
 	b.sb.WriteString(" ON DUPLICATE KEY UPDATE ")
-	for idx, assign := range upsert.assigns {
-		if idx > 0 {
-			b.sb.WriteString(",")
-		}
-		switch a := assign.(type) {
-		case Assignment:
-			fd, ok := b.model.FieldMap[a.col]
-			if !ok {
-				return errs.NewErrUnknownField(a.col)
-			}
-			b.quote(fd.ColName)
-			b.sb.WriteString("=?")
-			b.addArg(a.val)
-		case Column:
-			fd, ok := b.model.FieldMap[a.name]
-			if !ok {
-				return errs.NewErrUnknownField(a.name)
-			}
-			b.quote(fd.ColName)
-			b.sb.WriteString("=VALUES(")
-			b.quote(fd.ColName)
-			b.sb.WriteString(")")
+	return b.buildUpsertAssigns(upsert.assigns)
+}
 
-		default:
-			return errs.NewErrUnsupportedAssignable(assign)
-		}
+func (m mysqlDialect) buildExcluded(b *builder, fd *model.Field) {
+	b.sb.WriteString("VALUES(")
+	b.quote(fd.ColName)
+	b.sb.WriteByte(')')
+}
+
+// buildConcat MySQL 中 || 默认是逻辑或，使用 CONCAT
+func (m mysqlDialect) buildConcat(b *builder, args []Expression) error {
+	b.sb.WriteString("CONCAT(")
+	if err := b.buildExpressions(args); err != nil {
+		return err
 	}
+	b.sb.WriteByte(')')
 	return nil
 }
 
@@ -107,49 +167,31 @@ type sqliteDialect struct {
 	standardSQL
 }
 
+func (s sqliteDialect) Name() string {
+	return "sqlite"
+}
+
 func (s sqliteDialect) quoter() byte {
 	return '`'
 }
-func (s sqliteDialect) buildUpsert(b *builder, upsert *Upsert) error {
-	b.sb.WriteString(" ON CONFLICT (")
-	for i, col := range upsert.conflictColumns {
-		if i > 0 {
-			b.sb.WriteString(",")
-		}
-		err := b.buildColumn(Column{name: col})
-		if err != nil {
-			return err
-		}
-	}
-	b.sb.WriteString(") DO UPDATE SET ")
-	for i, assign := range upsert.assigns {
-		if i > 0 {
-			b.sb.WriteString(",")
-		}
-		switch a := assign.(type) {
-		case Assignment:
-			fd, ok := b.model.FieldMap[a.col]
-			if !ok {
-				return errs.NewErrUnknownField(a.col)
-			}
-			b.quote(fd.ColName)
-			b.sb.WriteString("=?")
-			b.addArg(a.val)
-		case Column:
-			fd, ok := b.model.FieldMap[a.name]
-			if !ok {
-				return errs.NewErrUnknownField(a.name)
-			}
-			b.quote(fd.ColName)
-			b.sb.WriteString("=EXCLUDED.")
-			b.quote(fd.ColName)
-		default:
-			return errs.NewErrUnsupportedAssignable(assign)
-		}
-	}
-	return nil
-}
 
 type postgresDialect struct {
 	standardSQL
 }
+
+func (p postgresDialect) Name() string {
+	return "postgresql"
+}
+
+func (p postgresDialect) quoter() byte {
+	return '"'
+}
+
+// buildUpsert PostgreSQL 冲突时更新必须指定冲突的列，
+// 并且 SET 的值和 WHERE 中不带表名的列有歧义，例如 "age" + EXCLUDED."age"，因此加上表名
+func (p postgresDialect) buildUpsert(b *builder, upsert *Upsert) error {
+	if !upsert.doNothing && len(upsert.conflictColumns) == 0 {
+		return errs.ErrMissingConflictColumns
+	}
+	return p.buildOnConflict(b, upsert, b.model.TableName)
+}
@@ -0,0 +1,104 @@
+package go_orm
+
+import (
+	"github.com/Andras5014/go-orm/internal/errs"
+	"github.com/Andras5014/go-orm/model"
+	"reflect"
+)
+
+// blindIndexer 加密字段的 Serializer 实现该接口后支持盲索引，例如 encryption.Cipher
+type blindIndexer interface {
+	BlindIndex(val any) (string, error)
+}
+
+func blindIndex(fd *model.Field, val any) (string, error) {
+	bi, ok := fd.Serializer.(blindIndexer)
+	if !ok {
+		return "", errs.NewErrEncryptedColumnQuery(fd.GoName)
+	}
+	return bi.BlindIndex(val)
+}
+
+// fillBlindIndex 根据加密字段的明文计算盲索引字段
+func fillBlindIndex(m *model.Model, entity any) error {
+	val := reflect.ValueOf(entity).Elem()
+	for _, fd := range m.Fields {
+		if fd.BlindIndex == nil {
+			continue
+		}
+		idx, err := blindIndex(fd, val.FieldByName(fd.GoName).Interface())
+		if err != nil {
+			return err
+		}
+		val.FieldByName(fd.BlindIndex.GoName).SetString(idx)
+	}
+	return nil
+}
+
+// blindIndexAssigns 为加密字段的赋值补充对应盲索引字段的赋值
+func blindIndexAssigns(m *model.Model, assigns []Assignable) ([]Assignable, error) {
+	res := assigns
+	for _, assign := range assigns {
+		var extra Assignable
+		switch a := assign.(type) {
+		case Assignment:
+			fd, ok := m.FieldMap[a.col]
+			if !ok || fd.BlindIndex == nil {
+				continue
+			}
+			if _, ok = a.val.(Expression); ok {
+				continue
+			}
+			idx, err := blindIndex(fd, a.val)
+			if err != nil {
+				return nil, err
+			}
+			extra = Assign(fd.BlindIndex.GoName, idx)
+		case Column:
+			fd, ok := m.FieldMap[a.name]
+			if !ok || fd.BlindIndex == nil {
+				continue
+			}
+			extra = C(fd.BlindIndex.GoName)
+		default:
+			continue
+		}
+		if len(res) == len(assigns) {
+			// 避免修改调用者的切片
+			res = append(make([]Assignable, 0, len(assigns)+1), assigns...)
+		}
+		res = append(res, extra)
+	}
+	return res, nil
+}
+
+// encryptedField 左边是加密字段并且右边是值的谓词需要特殊处理
+func (b *builder) encryptedField(p Predicate) *model.Field {
+	c, ok := p.left.(Column)
+	if !ok {
+		return nil
+	}
+	if _, ok = p.right.(value); !ok {
+		return nil
+	}
+	fd, ok := b.model.FieldMap[c.name]
+	if !ok || !fd.Encrypt {
+		return nil
+	}
+	return fd
+}
+
+// buildEncryptedPredicate 加密字段只支持通过盲索引进行等值查询
+func (b *builder) buildEncryptedPredicate(fd *model.Field, p Predicate) error {
+	if p.op != opEq || fd.BlindIndex == nil {
+		return errs.NewErrEncryptedColumnQuery(fd.GoName)
+	}
+	idx, err := blindIndex(fd, p.right.(value).arg)
+	if err != nil {
+		return err
+	}
+	b.quoteColumn(fd.BlindIndex.ColName)
+	b.sb.WriteString(" = ?")
+	b.addColumnArg(fd.BlindIndex.ColName, idx)
+	return nil
+}
@@ -0,0 +1,125 @@
+package go_orm
+
+import (
+	"context"
+	"github.com/Andras5014/go-orm/encryption"
+	"github.com/Andras5014/go-orm/internal/errs"
+	"github.com/Andras5014/go-orm/model"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func encryptDB(t *testing.T) (*DB, *encryption.Cipher, sqlmock.Sqlmock) {
+	c := encryption.NewCipher(encryption.StaticKeyProvider{
+		Current: "k1",
+		Keys:    map[string][]byte{"k1": []byte("0123456789abcdef")},
+	}, []byte("blind"))
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockDB, DBWithRegistry(model.NewRegistry(
+		model.RegistryWithSerializer(model.EncryptSerializer, c))))
+	require.NoError(t, err)
+	return db, c, mock
+}
+
+func TestInserter_Encrypt(t *testing.T) {
+	db, c, _ := encryptDB(t)
+	user := &EncryptModel{Id: 1, Email: "a@b.com"}
+	q, err := NewInserter[EncryptModel](db).Columns("Id", "Email").Values(user).Build()
+	require.NoError(t, err)
+	assert.Equal(t, "INSERT INTO `encrypt_model` (`id`,`email`,`email_idx`) VALUES (?,?,?);", q.SQL)
+
+	idx, err := c.BlindIndex("a@b.com")
+	require.NoError(t, err)
+	// Build 不修改传入的实体
+	assert.Empty(t, user.EmailIdx)
+	assert.Equal(t, idx, q.Args[2])
+
+	cipherText, ok := q.Args[1].([]byte)
+	require.True(t, ok)
+	assert.True(t, strings.HasPrefix(string(cipherText), "k1:"))
+	var plain string
+	require.NoError(t, c.Deserialize(cipherText, &plain))
+	assert.Equal(t, "a@b.com", plain)
+}
+
+func TestSelector_Encrypt(t *testing.T) {
+	db, c, mock := encryptDB(t)
+	idx, err := c.BlindIndex("a@b.com")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name      string
+		s         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "blind index",
+			s:    NewSelector[EncryptModel](db).Where(C("Email").Eq("a@b.com")),
+			wantQuery: &Query{
+				SQL:        "SELECT * FROM `encrypt_model` WHERE `email_idx` = ?;",
+				Args:       []any{idx},
+				ArgColumns: []string{"email_idx"},
+			},
+		},
+		{
+			name:    "range query",
+			s:       NewSelector[EncryptModel](db).Where(C("Email").Gt("a@b.com")),
+			wantErr: errs.NewErrEncryptedColumnQuery("Email"),
+		},
+		{
+			name:    "without blind index",
+			s:       NewSelector[EncryptModel](db).Where(C("Phone").Eq("138")),
+			wantErr: errs.NewErrEncryptedColumnQuery("Phone"),
+		},
+		{
+			name: "is null",
+			s:    NewSelector[EncryptModel](db).Where(C("Phone").IsNull()),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `encrypt_model` WHERE `phone` IS NULL;",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.s.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+
+	email, err := c.Serialize("a@b.com")
+	require.NoError(t, err)
+	mock.ExpectQuery("SELECT .*").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "email", "email_idx", "phone"}).AddRow(1, email, idx, nil))
+	res, err := NewSelector[EncryptModel](db).Where(C("Email").Eq("a@b.com")).Get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &EncryptModel{Id: 1, Email: "a@b.com", EmailIdx: idx}, res)
+}
+
+func TestUpdater_Encrypt(t *testing.T) {
+	db, c, _ := encryptDB(t)
+	q, err := NewUpdater[EncryptModel](db).Set(Assign("Email", "c@d.com")).Where(C("Id").Eq(1)).Build()
+	require.NoError(t, err)
+	assert.Equal(t, "UPDATE `encrypt_model` SET `email` = ?,`email_idx` = ? WHERE `id` = ?;", q.SQL)
+	idx, err := c.BlindIndex("c@d.com")
+	require.NoError(t, err)
+	assert.Equal(t, idx, q.Args[1])
+	var plain string
+	require.NoError(t, c.Deserialize(q.Args[0].([]byte), &plain))
+	assert.Equal(t, "c@d.com", plain)
+}
+
+type EncryptModel struct {
+	Id       int64
+	Email    string `orm:"encrypt"`
+	EmailIdx string `orm:"blind_index:Email"`
+	Phone    string `orm:"encrypt"`
+}
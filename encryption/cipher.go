@@ -0,0 +1,150 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	ErrInvalidCiphertext = errors.New("orm: invalid ciphertext")
+	ErrNoBlindIndexKey   = errors.New("orm: blind index key is not configured")
+)
+
+// KeyProvider 提供加解密使用的 AES 密钥
+// 密文以密钥 id 作为前缀，轮换密钥后旧数据依旧可以用旧密钥解密
+type KeyProvider interface {
+	// CurrentKey 返回加密使用的密钥及其 id
+	CurrentKey() (id string, key []byte, err error)
+	// Key 根据密文前缀中的 id 返回解密使用的密钥
+	Key(id string) ([]byte, error)
+}
+
+// StaticKeyProvider 固定的密钥集合，Current 为当前加密使用的密钥 id
+type StaticKeyProvider struct {
+	Current string
+	Keys    map[string][]byte
+}
+
+func (s StaticKeyProvider) CurrentKey() (string, []byte, error) {
+	key, err := s.Key(s.Current)
+	return s.Current, key, err
+}
+
+func (s StaticKeyProvider) Key(id string) ([]byte, error) {
+	key, ok := s.Keys[id]
+	if !ok {
+		return nil, fmt.Errorf("orm: unknown encryption key id: %s", id)
+	}
+	return key, nil
+}
+
+// Cipher 使用 AES-GCM 加密字段，实现了 serializer.Serializer
+// 支持 string 和 []byte 类型的字段，密文格式为 id:base64(nonce+密文)
+type Cipher struct {
+	kp            KeyProvider
+	blindIndexKey []byte
+}
+
+// NewCipher blindIndexKey 用于计算盲索引，为 nil 时不支持按加密字段做等值查询
+func NewCipher(kp KeyProvider, blindIndexKey []byte) *Cipher {
+	return &Cipher{
+		kp:            kp,
+		blindIndexKey: blindIndexKey,
+	}
+}
+
+func (c *Cipher) Serialize(val any) ([]byte, error) {
+	plain, err := toBytes(val)
+	if err != nil {
+		return nil, err
+	}
+	id, key, err := c.kp.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	sealed := gcm.Seal(nonce, nonce, plain, []byte(id))
+	return []byte(id + ":" + base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+func (c *Cipher) Deserialize(data []byte, ptr any) error {
+	id, encoded, ok := bytes.Cut(data, []byte{':'})
+	if !ok {
+		return ErrInvalidCiphertext
+	}
+	key, err := c.kp.Key(string(id))
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil || len(sealed) < gcm.NonceSize() {
+		return ErrInvalidCiphertext
+	}
+	nonce, sealed := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, sealed, id)
+	if err != nil {
+		return ErrInvalidCiphertext
+	}
+	switch dst := ptr.(type) {
+	case *string:
+		*dst = string(plain)
+	case *[]byte:
+		*dst = plain
+	default:
+		return fmt.Errorf("orm: encryption only supports *string and *[]byte, got %T", ptr)
+	}
+	return nil
+}
+
+// BlindIndex 计算确定性的盲索引，用于加密字段的等值查询
+func (c *Cipher) BlindIndex(val any) (string, error) {
+	if c.blindIndexKey == nil {
+		return "", ErrNoBlindIndexKey
+	}
+	plain, err := toBytes(val)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, c.blindIndexKey)
+	mac.Write(plain)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+func toBytes(val any) ([]byte, error) {
+	switch v := val.(type) {
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	default:
+		return nil, fmt.Errorf("orm: encryption only supports string and []byte, got %T", val)
+	}
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,68 @@
+package encryption
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestCipher(t *testing.T) {
+	kp := &StaticKeyProvider{
+		Current: "k1",
+		Keys: map[string][]byte{
+			"k1": []byte("0123456789abcdef"),
+			"k2": []byte("fedcba9876543210fedcba9876543210"),
+		},
+	}
+	c := NewCipher(kp, []byte("blind"))
+
+	data, err := c.Serialize("13800138000")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(data), "k1:"))
+	// 随机 nonce，两次加密的结果不同
+	data2, err := c.Serialize("13800138000")
+	require.NoError(t, err)
+	assert.NotEqual(t, data, data2)
+
+	// 轮换密钥后旧数据依旧可以解密
+	kp.Current = "k2"
+	data3, err := c.Serialize([]byte("secret"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(data3), "k2:"))
+
+	var str string
+	require.NoError(t, c.Deserialize(data, &str))
+	assert.Equal(t, "13800138000", str)
+	var bs []byte
+	require.NoError(t, c.Deserialize(data3, &bs))
+	assert.Equal(t, []byte("secret"), bs)
+
+	// 篡改密钥 id
+	tampered := append([]byte("k2"), data[2:]...)
+	assert.Equal(t, ErrInvalidCiphertext, c.Deserialize(tampered, &str))
+	assert.Equal(t, ErrInvalidCiphertext, c.Deserialize([]byte("plain"), &str))
+	assert.EqualError(t, c.Deserialize([]byte("k3:xxx"), &str), "orm: unknown encryption key id: k3")
+
+	_, err = c.Serialize(123)
+	assert.EqualError(t, err, "orm: encryption only supports string and []byte, got int")
+}
+
+func TestCipher_BlindIndex(t *testing.T) {
+	kp := StaticKeyProvider{
+		Current: "k1",
+		Keys:    map[string][]byte{"k1": []byte("0123456789abcdef")},
+	}
+	c := NewCipher(kp, []byte("blind"))
+	idx1, err := c.BlindIndex("a@b.com")
+	require.NoError(t, err)
+	idx2, err := c.BlindIndex([]byte("a@b.com"))
+	require.NoError(t, err)
+	assert.Equal(t, idx1, idx2)
+	idx3, err := c.BlindIndex("c@d.com")
+	require.NoError(t, err)
+	assert.NotEqual(t, idx1, idx3)
+
+	_, err = NewCipher(kp, nil).BlindIndex("a@b.com")
+	assert.Equal(t, ErrNoBlindIndexKey, err)
+}
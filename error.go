@@ -5,4 +5,14 @@ import "github.com/Andras5014/go-orm/internal/errs"
 // 内部错误暴露在外面
 var (
 	ErrNoRows = errs.ErrNoRows
+	// ErrMissingTenant 需要租户的查询在 context 中找不到租户
+	ErrMissingTenant = errs.ErrMissingTenant
+	// ErrUnsupportedUpsertWhere 方言不支持在 upsert 中使用 WHERE
+	ErrUnsupportedUpsertWhere = errs.ErrUnsupportedUpsertWhere
+	// ErrTenantUpsert 有租户字段的模型不支持冲突时更新，唯一索引冲突的可能是其它租户的行
+	ErrTenantUpsert = errs.ErrTenantUpsert
+	// ErrMissingConflictColumns PostgreSQL 冲突时更新必须指定冲突的列
+	ErrMissingConflictColumns = errs.ErrMissingConflictColumns
+	// ErrTenantSchemaTable 按 schema 隔离租户时 From 指定了 JOIN 或者带 schema 的表
+	ErrTenantSchemaTable = errs.ErrTenantSchemaTable
 )
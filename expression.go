@@ -31,3 +31,103 @@ func (r RawExpr) AsPredicate() Predicate {
 		left: r,
 	}
 }
+
+// MathExpr 算术表达式，例如 C("Age").Add(1)
+type MathExpr struct {
+	left  Expression
+	op    op
+	right Expression
+	alias string
+}
+
+func mathExpr(left Expression, op op, val any) MathExpr {
+	return MathExpr{
+		left:  left,
+		op:    op,
+		right: valueOf(val),
+	}
+}
+
+func (MathExpr) expr()       {}
+func (MathExpr) selectable() {}
+
+func (m MathExpr) As(alias string) MathExpr {
+	m.alias = alias
+	return m
+}
+
+func (m MathExpr) Add(val any) MathExpr {
+	return mathExpr(m, opAdd, val)
+}
+
+func (m MathExpr) Sub(val any) MathExpr {
+	return mathExpr(m, opSub, val)
+}
+
+func (m MathExpr) Mul(val any) MathExpr {
+	return mathExpr(m, opMul, val)
+}
+
+func (m MathExpr) Div(val any) MathExpr {
+	return mathExpr(m, opDiv, val)
+}
+
+func (m MathExpr) Mod(val any) MathExpr {
+	return mathExpr(m, opMod, val)
+}
+
+func (m MathExpr) Eq(arg any) Predicate {
+	return Predicate{
+		left:  m,
+		op:    opEq,
+		right: valueOf(arg),
+	}
+}
+
+func (m MathExpr) Lt(arg any) Predicate {
+	return Predicate{
+		left:  m,
+		op:    opLt,
+		right: valueOf(arg),
+	}
+}
+
+func (m MathExpr) Gt(arg any) Predicate {
+	return Predicate{
+		left:  m,
+		op:    opGt,
+		right: valueOf(arg),
+	}
+}
+
+// ExcludedColumn 引用 upsert 时准备插入的值
+// PostgreSQL 和 SQLite 中是 EXCLUDED.col，MySQL 中是 VALUES(col)
+type ExcludedColumn struct {
+	name string
+}
+
+func Excluded(col string) ExcludedColumn {
+	return ExcludedColumn{name: col}
+}
+
+func (ExcludedColumn) expr() {}
+
+func (e ExcludedColumn) Add(val any) MathExpr {
+	return mathExpr(e, opAdd, val)
+}
+
+func (e ExcludedColumn) Sub(val any) MathExpr {
+	return mathExpr(e, opSub, val)
+}
+
+func (e ExcludedColumn) Mul(val any) MathExpr {
+	return mathExpr(e, opMul, val)
+}
+
+func (e ExcludedColumn) Div(val any) MathExpr {
+	return mathExpr(e, opDiv, val)
+}
+
+func (e ExcludedColumn) Mod(val any) MathExpr {
+	return mathExpr(e, opMod, val)
+}
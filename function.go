@@ -0,0 +1,103 @@
+package go_orm
+
+// FuncExpr 函数调用，例如 Fn("COALESCE", C("Nick"), C("Name"))
+type FuncExpr struct {
+	name string
+	args []Expression
+	// keyword 为 true 时只输出函数名，例如 CURRENT_TIMESTAMP
+	keyword bool
+	// concat 字符串拼接，不同方言的写法不同
+	concat bool
+	alias  string
+}
+
+// Fn 调用任意函数，参数可以是列、表达式或者值，值会作为查询参数
+func Fn(name string, args ...any) FuncExpr {
+	exprs := make([]Expression, 0, len(args))
+	for _, arg := range args {
+		exprs = append(exprs, valueOf(arg))
+	}
+	return FuncExpr{
+		name: name,
+		args: exprs,
+	}
+}
+
+func Lower(arg any) FuncExpr {
+	return Fn("LOWER", arg)
+}
+
+func Upper(arg any) FuncExpr {
+	return Fn("UPPER", arg)
+}
+
+func Coalesce(args ...any) FuncExpr {
+	return Fn("COALESCE", args...)
+}
+
+// Now 当前时间，使用各个数据库都支持的 CURRENT_TIMESTAMP
+func Now() FuncExpr {
+	return FuncExpr{
+		name:    "CURRENT_TIMESTAMP",
+		keyword: true,
+	}
+}
+
+// Concat 拼接字符串，MySQL 使用 CONCAT(a,b)，其余数据库使用 a || b
+func Concat(args ...any) FuncExpr {
+	f := Fn("CONCAT", args...)
+	f.concat = true
+	return f
+}
+
+func (FuncExpr) expr()       {}
+func (FuncExpr) selectable() {}
+
+func (f FuncExpr) As(alias string) FuncExpr {
+	f.alias = alias
+	return f
+}
+
+func (f FuncExpr) Add(val any) MathExpr {
+	return mathExpr(f, opAdd, val)
+}
+
+func (f FuncExpr) Sub(val any) MathExpr {
+	return mathExpr(f, opSub, val)
+}
+
+func (f FuncExpr) Mul(val any) MathExpr {
+	return mathExpr(f, opMul, val)
+}
+
+func (f FuncExpr) Div(val any) MathExpr {
+	return mathExpr(f, opDiv, val)
+}
+
+func (f FuncExpr) Mod(val any) MathExpr {
+	return mathExpr(f, opMod, val)
+}
+
+func (f FuncExpr) Eq(arg any) Predicate {
+	return Predicate{
+		left:  f,
+		op:    opEq,
+		right: valueOf(arg),
+	}
+}
+
+func (f FuncExpr) Lt(arg any) Predicate {
+	return Predicate{
+		left:  f,
+		op:    opLt,
+		right: valueOf(arg),
+	}
+}
+
+func (f FuncExpr) Gt(arg any) Predicate {
+	return Predicate{
+		left:  f,
+		op:    opGt,
+		right: valueOf(arg),
+	}
+}
@@ -0,0 +1,91 @@
+package go_orm
+
+import "context"
+
+// 实体可以实现以下接口，在执行语句前后被回调
+// Before 钩子返回错误会中止语句的执行，After 钩子返回的错误会作为执行结果返回，
+// 在 DoTx 中使用时会导致事务回滚
+//
+// Updater 的钩子在 Update 指定的实体上调用，没有指定时使用 T 的零值。
+// BeforeUpdate 调用前 Set 中的字面量赋值会写入实体，钩子修改过的字段会写回赋值，
+// 所以钩子可以校验要写入的值，也可以计算派生字段
+//
+// Deleter 没有具体的实体，钩子总是在 T 的零值上调用，只适合做权限校验或者拒绝删除
+
+type BeforeInsertHook interface {
+	BeforeInsert(ctx context.Context, sess Session) error
+}
+
+type AfterInsertHook interface {
+	AfterInsert(ctx context.Context, sess Session) error
+}
+
+type BeforeUpdateHook interface {
+	BeforeUpdate(ctx context.Context, sess Session) error
+}
+
+type AfterUpdateHook interface {
+	AfterUpdate(ctx context.Context, sess Session) error
+}
+
+type BeforeDeleteHook interface {
+	BeforeDelete(ctx context.Context, sess Session) error
+}
+
+type AfterDeleteHook interface {
+	AfterDelete(ctx context.Context, sess Session) error
+}
+
+// AfterFindHook 在 Selector.Get 和 Selector.GetMulti 查询到数据之后调用
+type AfterFindHook interface {
+	AfterFind(ctx context.Context) error
+}
+
+func beforeInsert(ctx context.Context, sess Session, entity any) error {
+	if h, ok := entity.(BeforeInsertHook); ok {
+		return h.BeforeInsert(ctx, sess)
+	}
+	return nil
+}
+
+func afterInsert(ctx context.Context, sess Session, entity any) error {
+	if h, ok := entity.(AfterInsertHook); ok {
+		return h.AfterInsert(ctx, sess)
+	}
+	return nil
+}
+
+func beforeUpdate(ctx context.Context, sess Session, entity any) error {
+	if h, ok := entity.(BeforeUpdateHook); ok {
+		return h.BeforeUpdate(ctx, sess)
+	}
+	return nil
+}
+
+func afterUpdate(ctx context.Context, sess Session, entity any) error {
+	if h, ok := entity.(AfterUpdateHook); ok {
+		return h.AfterUpdate(ctx, sess)
+	}
+	return nil
+}
+
+func beforeDelete(ctx context.Context, sess Session, entity any) error {
+	if h, ok := entity.(BeforeDeleteHook); ok {
+		return h.BeforeDelete(ctx, sess)
+	}
+	return nil
+}
+
+func afterDelete(ctx context.Context, sess Session, entity any) error {
+	if h, ok := entity.(AfterDeleteHook); ok {
+		return h.AfterDelete(ctx, sess)
+	}
+	return nil
+}
+
+func afterFind(ctx context.Context, entity any) error {
+	if h, ok := entity.(AfterFindHook); ok {
+		return h.AfterFind(ctx)
+	}
+	return nil
+}
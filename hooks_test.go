@@ -0,0 +1,277 @@
+package go_orm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"github.com/Andras5014/go-orm/internal/errs"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestInserter_Hooks(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockDB)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name       string
+		entity     *HookModel
+		mock       func()
+		wantErr    error
+		wantEntity *HookModel
+	}{
+		{
+			name:    "before insert error",
+			entity:  &HookModel{Id: 1, beforeErr: errors.New("invalid")},
+			mock:    func() {},
+			wantErr: errors.New("invalid"),
+		},
+		{
+			name:   "derived field",
+			entity: &HookModel{Id: 1, FirstName: "tom"},
+			mock: func() {
+				mock.ExpectExec("INSERT INTO .*").
+					WithArgs(1, "tom", "TOM").
+					WillReturnResult(driver.RowsAffected(1))
+			},
+			wantEntity: &HookModel{Id: 1, FirstName: "tom", Display: "TOM", calls: []string{"BeforeInsert", "AfterInsert"}},
+		},
+		{
+			name:   "exec error",
+			entity: &HookModel{Id: 1, FirstName: "tom"},
+			mock: func() {
+				mock.ExpectExec("INSERT INTO .*").WillReturnError(errors.New("db error"))
+			},
+			wantErr:    errors.New("db error"),
+			wantEntity: &HookModel{Id: 1, FirstName: "tom", Display: "TOM", calls: []string{"BeforeInsert"}},
+		},
+		{
+			name:   "after insert error",
+			entity: &HookModel{Id: 1, FirstName: "tom", afterErr: errors.New("cache error")},
+			mock: func() {
+				mock.ExpectExec("INSERT INTO .*").WillReturnResult(driver.RowsAffected(1))
+			},
+			wantErr: errors.New("cache error"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mock()
+			res := NewInserter[HookModel](db).Columns("Id", "FirstName", "Display").Values(tc.entity).Exec(context.Background())
+			assert.Equal(t, tc.wantErr, res.Err())
+			if tc.wantEntity != nil {
+				assert.Equal(t, tc.wantEntity, tc.entity)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUpdaterAndDeleter_Hooks(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockDB)
+	require.NoError(t, err)
+
+	res := NewUpdater[RejectModel](db).Set(Assign("Id", 1)).Exec(context.Background())
+	assert.Equal(t, errRejected, res.Err())
+	res = NewDeleter[RejectModel](db).Exec(context.Background())
+	assert.Equal(t, errRejected, res.Err())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSelector_AfterFind(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockDB)
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT .*").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "first_name"}).AddRow(1, "tom"))
+	res, err := NewSelector[HookModel](db).Get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &HookModel{Id: 1, FirstName: "tom", Display: "TOM", calls: []string{"AfterFind"}}, res)
+
+	mock.ExpectQuery("SELECT .*").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "first_name"}).AddRow(1, "tom").AddRow(2, "jerry"))
+	multi, err := NewSelector[HookModel](db).GetMulti(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []*HookModel{
+		{Id: 1, FirstName: "tom", Display: "TOM", calls: []string{"AfterFind"}},
+		{Id: 2, FirstName: "jerry", Display: "JERRY", calls: []string{"AfterFind"}},
+	}, multi)
+}
+
+func TestDB_DoTx_HookRollback(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockDB)
+	require.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO .*").WillReturnResult(driver.RowsAffected(1))
+	mock.ExpectRollback()
+	err = db.DoTx(context.Background(), func(ctx context.Context, tx *Tx) error {
+		return NewInserter[HookModel](tx).Columns("Id").
+			Values(&HookModel{Id: 1, afterErr: errors.New("cache error")}).
+			Exec(ctx).Err()
+	}, &sql.TxOptions{})
+	assert.Equal(t, errors.New("cache error"), err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+type HookModel struct {
+	Id        int64
+	FirstName string
+	Display   string
+
+	// 以下字段不是列，插入时需要指定列
+	beforeErr error
+	afterErr  error
+	calls     []string
+}
+
+func (h *HookModel) BeforeInsert(ctx context.Context, sess Session) error {
+	if h.beforeErr != nil {
+		return h.beforeErr
+	}
+	h.Display = strings.ToUpper(h.FirstName)
+	h.calls = append(h.calls, "BeforeInsert")
+	return nil
+}
+
+func (h *HookModel) AfterInsert(ctx context.Context, sess Session) error {
+	if h.afterErr != nil {
+		return h.afterErr
+	}
+	h.calls = append(h.calls, "AfterInsert")
+	return nil
+}
+
+func (h *HookModel) AfterFind(ctx context.Context) error {
+	h.Display = strings.ToUpper(h.FirstName)
+	h.calls = append(h.calls, "AfterFind")
+	return nil
+}
+
+var errRejected = errors.New("rejected")
+
+type RejectModel struct {
+	Id int64
+}
+
+func (r *RejectModel) BeforeUpdate(ctx context.Context, sess Session) error {
+	return errRejected
+}
+
+func (r *RejectModel) BeforeDelete(ctx context.Context, sess Session) error {
+	return errRejected
+}
+
+func TestUpdater_Hooks(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockDB)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name       string
+		u          *Updater[UpdateHookModel]
+		mock       func()
+		wantErr    error
+		wantEntity *UpdateHookModel
+	}{
+		{
+			name: "derived field",
+			u:    NewUpdater[UpdateHookModel](db).Set(Assign("FirstName", "tom")).Where(C("Id").Eq(1)),
+			mock: func() {
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE `update_hook_model` SET `first_name` = ?,`display` = ? WHERE `id` = ?;")).
+					WithArgs("tom", "TOM", 1).
+					WillReturnResult(driver.RowsAffected(1))
+			},
+		},
+		{
+			name: "hook changes assigned value",
+			u:    NewUpdater[UpdateHookModel](db).Set(Assign("FirstName", " tom ")).Where(C("Id").Eq(1)),
+			mock: func() {
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE `update_hook_model` SET `first_name` = ?,`display` = ? WHERE `id` = ?;")).
+					WithArgs("tom", "TOM", 1).
+					WillReturnResult(driver.RowsAffected(1))
+			},
+		},
+		{
+			name: "update entity",
+			u: NewUpdater[UpdateHookModel](db).Update(&UpdateHookModel{Id: 1, FirstName: "jerry"}).
+				Set(C("FirstName")).Where(C("Id").Eq(1)),
+			mock: func() {
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE `update_hook_model` SET `first_name` = ?,`display` = ? WHERE `id` = ?;")).
+					WithArgs("jerry", "JERRY", 1).
+					WillReturnResult(driver.RowsAffected(1))
+			},
+			wantEntity: &UpdateHookModel{Id: 1, FirstName: "jerry", Display: "JERRY", calls: []string{"BeforeUpdate", "AfterUpdate"}},
+		},
+		{
+			name:    "validation",
+			u:       NewUpdater[UpdateHookModel](db).Set(Assign("FirstName", "")).Where(C("Id").Eq(1)),
+			mock:    func() {},
+			wantErr: errEmptyName,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mock()
+			res := tc.u.Exec(context.Background())
+			assert.Equal(t, tc.wantErr, res.Err())
+			if tc.wantEntity != nil {
+				assert.Equal(t, tc.wantEntity, tc.u.val)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUpdater_ColumnWithoutEntity(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockDB)
+	require.NoError(t, err)
+	_, err = NewUpdater[TestModel](db).Set(C("FirstName")).Build()
+	assert.Equal(t, errs.NewErrUnsupportedAssignableType(C("FirstName")), err)
+
+	// Exec 和 Build 一致，不会把零值写入数据库
+	err = NewUpdater[TestModel](db).Set(C("FirstName")).Where(C("Id").Eq(1)).Exec(context.Background()).Err()
+	assert.Equal(t, errs.NewErrUnsupportedAssignableType(C("FirstName")), err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+var errEmptyName = errors.New("empty name")
+
+type UpdateHookModel struct {
+	Id        int64
+	FirstName string
+	Display   string
+
+	calls []string
+}
+
+func (h *UpdateHookModel) BeforeUpdate(ctx context.Context, sess Session) error {
+	h.FirstName = strings.TrimSpace(h.FirstName)
+	if h.FirstName == "" {
+		return errEmptyName
+	}
+	h.Display = strings.ToUpper(h.FirstName)
+	h.calls = append(h.calls, "BeforeUpdate")
+	return nil
+}
+
+func (h *UpdateHookModel) AfterUpdate(ctx context.Context, sess Session) error {
+	h.calls = append(h.calls, "AfterUpdate")
+	return nil
+}
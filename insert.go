@@ -5,15 +5,22 @@ import (
 	"database/sql"
 	"github.com/Andras5014/go-orm/internal/errs"
 	"github.com/Andras5014/go-orm/model"
+	"slices"
+	"time"
 )
 
 type UpsertBuilder[T any] struct {
 	i               *Inserter[T]
 	conflictColumns []string
+	where           []Predicate
 }
 type Upsert struct {
 	assigns         []Assignable
 	conflictColumns []string
+	// doNothing 冲突时忽略，MySQL 使用 INSERT IGNORE
+	doNothing bool
+	// where 只有冲突的行满足条件时才更新，MySQL 不支持
+	where []Predicate
 }
 
 // ConflictColumns 中间方法
@@ -21,10 +28,33 @@ func (o *UpsertBuilder[T]) ConflictColumns(cols ...string) *UpsertBuilder[T] {
 	o.conflictColumns = cols
 	return o
 }
+
+// Where 只更新满足条件的冲突行，对应 PostgreSQL 和 SQLite 的 DO UPDATE SET ... WHERE
+func (o *UpsertBuilder[T]) Where(ps ...Predicate) *UpsertBuilder[T] {
+	o.where = ps
+	return o
+}
+
 func (o *UpsertBuilder[T]) Update(assigns ...Assignable) *Inserter[T] {
 	o.i.OnDuplicateKey = &Upsert{
 		assigns:         assigns,
 		conflictColumns: o.conflictColumns,
+		where:           o.where,
+	}
+	return o.i
+}
+
+// DoUpdate 冲突时更新，和 Update 相同
+// 可以使用 Excluded 引用准备插入的值，例如 Assign("Count", C("Count").Add(Excluded("Count")))
+func (o *UpsertBuilder[T]) DoUpdate(assigns ...Assignable) *Inserter[T] {
+	return o.Update(assigns...)
+}
+
+// DoNothing 冲突时忽略这一行
+func (o *UpsertBuilder[T]) DoNothing() *Inserter[T] {
+	o.i.OnDuplicateKey = &Upsert{
+		conflictColumns: o.conflictColumns,
+		doNothing:       true,
 	}
 	return o.i
 }
@@ -37,7 +67,11 @@ type Inserter[T any] struct {
 	values         []*T
 	columns        []string
 	OnDuplicateKey *Upsert
-	sess           Session
+	// batchSize 大于 0 时每条 INSERT 语句最多插入 batchSize 行
+	batchSize int
+	sess      Session
+	// now Exec 填充自动时间字段使用的时间，upsert 更新的自动时间字段使用同一个时间
+	now time.Time
 }
 
 func NewInserter[T any](sess Session) *Inserter[T] {
@@ -57,6 +91,17 @@ func (i *Inserter[T]) onDuplicateKey() *UpsertBuilder[T] {
 	}
 }
 
+// OnConflict 处理唯一键冲突，cols 是冲突的列
+// MySQL 忽略 cols，按照任意唯一键冲突处理
+// PostgreSQL 的 DoUpdate 必须指定 cols，否则返回 ErrMissingConflictColumns
+// 有租户字段的模型只支持 DoNothing，冲突的行可能属于其它租户
+func (i *Inserter[T]) OnConflict(cols ...string) *UpsertBuilder[T] {
+	return &UpsertBuilder[T]{
+		i:               i,
+		conflictColumns: cols,
+	}
+}
+
 // Values 指定插入的数据
 func (i *Inserter[T]) Values(vals ...*T) *Inserter[T] {
 	i.values = vals
@@ -67,12 +112,27 @@ func (i *Inserter[T]) Columns(cols ...string) *Inserter[T] {
 	i.columns = cols
 	return i
 }
+
+// BatchSize 数据超过 n 行时拆分成多条 INSERT 语句，在同一个事务中执行
+// Session 本身是 Tx 时使用这个事务，否则开启一个新的事务
+// Build 不受影响，依旧构造一条语句
+func (i *Inserter[T]) BatchSize(n int) *Inserter[T] {
+	i.batchSize = n
+	return i
+}
+
+// Build 在副本上构造 SQL，不会修改 Inserter 本身和 Values 传入的实体，可以重复调用
+// 自动时间、租户和盲索引字段在实体的副本上填充，Exec 才会写回实体
 func (i *Inserter[T]) Build() (*Query, error) {
+	b := *i
+	return b.build()
+}
+
+func (i *Inserter[T]) build() (*Query, error) {
 	if len(i.values) == 0 {
 		return nil, errs.ErrInsertZeroRow
 	}
 
-	i.sb.WriteString("INSERT INTO ")
 	if i.model == nil {
 		m, err := i.r.Get(i.values[0])
 		i.model = m
@@ -81,19 +141,57 @@ func (i *Inserter[T]) Build() (*Query, error) {
 		}
 	}
 
-	i.quote(i.model.TableName)
+	if err := i.checkTenant(); err != nil {
+		return nil, err
+	}
+	// 冲突的行可能属于其它租户，更新会覆盖其它租户的数据
+	if i.model.TenantField != nil && i.OnDuplicateKey != nil && !i.OnDuplicateKey.doNothing {
+		return nil, errs.ErrTenantUpsert
+	}
+
+	i.dialect.buildInsert(&i.builder, i.OnDuplicateKey)
+	i.quoteTable(i.model.TableName)
 	// 指定列的顺序
 	i.sb.WriteString(" (")
 
 	fields := i.model.Fields
 	if len(i.columns) > 0 {
 		fields = make([]*model.Field, 0, len(i.columns))
+		specified := make(map[string]struct{}, len(i.columns))
 		for _, fd := range i.columns {
 			fdMeta, ok := i.model.FieldMap[fd]
 			if !ok {
 				return nil, errs.NewErrUnknownField(fd)
 			}
 			fields = append(fields, fdMeta)
+			specified[fd] = struct{}{}
+		}
+		// 自动时间字段即便没有指定也要插入
+		for _, fdMeta := range i.model.Fields {
+			if _, ok := specified[fdMeta.GoName]; ok {
+				continue
+			}
+			if fdMeta.AutoCreateTime || fdMeta.AutoUpdateTime {
+				fields = append(fields, fdMeta)
+				specified[fdMeta.GoName] = struct{}{}
+			}
+		}
+		// 租户字段即便没有指定也要插入
+		if tfd := i.model.TenantField; tfd != nil {
+			if _, ok := specified[tfd.GoName]; !ok {
+				fields = append(fields, tfd)
+				specified[tfd.GoName] = struct{}{}
+			}
+		}
+		// 插入加密字段时同时插入盲索引
+		for _, fdMeta := range fields {
+			if fdMeta.BlindIndex == nil {
+				continue
+			}
+			if _, ok := specified[fdMeta.BlindIndex.GoName]; !ok {
+				fields = append(fields, fdMeta.BlindIndex)
+				specified[fdMeta.BlindIndex.GoName] = struct{}{}
+			}
 		}
 	}
 	for idx, field := range fields {
@@ -108,12 +206,22 @@ func (i *Inserter[T]) Build() (*Query, error) {
 	i.sb.WriteString(" VALUES ")
 
 	i.args = make([]any, 0, len(i.values)*len(fields))
+	i.argCols = make([]string, 0, len(i.values)*len(fields))
+	now := i.now
+	if now.IsZero() {
+		now = i.clock()
+	}
 	for index, v := range i.values {
 		if index > 0 {
 			i.sb.WriteString(",")
 		}
+		// 在副本上填充，Build 不修改传入的实体
+		cp := *v
+		if err := i.fill(&cp, now); err != nil {
+			return nil, err
+		}
 		i.sb.WriteString("(")
-		val := i.creator(i.model, v)
+		val := i.creator(i.model, &cp)
 		for idx, field := range fields {
 			if idx > 0 {
 				i.sb.WriteString(",")
@@ -123,24 +231,54 @@ func (i *Inserter[T]) Build() (*Query, error) {
 			if err != nil {
 				return nil, err
 			}
-			i.addArg(arg)
+			i.addColumnArg(field.ColName, arg)
 		}
 		i.sb.WriteString(")")
 	}
 	if i.OnDuplicateKey != nil {
-		err := i.dialect.buildUpsert(&i.builder, i.OnDuplicateKey)
+		upsert := *i.OnDuplicateKey
+		if !upsert.doNothing {
+			assigns, err := blindIndexAssigns(i.model, upsert.assigns)
+			if err != nil {
+				return nil, err
+			}
+			upsert.assigns = autoUpdateAssigns(i.model, assigns, now)
+		}
+		err := i.dialect.buildUpsert(&i.builder, &upsert)
 		if err != nil {
 			return nil, err
 		}
 	}
 	i.sb.WriteByte(';')
-	return &Query{
-		SQL:  i.sb.String(),
-		Args: i.args,
-	}, nil
+	return i.query(), nil
+}
+
+// fill 填充自动时间、租户和盲索引字段
+func (i *Inserter[T]) fill(v *T, now time.Time) error {
+	fillAutoTime(i.model, v, now)
+	if err := fillTenant(i.model, v, i.tenant); err != nil {
+		return err
+	}
+	return fillBlindIndex(i.model, v)
+}
+
+// Clone 复制一个 Inserter，副本和原来的 Inserter 互不影响，但是共享 Values 传入的实体
+func (i *Inserter[T]) Clone() *Inserter[T] {
+	res := *i
+	res.values = slices.Clone(i.values)
+	res.columns = slices.Clone(i.columns)
+	return &res
 }
 
 func (i *Inserter[T]) Exec(ctx context.Context) Result {
+	// 在副本上执行，Inserter 本身不会被修改
+	cp := *i
+	i = &cp
+	if len(i.values) == 0 {
+		return Result{
+			err: errs.ErrInsertZeroRow,
+		}
+	}
 	var err error
 	i.model, err = i.r.Get(i.values[0])
 	if err != nil {
@@ -148,17 +286,129 @@ func (i *Inserter[T]) Exec(ctx context.Context) Result {
 			err: err,
 		}
 	}
-	res := exec(ctx, i.sess, i.core, &QueryContext{
+	if err = i.bindTenant(ctx); err != nil {
+		return Result{
+			err: err,
+		}
+	}
+	for _, v := range i.values {
+		if err = beforeInsert(ctx, i.sess, v); err != nil {
+			return Result{
+				err: err,
+			}
+		}
+	}
+	// 在进入中间件之前写回实体，中间件和 AfterInsert 看到的是填充之后的实体
+	i.now = i.clock()
+	for _, v := range i.values {
+		if err = i.fill(v, i.now); err != nil {
+			return Result{
+				err: err,
+			}
+		}
+	}
+	var r Result
+	if i.batchSize > 0 && len(i.values) > i.batchSize {
+		r = i.execBatches(ctx)
+	} else {
+		r = i.execOnce(ctx, i.sess)
+	}
+	if r.err != nil {
+		return r
+	}
+	for _, v := range i.values {
+		if err = afterInsert(ctx, i.sess, v); err != nil {
+			return Result{
+				err: err,
+				res: r.res,
+			}
+		}
+	}
+	return r
+}
+
+func (i *Inserter[T]) execOnce(ctx context.Context, sess Session) Result {
+	res := exec(ctx, sess, i.core, &QueryContext{
 		Type:    "INSERT",
 		Builder: i,
 		Model:   i.model,
 	})
-
+	r := Result{
+		err: res.Err,
+	}
 	if res.Result != nil {
-		return res.Result.(Result)
+		r = res.Result.(Result)
 	}
-	return Result{
-		err: res.Err,
-		res: res.Result.(sql.Result),
+	return r
+}
+
+type txBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error)
+}
+
+// execBatches 分批执行，任意一批失败时回滚所有批次
+func (i *Inserter[T]) execBatches(ctx context.Context) Result {
+	run := func(sess Session) (batchResult, error) {
+		res := make(batchResult, 0, (len(i.values)+i.batchSize-1)/i.batchSize)
+		for start := 0; start < len(i.values); start += i.batchSize {
+			batch := *i
+			batch.values = i.values[start:min(start+i.batchSize, len(i.values))]
+			r := batch.execOnce(ctx, sess)
+			if r.err != nil {
+				return nil, r.err
+			}
+			res = append(res, r.res)
+		}
+		return res, nil
+	}
+	if _, ok := i.sess.(*Tx); ok {
+		res, err := run(i.sess)
+		return Result{err: err, res: res}
+	}
+	beginner, ok := i.sess.(txBeginner)
+	if !ok {
+		res, err := run(i.sess)
+		return Result{err: err, res: res}
+	}
+	tx, err := beginner.BeginTx(ctx, nil)
+	if err != nil {
+		return Result{err: err}
+	}
+	res, err := run(tx)
+	if err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			err = errs.NewErrFailedToRollback(err, rbErr, false)
+		}
+		return Result{err: err}
+	}
+	if err = tx.Commit(); err != nil {
+		return Result{err: err}
+	}
+	return Result{res: res}
+}
+
+// batchResult 汇总多条语句的结果
+// LastInsertId 返回第一批的结果，RowsAffected 返回总和
+type batchResult []sql.Result
+
+func (b batchResult) LastInsertId() (int64, error) {
+	if len(b) == 0 || b[0] == nil {
+		return 0, nil
+	}
+	return b[0].LastInsertId()
+}
+
+func (b batchResult) RowsAffected() (int64, error) {
+	var total int64
+	for _, r := range b {
+		if r == nil {
+			continue
+		}
+		n, err := r.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		total += n
 	}
+	return total, nil
 }
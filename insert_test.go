@@ -9,7 +9,10 @@ import (
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"regexp"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestInserter_SQLite_upsert(t *testing.T) {
@@ -33,8 +36,9 @@ func TestInserter_SQLite_upsert(t *testing.T) {
 			}).onDuplicateKey().ConflictColumns("FirstName", "Age").Update(Assign("FirstName", "J"), Assign("Age", 19)),
 			wantQuery: &Query{
 				SQL: "INSERT INTO `test_model` (`id`,`first_name`,`age`,`last_name`) VALUES (?,?,?,?)" +
-					" ON CONFLICT (`first_name`,`age`) DO UPDATE SET `first_name`=?,`age`=?;",
-				Args: []any{int64(1), "a", int8(18), &sql.NullString{String: "ndras", Valid: true}, "J", 19},
+					" ON CONFLICT (`first_name`,`age`) DO UPDATE SET `first_name` = ?,`age` = ?;",
+				Args:       []any{int64(1), "a", int8(18), &sql.NullString{String: "ndras", Valid: true}, "J", 19},
+				ArgColumns: []string{"id", "first_name", "age", "last_name", "first_name", "age"},
 			},
 		},
 		{
@@ -49,9 +53,10 @@ func TestInserter_SQLite_upsert(t *testing.T) {
 			}).onDuplicateKey().ConflictColumns("FirstName", "Age").Update(C("FirstName"), C("Age")),
 			wantQuery: &Query{
 				SQL: "INSERT INTO `test_model` (`id`,`first_name`) VALUES (?,?),(?,?)" +
-					" ON CONFLICT (`first_name`,`age`) DO UPDATE SET `first_name`=EXCLUDED.`first_name`,`age`=EXCLUDED.`age`;",
+					" ON CONFLICT (`first_name`,`age`) DO UPDATE SET `first_name` = EXCLUDED.`first_name`,`age` = EXCLUDED.`age`;",
 				Args: []any{int64(1), "a",
 					int64(2), "b"},
+				ArgColumns: []string{"id", "first_name", "id", "first_name"},
 			},
 		},
 	}
@@ -86,8 +91,9 @@ func TestInserter_Build(t *testing.T) {
 				},
 			}),
 			wantQuery: &Query{
-				SQL:  "INSERT INTO `test_model` (`id`,`first_name`,`age`,`last_name`) VALUES (?,?,?,?);",
-				Args: []any{int64(1), "a", int8(18), &sql.NullString{String: "ndras", Valid: true}},
+				SQL:        "INSERT INTO `test_model` (`id`,`first_name`,`age`,`last_name`) VALUES (?,?,?,?);",
+				Args:       []any{int64(1), "a", int8(18), &sql.NullString{String: "ndras", Valid: true}},
+				ArgColumns: []string{"id", "first_name", "age", "last_name"},
 			},
 		},
 		{
@@ -113,6 +119,7 @@ func TestInserter_Build(t *testing.T) {
 				SQL: "INSERT INTO `test_model` (`id`,`first_name`,`age`,`last_name`) VALUES (?,?,?,?),(?,?,?,?);",
 				Args: []any{int64(1), "a", int8(18), &sql.NullString{String: "ndras", Valid: true},
 					int64(2), "b", int8(28), &sql.NullString{String: "ndras", Valid: true}},
+				ArgColumns: []string{"id", "first_name", "age", "last_name", "id", "first_name", "age", "last_name"},
 			},
 		},
 		{
@@ -134,6 +141,7 @@ func TestInserter_Build(t *testing.T) {
 				SQL: "INSERT INTO `test_model` (`id`,`first_name`) VALUES (?,?),(?,?);",
 				Args: []any{int64(1), "a",
 					int64(2), "b"},
+				ArgColumns: []string{"id", "first_name", "id", "first_name"},
 			},
 		},
 		{
@@ -149,8 +157,9 @@ func TestInserter_Build(t *testing.T) {
 			}).onDuplicateKey().Update(Assign("FirstName", "J"), Assign("Age", 19)),
 			wantQuery: &Query{
 				SQL: "INSERT INTO `test_model` (`id`,`first_name`,`age`,`last_name`) VALUES (?,?,?,?)" +
-					" ON DUPLICATE KEY UPDATE `first_name`=?,`age`=?;",
-				Args: []any{int64(1), "a", int8(18), &sql.NullString{String: "ndras", Valid: true}, "J", 19},
+					" ON DUPLICATE KEY UPDATE `first_name` = ?,`age` = ?;",
+				Args:       []any{int64(1), "a", int8(18), &sql.NullString{String: "ndras", Valid: true}, "J", 19},
+				ArgColumns: []string{"id", "first_name", "age", "last_name", "first_name", "age"},
 			},
 		},
 		{
@@ -165,9 +174,10 @@ func TestInserter_Build(t *testing.T) {
 			}).onDuplicateKey().Update(C("FirstName"), C("Age")),
 			wantQuery: &Query{
 				SQL: "INSERT INTO `test_model` (`id`,`first_name`) VALUES (?,?),(?,?)" +
-					" ON DUPLICATE KEY UPDATE `first_name`=VALUES(`first_name`),`age`=VALUES(`age`);",
+					" ON DUPLICATE KEY UPDATE `first_name` = VALUES(`first_name`),`age` = VALUES(`age`);",
 				Args: []any{int64(1), "a",
 					int64(2), "b"},
+				ArgColumns: []string{"id", "first_name", "id", "first_name"},
 			},
 		},
 	}
@@ -183,6 +193,137 @@ func TestInserter_Build(t *testing.T) {
 		})
 	}
 }
+func TestInserter_AutoTime(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	db := memoryDB(t, DBWithDialect(DialectMySQL), DBWithClock(func() time.Time {
+		return now
+	}))
+	createdAt := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name       string
+		q          QueryBuilder
+		wantErr    error
+		wantQuery  *Query
+		wantEntity *AutoTimeModel
+	}{
+		{
+			name: "fill zero fields",
+			q:    NewInserter[AutoTimeModel](db).Values(&AutoTimeModel{Id: 1}),
+			wantQuery: &Query{
+				SQL:        "INSERT INTO `auto_time_model` (`id`,`created_at`,`updated_at`,`created_ms`,`updated_null`) VALUES (?,?,?,?,?);",
+				Args:       []any{int64(1), now, now.Unix(), now.UnixMilli(), sql.NullTime{Time: now, Valid: true}},
+				ArgColumns: []string{"id", "created_at", "updated_at", "created_ms", "updated_null"},
+			},
+			// Build 不修改传入的实体
+			wantEntity: &AutoTimeModel{Id: 1},
+		},
+		{
+			name: "keep non-zero fields",
+			q:    NewInserter[AutoTimeModel](db).Values(&AutoTimeModel{Id: 1, CreatedAt: createdAt}),
+			wantQuery: &Query{
+				SQL:        "INSERT INTO `auto_time_model` (`id`,`created_at`,`updated_at`,`created_ms`,`updated_null`) VALUES (?,?,?,?,?);",
+				Args:       []any{int64(1), createdAt, now.Unix(), now.UnixMilli(), sql.NullTime{Time: now, Valid: true}},
+				ArgColumns: []string{"id", "created_at", "updated_at", "created_ms", "updated_null"},
+			},
+		},
+		{
+			name: "partial columns",
+			q:    NewInserter[AutoTimeModel](db).Columns("Id", "UpdatedAt").Values(&AutoTimeModel{Id: 1}),
+			wantQuery: &Query{
+				SQL:        "INSERT INTO `auto_time_model` (`id`,`updated_at`,`created_at`,`created_ms`,`updated_null`) VALUES (?,?,?,?,?);",
+				Args:       []any{int64(1), now.Unix(), now, now.UnixMilli(), sql.NullTime{Time: now, Valid: true}},
+				ArgColumns: []string{"id", "updated_at", "created_at", "created_ms", "updated_null"},
+			},
+		},
+		{
+			name: "upsert",
+			q: NewInserter[AutoTimeModel](db).Columns("Id").Values(&AutoTimeModel{Id: 1}).
+				onDuplicateKey().Update(C("Id")),
+			wantQuery: &Query{
+				SQL: "INSERT INTO `auto_time_model` (`id`,`created_at`,`updated_at`,`created_ms`,`updated_null`) VALUES (?,?,?,?,?)" +
+					" ON DUPLICATE KEY UPDATE `id` = VALUES(`id`),`updated_at` = ?,`updated_null` = ?;",
+				Args: []any{int64(1), now, now.Unix(), now.UnixMilli(), sql.NullTime{Time: now, Valid: true},
+					now.Unix(), sql.NullTime{Time: now, Valid: true}},
+				ArgColumns: []string{"id", "created_at", "updated_at", "created_ms", "updated_null", "updated_at", "updated_null"},
+			},
+		},
+		{
+			name: "upsert assigned",
+			q: NewInserter[AutoTimeModel](db).Columns("Id").Values(&AutoTimeModel{Id: 1}).
+				onDuplicateKey().Update(C("UpdatedAt"), Assign("UpdatedNull", nil)),
+			wantQuery: &Query{
+				SQL: "INSERT INTO `auto_time_model` (`id`,`created_at`,`updated_at`,`created_ms`,`updated_null`) VALUES (?,?,?,?,?)" +
+					" ON DUPLICATE KEY UPDATE `updated_at` = VALUES(`updated_at`),`updated_null` = ?;",
+				Args:       []any{int64(1), now, now.Unix(), now.UnixMilli(), sql.NullTime{Time: now, Valid: true}, nil},
+				ArgColumns: []string{"id", "created_at", "updated_at", "created_ms", "updated_null", "updated_null"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, q)
+			if tc.wantEntity != nil {
+				assert.Equal(t, tc.wantEntity, tc.q.(*Inserter[AutoTimeModel]).values[0])
+			}
+		})
+	}
+
+	// Exec 把自动时间写回实体
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	mockORM, err := OpenDB(mockDB, DBWithClock(func() time.Time {
+		return now
+	}))
+	require.NoError(t, err)
+	mock.ExpectExec("INSERT INTO .*").
+		WithArgs(int64(1), now, now.Unix(), now.UnixMilli(), sql.NullTime{Time: now, Valid: true}).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	entity := &AutoTimeModel{Id: 1}
+	require.NoError(t, NewInserter[AutoTimeModel](mockORM).Values(entity).Exec(context.Background()).Err())
+	assert.Equal(t, &AutoTimeModel{
+		Id:          1,
+		CreatedAt:   now,
+		UpdatedAt:   now.Unix(),
+		CreatedMs:   now.UnixMilli(),
+		UpdatedNull: sql.NullTime{Time: now, Valid: true},
+	}, entity)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestInserter_Serializer(t *testing.T) {
+	db := memoryDB(t, DBWithDialect(DialectSQLite))
+	q, err := NewInserter[SerializerModel](db).Values(&SerializerModel{
+		Id:    1,
+		Attrs: map[string]string{"a": "b"},
+	}).onDuplicateKey().ConflictColumns("Id").Update(Assign("Tags", []string{"x", "y"})).Build()
+	require.NoError(t, err)
+	assert.Equal(t, &Query{
+		SQL: "INSERT INTO `serializer_model` (`id`,`attrs`,`tags`) VALUES (?,?,?)" +
+			" ON CONFLICT (`id`) DO UPDATE SET `tags` = ?;",
+		Args:       []any{int64(1), []byte(`{"a":"b"}`), nil, []byte("x,y")},
+		ArgColumns: []string{"id", "attrs", "tags", "tags"},
+	}, q)
+}
+
+type SerializerModel struct {
+	Id    int64
+	Attrs map[string]string `orm:"serializer:json"`
+	Tags  []string          `orm:"serializer:csv"`
+}
+
+type AutoTimeModel struct {
+	Id          int64
+	CreatedAt   time.Time    `orm:"auto_create_time"`
+	UpdatedAt   int64        `orm:"auto_update_time"`
+	CreatedMs   int64        `orm:"auto_create_time:milli"`
+	UpdatedNull sql.NullTime `orm:"auto_update_time"`
+}
+
 func TestInserter_Exec(t *testing.T) {
 	mockDB, mock, err := sqlmock.New()
 	require.NoError(t, err)
@@ -240,3 +381,290 @@ func TestInserter_Exec(t *testing.T) {
 		})
 	}
 }
+
+func TestInserter_Clone(t *testing.T) {
+	db := memoryDB(t)
+	base := NewInserter[TestModel](db).Columns("Id", "FirstName")
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ins := base.Clone().Values(&TestModel{Id: int64(i), FirstName: "Tom"})
+			want := &Query{
+				SQL:        "INSERT INTO `test_model` (`id`,`first_name`) VALUES (?,?);",
+				Args:       []any{int64(i), "Tom"},
+				ArgColumns: []string{"id", "first_name"},
+			}
+			for j := 0; j < 2; j++ {
+				q, err := ins.Build()
+				assert.NoError(t, err)
+				assert.Equal(t, want, q)
+			}
+		}(i)
+	}
+	wg.Wait()
+	_, err := base.Build()
+	assert.Equal(t, errs.ErrInsertZeroRow, err)
+
+	// 多个 goroutine 同时构造同一个带自动时间字段的实体，Build 不写实体
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	clockDB := memoryDB(t, DBWithClock(func() time.Time {
+		return now
+	}))
+	entity := &AutoTimeModel{Id: 1}
+	autoBase := NewInserter[AutoTimeModel](clockDB).Values(entity)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q, err := autoBase.Clone().Build()
+			assert.NoError(t, err)
+			assert.Equal(t, []any{int64(1), now, now.Unix(), now.UnixMilli(), sql.NullTime{Time: now, Valid: true}}, q.Args)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, &AutoTimeModel{Id: 1}, entity)
+}
+
+func TestInserter_BatchSize(t *testing.T) {
+	vals := func(n int) []*TestModel {
+		res := make([]*TestModel, 0, n)
+		for i := 1; i <= n; i++ {
+			res = append(res, &TestModel{Id: int64(i)})
+		}
+		return res
+	}
+	testCases := []struct {
+		name         string
+		mock         func(mock sqlmock.Sqlmock)
+		exec         func(db *DB) Result
+		wantErr      error
+		wantAffected int64
+		wantId       int64
+	}{
+		{
+			name: "batches in transaction",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `test_model` (`id`,`first_name`,`age`,`last_name`) VALUES (?,?,?,?),(?,?,?,?);")).
+					WithArgs(int64(1), "", int8(0), nil, int64(2), "", int8(0), nil).
+					WillReturnResult(sqlmock.NewResult(1, 2))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `test_model` (`id`,`first_name`,`age`,`last_name`) VALUES (?,?,?,?),(?,?,?,?);")).
+					WillReturnResult(sqlmock.NewResult(3, 2))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `test_model` (`id`,`first_name`,`age`,`last_name`) VALUES (?,?,?,?);")).
+					WillReturnResult(sqlmock.NewResult(5, 1))
+				mock.ExpectCommit()
+			},
+			exec: func(db *DB) Result {
+				return NewInserter[TestModel](db).BatchSize(2).Values(vals(5)...).Exec(context.Background())
+			},
+			wantAffected: 5,
+			wantId:       1,
+		},
+		{
+			name: "not exceed batch size",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("INSERT INTO .*").WillReturnResult(sqlmock.NewResult(1, 2))
+			},
+			exec: func(db *DB) Result {
+				return NewInserter[TestModel](db).BatchSize(2).Values(vals(2)...).Exec(context.Background())
+			},
+			wantAffected: 2,
+			wantId:       1,
+		},
+		{
+			name: "rollback",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO .*").WillReturnResult(sqlmock.NewResult(1, 2))
+				mock.ExpectExec("INSERT INTO .*").WillReturnError(errors.New("duplicate"))
+				mock.ExpectRollback()
+			},
+			exec: func(db *DB) Result {
+				return NewInserter[TestModel](db).BatchSize(2).Values(vals(3)...).Exec(context.Background())
+			},
+			wantErr: errors.New("duplicate"),
+		},
+		{
+			name: "tx session",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO .*").WillReturnResult(sqlmock.NewResult(1, 2))
+				mock.ExpectExec("INSERT INTO .*").WillReturnResult(sqlmock.NewResult(3, 1))
+				mock.ExpectCommit()
+			},
+			exec: func(db *DB) Result {
+				tx, err := db.BeginTx(context.Background(), nil)
+				require.NoError(t, err)
+				res := NewInserter[TestModel](tx).BatchSize(2).Values(vals(3)...).Exec(context.Background())
+				require.NoError(t, tx.Commit())
+				return res
+			},
+			wantAffected: 3,
+			wantId:       1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			db, err := OpenDB(mockDB)
+			require.NoError(t, err)
+			tc.mock(mock)
+			res := tc.exec(db)
+			assert.Equal(t, tc.wantErr, res.Err())
+			require.NoError(t, mock.ExpectationsWereMet())
+			if res.Err() != nil {
+				return
+			}
+			affected, err := res.RowsAffected()
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantAffected, affected)
+			id, err := res.LastInsertId()
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantId, id)
+		})
+	}
+}
+
+func TestInserter_OnConflict(t *testing.T) {
+	mysqlDB := memoryDB(t, DBWithDialect(DialectMySQL))
+	sqliteDB := memoryDB(t, DBWithDialect(DialectSQLite))
+	pgDB := memoryDB(t, DBWithDialect(DialectPostgreSQL))
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantErr   error
+		wantQuery *Query
+	}{
+		{
+			name: "mysql do nothing",
+			q:    NewInserter[TestModel](mysqlDB).Columns("Id", "Age").Values(&TestModel{Id: 1, Age: 18}).OnConflict("Id").DoNothing(),
+			wantQuery: &Query{
+				SQL:        "INSERT IGNORE INTO `test_model` (`id`,`age`) VALUES (?,?);",
+				Args:       []any{int64(1), int8(18)},
+				ArgColumns: []string{"id", "age"},
+			},
+		},
+		{
+			name: "mysql do update excluded",
+			q: NewInserter[TestModel](mysqlDB).Columns("Id", "Age").Values(&TestModel{Id: 1, Age: 18}).
+				OnConflict().DoUpdate(Assign("Age", C("Age").Add(Excluded("Age")))),
+			wantQuery: &Query{
+				SQL:        "INSERT INTO `test_model` (`id`,`age`) VALUES (?,?) ON DUPLICATE KEY UPDATE `age` = `age` + VALUES(`age`);",
+				Args:       []any{int64(1), int8(18)},
+				ArgColumns: []string{"id", "age"},
+			},
+		},
+		{
+			name: "mysql where",
+			q: NewInserter[TestModel](mysqlDB).Columns("Id", "Age").Values(&TestModel{Id: 1, Age: 18}).
+				OnConflict().Where(C("Age").Lt(Excluded("Age"))).DoUpdate(C("Age")),
+			wantErr: errs.ErrUnsupportedUpsertWhere,
+		},
+		{
+			name: "sqlite do nothing",
+			q:    NewInserter[TestModel](sqliteDB).Columns("Id", "Age").Values(&TestModel{Id: 1, Age: 18}).OnConflict("Id").DoNothing(),
+			wantQuery: &Query{
+				SQL:        "INSERT INTO `test_model` (`id`,`age`) VALUES (?,?) ON CONFLICT (`id`) DO NOTHING;",
+				Args:       []any{int64(1), int8(18)},
+				ArgColumns: []string{"id", "age"},
+			},
+		},
+		{
+			name: "postgres do nothing without columns",
+			q:    NewInserter[TestModel](pgDB).Columns("Id", "Age").Values(&TestModel{Id: 1, Age: 18}).OnConflict().DoNothing(),
+			wantQuery: &Query{
+				SQL:        `INSERT INTO "test_model" ("id","age") VALUES (?,?) ON CONFLICT DO NOTHING;`,
+				Args:       []any{int64(1), int8(18)},
+				ArgColumns: []string{"id", "age"},
+			},
+		},
+		{
+			name: "postgres do update where",
+			q: NewInserter[TestModel](pgDB).Columns("Id", "Age").Values(&TestModel{Id: 1, Age: 18}).
+				OnConflict("Id").Where(C("Age").Lt(Excluded("Age"))).
+				DoUpdate(Assign("Age", Excluded("Age").Add(1)), Assign("FirstName", "Tom")),
+			wantQuery: &Query{
+				SQL: `INSERT INTO "test_model" ("id","age") VALUES (?,?) ON CONFLICT ("id")` +
+					` DO UPDATE SET "age" = EXCLUDED."age" + ?,"first_name" = ? WHERE "test_model"."age" < EXCLUDED."age";`,
+				Args:       []any{int64(1), int8(18), 1, "Tom"},
+				ArgColumns: []string{"id", "age", "age", "first_name"},
+			},
+		},
+		{
+			name: "postgres do update existing row",
+			q: NewInserter[TestModel](pgDB).Columns("Id", "Age").Values(&TestModel{Id: 1, Age: 18}).
+				OnConflict("Id").DoUpdate(Assign("Age", C("Age").Add(Excluded("Age")))),
+			wantQuery: &Query{
+				SQL: `INSERT INTO "test_model" ("id","age") VALUES (?,?) ON CONFLICT ("id")` +
+					` DO UPDATE SET "age" = "test_model"."age" + EXCLUDED."age";`,
+				Args:       []any{int64(1), int8(18)},
+				ArgColumns: []string{"id", "age"},
+			},
+		},
+		{
+			name: "postgres do update without columns",
+			q: NewInserter[TestModel](pgDB).Columns("Id", "Age").Values(&TestModel{Id: 1, Age: 18}).
+				OnConflict().DoUpdate(C("Age")),
+			wantErr: errs.ErrMissingConflictColumns,
+		},
+		{
+			name: "sqlite do update existing row",
+			q: NewInserter[TestModel](sqliteDB).Columns("Id", "Age").Values(&TestModel{Id: 1, Age: 18}).
+				OnConflict("Id").DoUpdate(Assign("Age", C("Age").Add(Excluded("Age")))),
+			wantQuery: &Query{
+				SQL: "INSERT INTO `test_model` (`id`,`age`) VALUES (?,?) ON CONFLICT (`id`)" +
+					" DO UPDATE SET `age` = `age` + EXCLUDED.`age`;",
+				Args:       []any{int64(1), int8(18)},
+				ArgColumns: []string{"id", "age"},
+			},
+		},
+		{
+			name: "unknown excluded",
+			q: NewInserter[TestModel](pgDB).Values(&TestModel{Id: 1}).
+				OnConflict("Id").DoUpdate(Assign("Age", Excluded("Invalid"))),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+}
+
+func TestInserter_OnConflict_SQLite(t *testing.T) {
+	db, err := Open("sqlite3", "file:"+t.Name()+"?mode=memory&cache=shared", DBWithDialect(DialectSQLite))
+	require.NoError(t, err)
+	ctx := context.Background()
+	err = RawQuery[TestModel](db, "CREATE TABLE test_model (id INTEGER PRIMARY KEY, first_name TEXT, age INTEGER, last_name TEXT)").Exec(ctx).Err()
+	require.NoError(t, err)
+	err = NewInserter[TestModel](db).Values(&TestModel{Id: 1, FirstName: "Tom", Age: 10}).Exec(ctx).Err()
+	require.NoError(t, err)
+
+	affected, err := NewInserter[TestModel](db).Values(&TestModel{Id: 1, FirstName: "Jerry"}).
+		OnConflict("Id").DoNothing().Exec(ctx).RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), affected)
+
+	// 累加准备插入的值
+	err = NewInserter[TestModel](db).Columns("Id", "Age").Values(&TestModel{Id: 1, Age: 5}).
+		OnConflict("Id").DoUpdate(Assign("Age", C("Age").Add(Excluded("Age")))).Exec(ctx).Err()
+	require.NoError(t, err)
+	// 不满足 WHERE 时不更新
+	err = NewInserter[TestModel](db).Columns("Id", "Age").Values(&TestModel{Id: 1, Age: 1}).
+		OnConflict("Id").Where(C("Age").Lt(Excluded("Age"))).DoUpdate(C("Age")).Exec(ctx).Err()
+	require.NoError(t, err)
+
+	res, err := NewSelector[TestModel](db).Where(C("Id").Eq(1)).Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "Tom", res.FirstName)
+	assert.Equal(t, int8(15), res.Age)
+}
@@ -3,6 +3,7 @@ package errs
 import (
 	"errors"
 	"fmt"
+	"reflect"
 )
 
 var (
@@ -10,6 +11,16 @@ var (
 	ErrNoRows           = errors.New("orm: no rows in result set")
 	ErrInsertZeroRow    = errors.New("orm: insert zero row")
 	ErrNoUpdatedColumns = errors.New("orm: no updated columns")
+
+	ErrMultipleSoftDeleteField = errors.New("orm: multiple soft delete fields")
+	ErrMultipleTenantField     = errors.New("orm: multiple tenant fields")
+	ErrMissingTenant           = errors.New("orm: missing tenant in context")
+	ErrUnsupportedUpsertWhere  = errors.New("orm: dialect does not support WHERE in upsert")
+	ErrTenantUpsert            = errors.New("orm: upsert on tenant model may update rows of other tenants")
+	ErrMissingConflictColumns  = errors.New("orm: upsert update requires conflict columns")
+	ErrTenantSchemaTable       = errors.New("orm: table must be a single table name or common table expression with tenant schema")
+	ErrEmptyCase               = errors.New("orm: CASE without WHEN")
+	ErrEmptyCTEName            = errors.New("orm: empty common table expression name")
 )
 
 // NewErrFailedToRollback bizErr 是业务错误，rbErr 是回滚错误，panicked 是是否在回滚时发生 panic
@@ -36,3 +47,31 @@ func NewErrUnsupportedAssignable(assign any) error {
 func NewErrUnsupportedAssignableType(assign any) error {
 	return fmt.Errorf("orm: unsupported assignable type: %s", assign)
 }
+
+func NewErrUnsupportedSoftDeleteType(typ any) error {
+	return fmt.Errorf("orm: unsupported soft delete field type: %v", typ)
+}
+
+func NewErrUnsupportedAutoTimeType(typ any) error {
+	return fmt.Errorf("orm: unsupported auto time field type: %v", typ)
+}
+
+func NewErrUnknownSerializer(name string) error {
+	return fmt.Errorf("orm: unknown serializer: %s", name)
+}
+
+func NewErrEncryptedColumnQuery(name string) error {
+	return fmt.Errorf("orm: encrypted field %s only supports equality query with blind index", name)
+}
+
+func NewErrUnsupportedTenantType(tenant any, typ reflect.Type) error {
+	return fmt.Errorf("orm: tenant %v can not be converted to %s", tenant, typ)
+}
+
+func NewErrDuplicateCTE(name string) error {
+	return fmt.Errorf("orm: duplicate common table expression: %s", name)
+}
+
+func NewErrCTEModelMismatch(name string, anchor string, recursive string) error {
+	return fmt.Errorf("orm: recursive common table expression %s queries %s and %s", name, anchor, recursive)
+}
@@ -0,0 +1,26 @@
+package sqlcomment
+
+import "strings"
+
+// Append 把注释放在末尾的分号之前
+func Append(query, comment string) string {
+	trimmed := strings.TrimRight(query, " \t\n;")
+	return trimmed + " " + comment + query[len(trimmed):]
+}
+
+// Strip 去掉 Append 追加的注释，保留末尾的分号
+// MySQL 的可执行注释 /*! */ 和优化器提示 /*+ */ 会影响语义，不会去掉
+func Strip(query string) string {
+	trimmed := strings.TrimRight(query, " \t\n;")
+	if !strings.HasSuffix(trimmed, "*/") {
+		return query
+	}
+	start := strings.LastIndex(trimmed, "/*")
+	if start < 0 || start+2 > len(trimmed)-2 {
+		return query
+	}
+	if c := trimmed[start+2]; c == '!' || c == '+' {
+		return query
+	}
+	return strings.TrimRight(trimmed[:start], " \t\n") + query[len(trimmed):]
+}
@@ -0,0 +1,61 @@
+package sqlcomment
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAppend(t *testing.T) {
+	assert.Equal(t, "SELECT 1 /*a='b'*/;", Append("SELECT 1;", "/*a='b'*/"))
+	assert.Equal(t, "SELECT 1 /*a='b'*/", Append("SELECT 1", "/*a='b'*/"))
+}
+
+func TestStrip(t *testing.T) {
+	testCases := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{
+			name:  "no comment",
+			query: "SELECT * FROM `user`;",
+			want:  "SELECT * FROM `user`;",
+		},
+		{
+			name:  "comment",
+			query: "SELECT * FROM `user` /*traceparent='00-1-2-01'*/;",
+			want:  "SELECT * FROM `user`;",
+		},
+		{
+			name:  "without semicolon",
+			query: "SELECT * FROM `user` /*app='order'*/",
+			want:  "SELECT * FROM `user`",
+		},
+		{
+			name:  "comment in string",
+			query: "SELECT * FROM `user` WHERE `name` = '/*a*/';",
+			want:  "SELECT * FROM `user` WHERE `name` = '/*a*/';",
+		},
+		{
+			name:  "executable comment",
+			query: "SELECT * FROM `user` /*!50000 FOR UPDATE */;",
+			want:  "SELECT * FROM `user` /*!50000 FOR UPDATE */;",
+		},
+		{
+			name:  "optimizer hint",
+			query: "SELECT * FROM `user` /*+ MAX_EXECUTION_TIME(1000) */;",
+			want:  "SELECT * FROM `user` /*+ MAX_EXECUTION_TIME(1000) */;",
+		},
+		{
+			name:  "only comment end",
+			query: "SELECT 1 */;",
+			want:  "SELECT 1 */;",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, Strip(tc.query))
+			assert.Equal(t, tc.want, Strip(Append(tc.want, "/*a='b'*/")))
+		})
+	}
+}
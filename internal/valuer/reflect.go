@@ -4,12 +4,13 @@ import (
 	"database/sql"
 	"github.com/Andras5014/go-orm/internal/errs"
 	go_orm "github.com/Andras5014/go-orm/model"
+	"github.com/Andras5014/go-orm/serializer"
 	"reflect"
 )
 
 type reflectValue struct {
 	model *go_orm.Model
-	// T的指针
+	// T 的结构体值
 	val reflect.Value
 }
 
@@ -17,18 +18,20 @@ var _ Creator = NewReflectValue
 
 func NewReflectValue(model *go_orm.Model, val any) Value {
 	return reflectValue{
-		val:   reflect.ValueOf(val),
+		val:   reflect.ValueOf(val).Elem(),
 		model: model,
 	}
 }
 func (r reflectValue) Field(name string) (any, error) {
-	// 检测name是否合法
-	//_, ok := r.val.Type().FieldByName(name)
-	//if !ok {
-	//	return nil, errs.NewErrUnknownField(name)
-	//}
-
-	return r.val.FieldByName(name).Interface(), nil
+	fd, ok := r.model.FieldMap[name]
+	if !ok {
+		return nil, errs.NewErrUnknownField(name)
+	}
+	val := r.val.FieldByName(name).Interface()
+	if fd.Serializer != nil {
+		return serializer.Encode(fd.Serializer, val)
+	}
+	return val, nil
 }
 func (r reflectValue) SetColumns(rows *sql.Rows) error {
 	// 拿到 select 出来的列
@@ -44,7 +47,12 @@ func (r reflectValue) SetColumns(rows *sql.Rows) error {
 		if !ok {
 			return errs.NewErrUnknownColumn(c)
 		}
-		val := reflect.New(fd.Typ)
+		typ := fd.Typ
+		if fd.Serializer != nil {
+			// 先读出原始字节，再解码
+			typ = bytesType
+		}
+		val := reflect.New(typ)
 		vals = append(vals, val.Interface())
 		valElems = append(valElems, val.Elem())
 
@@ -59,7 +67,14 @@ func (r reflectValue) SetColumns(rows *sql.Rows) error {
 		if !ok {
 			return errs.NewErrUnknownColumn(c)
 		}
-		tpValueElem.FieldByName(fd.GoName).Set(valElems[i])
+		fdVal := tpValueElem.FieldByName(fd.GoName)
+		if fd.Serializer != nil {
+			if err = decode(fd, valElems[i].Bytes(), fdVal.Addr().Interface()); err != nil {
+				return err
+			}
+			continue
+		}
+		fdVal.Set(valElems[i])
 
 	}
 	return nil
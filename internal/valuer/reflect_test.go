@@ -20,6 +20,62 @@ func TestNewReflectValue(t *testing.T) {
 	testSetColumns(t, NewReflectValue)
 }
 
+type SerializerModel struct {
+	Id      int64
+	Address *Address          `orm:"serializer:json"`
+	Tags    []string          `orm:"serializer:csv"`
+	Attrs   map[string]string `orm:"serializer:json"`
+}
+
+type Address struct {
+	City string
+}
+
+func testField(t *testing.T, creator Creator) {
+	testCases := []struct {
+		name    string
+		entity  any
+		field   string
+		wantVal any
+		wantErr error
+	}{
+		{
+			name:    "normal",
+			entity:  &TestModel{FirstName: "Andras"},
+			field:   "FirstName",
+			wantVal: "Andras",
+		},
+		{
+			name:    "serializer",
+			entity:  &SerializerModel{Address: &Address{City: "sz"}},
+			field:   "Address",
+			wantVal: []byte(`{"City":"sz"}`),
+		},
+		{
+			name:   "serializer nil",
+			entity: &SerializerModel{},
+			field:  "Attrs",
+		},
+	}
+	r := go_orm.NewRegistry()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := r.Get(tc.entity)
+			require.NoError(t, err)
+			val, err := creator(m, tc.entity).Field(tc.field)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantVal, val)
+		})
+	}
+}
+
+func TestReflectValue_Field(t *testing.T) {
+	testField(t, NewReflectValue)
+}
+
 func testSetColumns(t *testing.T, creator Creator) {
 	testCases := []struct {
 		name string
@@ -78,6 +134,20 @@ func testSetColumns(t *testing.T, creator Creator) {
 				FirstName: "Andras",
 			},
 		},
+		{
+			name:   "serializer",
+			entity: &SerializerModel{},
+			rows: func() *sqlmock.Rows {
+				rows := sqlmock.NewRows([]string{"id", "address", "tags", "attrs"})
+				rows.AddRow(1, `{"City":"sz"}`, []byte("a,b"), nil)
+				return rows
+			}(),
+			wantEntity: &SerializerModel{
+				Id:      1,
+				Address: &Address{City: "sz"},
+				Tags:    []string{"a", "b"},
+			},
+		},
 	}
 	r := go_orm.NewRegistry()
 	mockDB, mock, err := sqlmock.New()
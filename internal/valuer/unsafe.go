@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"github.com/Andras5014/go-orm/internal/errs"
 	go_orm "github.com/Andras5014/go-orm/model"
+	"github.com/Andras5014/go-orm/serializer"
 	"reflect"
 	"unsafe"
 )
@@ -30,6 +31,9 @@ func (u unsafeValue) Field(name string) (any, error) {
 	}
 	fdAddress := unsafe.Pointer(uintptr(u.address) + fd.Offset)
 	val := reflect.NewAt(fd.Typ, fdAddress)
+	if fd.Serializer != nil {
+		return serializer.Encode(fd.Serializer, val.Elem().Interface())
+	}
 	return val.Elem().Interface(), nil
 }
 func (u unsafeValue) SetColumns(rows *sql.Rows) error {
@@ -39,11 +43,22 @@ func (u unsafeValue) SetColumns(rows *sql.Rows) error {
 		return err
 	}
 	var vals []any
-	for _, c := range cs {
+	// 需要解码的列，下标 -> 原始字节
+	var raws map[int]*[]byte
+	for i, c := range cs {
 		fd, ok := u.model.ColumnMap[c]
 		if !ok {
 			return errs.NewErrUnknownColumn(c)
 		}
+		if fd.Serializer != nil {
+			if raws == nil {
+				raws = make(map[int]*[]byte, len(cs))
+			}
+			raw := new([]byte)
+			raws[i] = raw
+			vals = append(vals, raw)
+			continue
+		}
 		// 计算字段地址
 		// 起始地址+字段偏移量
 		fdAddress := unsafe.Pointer(uintptr(u.address) + fd.Offset)
@@ -54,5 +69,12 @@ func (u unsafeValue) SetColumns(rows *sql.Rows) error {
 	if err != nil {
 		return err
 	}
-	return err
+	for i, raw := range raws {
+		fd := u.model.ColumnMap[cs[i]]
+		fdAddress := unsafe.Pointer(uintptr(u.address) + fd.Offset)
+		if err = decode(fd, *raw, reflect.NewAt(fd.Typ, fdAddress).Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
 }
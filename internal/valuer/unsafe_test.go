@@ -7,3 +7,7 @@ import (
 func Test_unsafeValue_SetColumns(t *testing.T) {
 	testSetColumns(t, NewUnsafeValue)
 }
+
+func Test_unsafeValue_Field(t *testing.T) {
+	testField(t, NewUnsafeValue)
+}
@@ -3,6 +3,7 @@ package valuer
 import (
 	"database/sql"
 	go_orm "github.com/Andras5014/go-orm/model"
+	"reflect"
 )
 
 type Value interface {
@@ -11,3 +12,13 @@ type Value interface {
 }
 
 type Creator func(model *go_orm.Model, entity any) Value
+
+var bytesType = reflect.TypeOf([]byte(nil))
+
+// decode 解码使用了 Serializer 的列，NULL 保持字段的零值
+func decode(fd *go_orm.Field, data []byte, ptr any) error {
+	if data == nil {
+		return nil
+	}
+	return fd.Serializer.Deserialize(data, ptr)
+}
@@ -10,13 +10,41 @@ type QueryContext struct {
 	Type string
 	// 查询语句
 	Builder QueryBuilder
+	// Multi 为 true 表示查询多行，对应 Selector.GetMulti
+	Multi bool
 
 	Model *model.Model
+	// Session 执行查询的 DB 或者 Tx，中间件可以在同一个事务中执行额外的查询
+	Session Session
+	// Dialect 执行查询的 DB 使用的方言
+	Dialect Dialect
+	// Tenant context 中的租户，没有租户时为 nil
+	// 使用 TenantDB 时不同租户执行相同的 SQL 查询的是不同的数据库
+	Tenant any
+
+	query    *Query
+	queryErr error
+}
+
+// Query 返回 Builder 构造的查询，同一个 QueryContext 只会调用一次 Build，
+// 中间件和最终执行查询的 handler 共享同一个结果，所以中间件可以直接修改返回的 Query，例如追加注释
+func (qc *QueryContext) Query() (*Query, error) {
+	if qc.query == nil && qc.queryErr == nil {
+		qc.query, qc.queryErr = qc.Builder.Build()
+	}
+	return qc.query, qc.queryErr
+}
+
+// SetQuery 替换整个查询，最终执行查询的 handler 执行的就是这个查询
+func (qc *QueryContext) SetQuery(q *Query) {
+	qc.query = q
+	qc.queryErr = nil
 }
 
 type QueryResult struct {
 	// Result 查询结果在不同查询类型下不同
 	// select: *T or []*T
+	// insert, update, delete: Result
 	Result any
 	// Err 查询错误
 	Err error
@@ -25,3 +53,22 @@ type QueryResult struct {
 type Handler func(ctx context.Context, qc *QueryContext) *QueryResult
 
 type Middleware func(next Handler) Handler
+
+// Rewrite 返回一个改写查询的中间件，例如加上优化器提示或者替换表名
+// fn 返回 error 时不再执行查询
+func Rewrite(fn func(ctx context.Context, qc *QueryContext, q *Query) error) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, qc *QueryContext) *QueryResult {
+			q, err := qc.Query()
+			if err == nil {
+				err = fn(ctx, qc, q)
+			}
+			if err != nil {
+				return &QueryResult{
+					Err: err,
+				}
+			}
+			return next(ctx, qc)
+		}
+	}
+}
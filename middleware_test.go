@@ -0,0 +1,85 @@
+package go_orm
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+type countBuilder struct {
+	QueryBuilder
+	cnt int
+}
+
+func (c *countBuilder) Build() (*Query, error) {
+	c.cnt++
+	return c.QueryBuilder.Build()
+}
+
+func TestQueryContext_Query(t *testing.T) {
+	db := memoryDB(t)
+	b := &countBuilder{QueryBuilder: NewSelector[TestModel](db).Where(C("Id").Eq(1))}
+	qc := &QueryContext{Type: "SELECT", Builder: b}
+	q1, err := qc.Query()
+	require.NoError(t, err)
+	q2, err := qc.Query()
+	require.NoError(t, err)
+	assert.Same(t, q1, q2)
+	assert.Equal(t, 1, b.cnt)
+
+	q := &Query{SQL: "SELECT 1;"}
+	qc.SetQuery(q)
+	q3, err := qc.Query()
+	require.NoError(t, err)
+	assert.Same(t, q, q3)
+	assert.Equal(t, 1, b.cnt)
+}
+
+func TestRewrite(t *testing.T) {
+	hint := Rewrite(func(ctx context.Context, qc *QueryContext, q *Query) error {
+		if qc.Type == "SELECT" {
+			q.SQL = strings.Replace(q.SQL, "SELECT ", "SELECT /*+ MAX_EXECUTION_TIME(1000) */ ", 1)
+		}
+		return nil
+	})
+	shard := Rewrite(func(ctx context.Context, qc *QueryContext, q *Query) error {
+		q.SQL = strings.ReplaceAll(q.SQL, "`test_model`", "`test_model_01`")
+		q.Args = append(q.Args, "shard")
+		return nil
+	})
+	reject := Rewrite(func(ctx context.Context, qc *QueryContext, q *Query) error {
+		if qc.Type == "DELETE" {
+			return errors.New("rejected")
+		}
+		return nil
+	})
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	db, err := OpenDB(mockDB, DBWithMiddlewares(hint, shard, reject))
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT /*+ MAX_EXECUTION_TIME(1000) */ * FROM `test_model_01` WHERE `id` = ?;").
+		WithArgs(1, "shard").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	_, err = NewSelector[TestModel](db).Where(C("Id").Eq(1)).Get(context.Background())
+	require.NoError(t, err)
+
+	mock.ExpectExec("UPDATE `test_model_01` SET `age` = ?;").
+		WithArgs(18, "shard").
+		WillReturnResult(driver.RowsAffected(1))
+	err = NewUpdater[TestModel](db).Set(Assign("Age", 18)).Exec(context.Background()).Err()
+	require.NoError(t, err)
+
+	err = NewDeleter[TestModel](db).Exec(context.Background()).Err()
+	assert.Equal(t, errors.New("rejected"), err)
+
+	// 构造失败时不会调用 fn
+	_, err = NewSelector[TestModel](db).Where(C("Invalid").Eq(1)).Get(context.Background())
+	assert.Error(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
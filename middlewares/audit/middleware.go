@@ -0,0 +1,126 @@
+package audit
+
+import (
+	"context"
+	go_orm "github.com/Andras5014/go-orm"
+	"log/slog"
+	"time"
+)
+
+// Record 一次修改的审计记录
+type Record struct {
+	Table string
+	// Operation INSERT、UPDATE 或者 DELETE
+	Operation string
+	Actor     string
+	// Before 修改前的数据，INSERT 为 nil
+	Before []map[string]any
+	// After 修改后的数据，DELETE 为 nil
+	After     []map[string]any
+	CreatedAt time.Time
+}
+
+type actorKey struct{}
+
+// WithActor 把操作人放入 context，默认的 ActorFunc 从这里读取
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext 读取 WithActor 放入的操作人
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// MiddlewareBuilder 记录 Inserter、Updater 和 Deleter 的修改
+// 修改前的数据通过同样条件的 SELECT 获取，修改前后的查询以及写入审计记录都使用同一个 Session，
+// 所以在事务中执行时，审计记录和修改一起提交或者回滚，写入审计记录失败时返回错误，由调用者回滚。
+// 不在事务中执行时修改已经生效，之后的失败不会返回给调用者，而是交给 ErrorHandler 处理，
+// 需要保证审计记录不丢失时应该在事务中执行
+type MiddlewareBuilder struct {
+	sink    Sink
+	actor   func(ctx context.Context) string
+	now     func() time.Time
+	onError func(ctx context.Context, rec Record, err error)
+}
+
+func NewMiddlewareBuilder(sink Sink) *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		sink:    sink,
+		actor:   ActorFromContext,
+		now:     time.Now,
+		onError: logError,
+	}
+}
+
+// ErrorHandler 不在事务中执行时，修改成功之后获取数据或者写入审计记录失败的处理方法，默认使用 slog 记录
+func (m *MiddlewareBuilder) ErrorHandler(fn func(ctx context.Context, rec Record, err error)) *MiddlewareBuilder {
+	m.onError = fn
+	return m
+}
+
+func logError(ctx context.Context, rec Record, err error) {
+	slog.ErrorContext(ctx, "audit: failed to write record",
+		slog.String("table", rec.Table),
+		slog.String("operation", rec.Operation),
+		slog.String("actor", rec.Actor),
+		slog.Any("error", err))
+}
+
+// ActorFunc 指定从 context 中获取操作人的方法，默认为 ActorFromContext
+func (m *MiddlewareBuilder) ActorFunc(fn func(ctx context.Context) string) *MiddlewareBuilder {
+	m.actor = fn
+	return m
+}
+
+func (m *MiddlewareBuilder) Build() go_orm.Middleware {
+	return func(next go_orm.Handler) go_orm.Handler {
+		return func(ctx context.Context, qc *go_orm.QueryContext) *go_orm.QueryResult {
+			snap, ok := qc.Builder.(go_orm.Snapshotter)
+			if !ok || qc.Session == nil {
+				return next(ctx, qc)
+			}
+			var before []map[string]any
+			var err error
+			if qc.Type == "UPDATE" || qc.Type == "DELETE" {
+				before, err = snap.Snapshot(ctx, qc.Session)
+				if err != nil {
+					return &go_orm.QueryResult{
+						Err: err,
+					}
+				}
+			}
+			res := next(ctx, qc)
+			if res.Err != nil {
+				return res
+			}
+			rec := Record{
+				Operation: qc.Type,
+				Actor:     m.actor(ctx),
+				Before:    before,
+				CreatedAt: m.now(),
+			}
+			if qc.Model != nil {
+				rec.Table = qc.Model.TableName
+			}
+			if qc.Type == "UPDATE" || qc.Type == "INSERT" {
+				rec.After, err = snap.Snapshot(ctx, qc.Session)
+			}
+			if err == nil {
+				err = m.sink.Write(ctx, qc.Session, rec)
+			}
+			if err == nil {
+				return res
+			}
+			// 事务中返回错误，调用者回滚之后修改和审计记录都不会生效
+			if _, ok := qc.Session.(*go_orm.Tx); ok {
+				return &go_orm.QueryResult{
+					Err: err,
+				}
+			}
+			m.onError(ctx, rec, err)
+			return res
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	go_orm "github.com/Andras5014/go-orm"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type TestModel struct {
+	Id        int64
+	FirstName string
+	Age       int8
+}
+
+func openDB(t *testing.T, m *MiddlewareBuilder) *go_orm.DB {
+	db, err := go_orm.Open("sqlite3", "file:"+t.Name()+"?mode=memory&cache=shared",
+		go_orm.DBWithDialect(go_orm.DialectSQLite), go_orm.DBWithMiddlewares(m.Build()))
+	require.NoError(t, err)
+	for _, ddl := range []string{
+		"CREATE TABLE test_model (id INTEGER PRIMARY KEY, first_name TEXT, age INTEGER)",
+		"CREATE TABLE audit_log (table_name TEXT, operation TEXT, actor TEXT, before_image TEXT, after_image TEXT, created_at DATETIME)",
+	} {
+		require.NoError(t, go_orm.RawQuery[TestModel](db, ddl).Exec(context.Background()).Err())
+	}
+	return db
+}
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	var records []Record
+	m := NewMiddlewareBuilder(SinkFunc(func(ctx context.Context, sess go_orm.Session, rec Record) error {
+		records = append(records, rec)
+		return nil
+	}))
+	now := time.UnixMilli(1000)
+	m.now = func() time.Time {
+		return now
+	}
+	db := openDB(t, m)
+	ctx := WithActor(context.Background(), "tom")
+
+	err := go_orm.NewInserter[TestModel](db).Values(&TestModel{Id: 1, FirstName: "a", Age: 18}).Exec(ctx).Err()
+	require.NoError(t, err)
+	err = go_orm.NewUpdater[TestModel](db).Set(go_orm.Assign("Age", 19)).Where(go_orm.C("Id").Eq(1)).Exec(ctx).Err()
+	require.NoError(t, err)
+	err = go_orm.NewDeleter[TestModel](db).Where(go_orm.C("Id").Eq(1)).Exec(ctx).Err()
+	require.NoError(t, err)
+
+	assert.Equal(t, []Record{
+		{
+			Table:     "test_model",
+			Operation: "INSERT",
+			Actor:     "tom",
+			After:     []map[string]any{{"id": int64(1), "first_name": "a", "age": int8(18)}},
+			CreatedAt: now,
+		},
+		{
+			Table:     "test_model",
+			Operation: "UPDATE",
+			Actor:     "tom",
+			Before:    []map[string]any{{"id": int64(1), "first_name": "a", "age": int64(18)}},
+			After:     []map[string]any{{"id": int64(1), "first_name": "a", "age": int64(19)}},
+			CreatedAt: now,
+		},
+		{
+			Table:     "test_model",
+			Operation: "DELETE",
+			Actor:     "tom",
+			Before:    []map[string]any{{"id": int64(1), "first_name": "a", "age": int64(19)}},
+			CreatedAt: now,
+		},
+	}, records)
+}
+
+func TestTableSink(t *testing.T) {
+	m := NewMiddlewareBuilder(TableSink("audit_log")).ActorFunc(func(ctx context.Context) string {
+		return "system"
+	})
+	db := openDB(t, m)
+	ctx := context.Background()
+
+	// 事务回滚时审计记录一起回滚
+	err := db.DoTx(ctx, func(ctx context.Context, tx *go_orm.Tx) error {
+		err := go_orm.NewInserter[TestModel](tx).Values(&TestModel{Id: 1, FirstName: "a"}).Exec(ctx).Err()
+		require.NoError(t, err)
+		return errors.New("rollback")
+	}, nil)
+	require.Error(t, err)
+	assert.Equal(t, 0, countAudit(t, db))
+
+	err = db.DoTx(ctx, func(ctx context.Context, tx *go_orm.Tx) error {
+		return go_orm.NewInserter[TestModel](tx).Values(&TestModel{Id: 1, FirstName: "a"}).Exec(ctx).Err()
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, countAudit(t, db))
+
+	err = go_orm.NewDeleter[TestModel](db).Where(go_orm.C("Id").Eq(1)).Exec(ctx).Err()
+	require.NoError(t, err)
+	rows, err := go_orm.NewSelector[AuditLog](db).OrderBy(go_orm.Asc("CreatedAt")).GetMulti(ctx)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "DELETE", rows[1].Operation)
+	assert.Equal(t, "system", rows[1].Actor)
+	assert.Equal(t, `[{"age":0,"first_name":"a","id":1}]`, rows[1].BeforeImage.String)
+	assert.False(t, rows[1].AfterImage.Valid)
+}
+
+func TestMiddlewareBuilder_SinkError(t *testing.T) {
+	var failed []Record
+	m := NewMiddlewareBuilder(SinkFunc(func(ctx context.Context, sess go_orm.Session, rec Record) error {
+		return errors.New("sink error")
+	})).ErrorHandler(func(ctx context.Context, rec Record, err error) {
+		assert.Equal(t, errors.New("sink error"), err)
+		failed = append(failed, rec)
+	})
+	db := openDB(t, m)
+	ctx := context.Background()
+
+	// 事务中返回错误，修改一起回滚
+	err := db.DoTx(ctx, func(ctx context.Context, tx *go_orm.Tx) error {
+		return go_orm.NewInserter[TestModel](tx).Values(&TestModel{Id: 1}).Exec(ctx).Err()
+	}, nil)
+	assert.Equal(t, errors.New("sink error"), err)
+	rows, err := go_orm.NewSelector[TestModel](db).GetMulti(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, rows)
+	assert.Empty(t, failed)
+
+	// 不在事务中时修改已经生效，不返回错误
+	err = go_orm.NewInserter[TestModel](db).Values(&TestModel{Id: 1}).Exec(ctx).Err()
+	require.NoError(t, err)
+	require.Len(t, failed, 1)
+	assert.Equal(t, "INSERT", failed[0].Operation)
+	rows, err = go_orm.NewSelector[TestModel](db).GetMulti(ctx)
+	require.NoError(t, err)
+	assert.Len(t, rows, 1)
+}
+
+func TestMiddlewareBuilder_CTE(t *testing.T) {
+	var records []Record
+	m := NewMiddlewareBuilder(SinkFunc(func(ctx context.Context, sess go_orm.Session, rec Record) error {
+		records = append(records, rec)
+		return nil
+	}))
+	db := openDB(t, m)
+	ctx := context.Background()
+	err := go_orm.NewInserter[TestModel](db).Values(&TestModel{Id: 1, Age: 18}, &TestModel{Id: 2, Age: 60}).Exec(ctx).Err()
+	require.NoError(t, err)
+
+	// 修改前后的数据使用同样的 CTE
+	err = go_orm.NewDeleter[TestModel](db).
+		With("old", go_orm.NewSelector[TestModel](db).Select(go_orm.C("Id")).Where(go_orm.C("Age").Gt(50))).
+		Where(go_orm.C("Id").InQuery(go_orm.NewSelector[TestModel](db).From("old").Select(go_orm.C("Id")))).
+		Exec(ctx).Err()
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, []map[string]any{{"id": int64(2), "first_name": "", "age": int64(60)}}, records[1].Before)
+}
+
+type AuditLog struct {
+	TableName   string
+	Operation   string
+	Actor       string
+	BeforeImage sql.NullString
+	AfterImage  sql.NullString
+	CreatedAt   time.Time
+}
+
+func countAudit(t *testing.T, db *go_orm.DB) int {
+	rows, err := go_orm.NewSelector[AuditLog](db).GetMulti(context.Background())
+	require.NoError(t, err)
+	return len(rows)
+}
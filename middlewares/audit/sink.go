@@ -0,0 +1,49 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	go_orm "github.com/Andras5014/go-orm"
+)
+
+// Sink 保存审计记录，sess 是执行修改的 DB 或者 Tx
+type Sink interface {
+	Write(ctx context.Context, sess go_orm.Session, rec Record) error
+}
+
+type SinkFunc func(ctx context.Context, sess go_orm.Session, rec Record) error
+
+func (f SinkFunc) Write(ctx context.Context, sess go_orm.Session, rec Record) error {
+	return f(ctx, sess, rec)
+}
+
+// TableSink 把审计记录写入 table，修改前后的数据以 JSON 保存
+// 表结构需要包含 table_name、operation、actor、before_image、after_image 和 created_at 列
+func TableSink(table string) Sink {
+	query := "INSERT INTO " + table +
+		" (table_name,operation,actor,before_image,after_image,created_at) VALUES (?,?,?,?,?,?);"
+	return SinkFunc(func(ctx context.Context, sess go_orm.Session, rec Record) error {
+		before, err := encode(rec.Before)
+		if err != nil {
+			return err
+		}
+		after, err := encode(rec.After)
+		if err != nil {
+			return err
+		}
+		return go_orm.RawQuery[Record](sess, query,
+			rec.Table, rec.Operation, rec.Actor, before, after, rec.CreatedAt).
+			Exec(ctx).Err()
+	})
+}
+
+func encode(rows []map[string]any) (any, error) {
+	if rows == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(rows)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,236 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	go_orm "github.com/Andras5014/go-orm"
+	"sync"
+	"time"
+)
+
+// ErrOpen 熔断器处于打开状态，查询没有发送到数据库
+var ErrOpen = errors.New("breaker: circuit open")
+
+type State int
+
+const (
+	StateClosed State = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// KeyFunc 决定查询属于哪一个熔断器
+type KeyFunc func(qc *go_orm.QueryContext) string
+
+// ByTable 每张表一个熔断器
+func ByTable(qc *go_orm.QueryContext) string {
+	if qc.Model == nil {
+		return ""
+	}
+	return qc.Model.TableName
+}
+
+// ByType 每种查询类型一个熔断器
+func ByType(qc *go_orm.QueryContext) string {
+	return qc.Type
+}
+
+// MiddlewareBuilder 按错误率熔断
+//   - closed：统计窗口内请求数不少于 minRequests 并且错误率达到 errorRate 时打开
+//   - open：直接返回 ErrOpen，经过 openTimeout 之后进入 half-open
+//   - half-open：最多放行 halfOpenRequests 个探测请求，全部成功后关闭，任意一个失败重新打开
+type MiddlewareBuilder struct {
+	key              KeyFunc
+	errorRate        float64
+	minRequests      int
+	window           time.Duration
+	openTimeout      time.Duration
+	halfOpenRequests int
+	isFailure        func(err error) bool
+	now              func() time.Time
+
+	circuits sync.Map
+}
+
+func NewMiddlewareBuilder() *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		key:              ByTable,
+		errorRate:        0.5,
+		minRequests:      10,
+		window:           10 * time.Second,
+		openTimeout:      5 * time.Second,
+		halfOpenRequests: 1,
+		isFailure:        isFailure,
+		now:              time.Now,
+	}
+}
+
+// Key 指定熔断的粒度，默认为 ByTable
+func (m *MiddlewareBuilder) Key(key KeyFunc) *MiddlewareBuilder {
+	m.key = key
+	return m
+}
+
+// ErrorRate 统计窗口内请求数不少于 minRequests 并且错误率达到 rate 时熔断
+func (m *MiddlewareBuilder) ErrorRate(rate float64, minRequests int) *MiddlewareBuilder {
+	m.errorRate = rate
+	m.minRequests = minRequests
+	return m
+}
+
+// Window 错误率的统计窗口
+func (m *MiddlewareBuilder) Window(window time.Duration) *MiddlewareBuilder {
+	m.window = window
+	return m
+}
+
+// OpenTimeout 熔断之后经过多久进入 half-open
+func (m *MiddlewareBuilder) OpenTimeout(timeout time.Duration) *MiddlewareBuilder {
+	m.openTimeout = timeout
+	return m
+}
+
+// HalfOpenRequests half-open 状态下放行的探测请求数
+func (m *MiddlewareBuilder) HalfOpenRequests(n int) *MiddlewareBuilder {
+	m.halfOpenRequests = n
+	return m
+}
+
+// IsFailure 判断查询是否失败，默认 ErrNoRows 和 context 取消不算失败
+func (m *MiddlewareBuilder) IsFailure(fn func(err error) bool) *MiddlewareBuilder {
+	m.isFailure = fn
+	return m
+}
+
+// State 返回某个熔断器的状态，没有请求过的熔断器处于 closed 状态
+func (m *MiddlewareBuilder) State(key string) State {
+	val, ok := m.circuits.Load(key)
+	if !ok {
+		return StateClosed
+	}
+	c := val.(*circuit)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+func (m *MiddlewareBuilder) Build() go_orm.Middleware {
+	return func(next go_orm.Handler) go_orm.Handler {
+		return func(ctx context.Context, qc *go_orm.QueryContext) *go_orm.QueryResult {
+			key := m.key(qc)
+			val, _ := m.circuits.LoadOrStore(key, &circuit{windowStart: m.now()})
+			c := val.(*circuit)
+			gen, ok := c.allow(m, m.now())
+			if !ok {
+				return &go_orm.QueryResult{
+					Err: fmt.Errorf("%w: %s", ErrOpen, key),
+				}
+			}
+			res := next(ctx, qc)
+			c.record(m, m.now(), gen, m.isFailure(res.Err))
+			return res
+		}
+	}
+}
+
+func isFailure(err error) bool {
+	return err != nil &&
+		!errors.Is(err, go_orm.ErrNoRows) &&
+		!errors.Is(err, context.Canceled)
+}
+
+type circuit struct {
+	mu    sync.Mutex
+	state State
+	// gen 状态变化或者开始新的统计窗口时加一，放行时的 gen 和当前不同的结果会被忽略，
+	// 例如 closed 时发出、half-open 时才返回的慢查询不能算作探测请求
+	gen uint64
+
+	windowStart time.Time
+	total       int
+	failures    int
+
+	openedAt time.Time
+	// half-open 状态下放行和成功的探测请求数
+	probes    int
+	successes int
+}
+
+// allow 判断是否放行，返回放行时的 gen
+func (c *circuit) allow(m *MiddlewareBuilder, now time.Time) (uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch c.state {
+	case StateOpen:
+		if now.Sub(c.openedAt) < m.openTimeout {
+			return c.gen, false
+		}
+		c.state = StateHalfOpen
+		c.gen++
+		c.probes, c.successes = 0, 0
+		fallthrough
+	case StateHalfOpen:
+		if c.probes >= m.halfOpenRequests {
+			return c.gen, false
+		}
+		c.probes++
+	default:
+		if now.Sub(c.windowStart) >= m.window {
+			c.resetWindow(now)
+		}
+	}
+	return c.gen, true
+}
+
+func (c *circuit) record(m *MiddlewareBuilder, now time.Time, gen uint64, failed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen != c.gen {
+		return
+	}
+	switch c.state {
+	case StateHalfOpen:
+		if failed {
+			c.open(now)
+			return
+		}
+		c.successes++
+		if c.successes >= m.halfOpenRequests {
+			c.state = StateClosed
+			c.resetWindow(now)
+		}
+	case StateClosed:
+		c.total++
+		if failed {
+			c.failures++
+		}
+		if c.total >= m.minRequests && float64(c.failures)/float64(c.total) >= m.errorRate {
+			c.open(now)
+		}
+	}
+}
+
+func (c *circuit) open(now time.Time) {
+	c.state = StateOpen
+	c.gen++
+	c.openedAt = now
+}
+
+func (c *circuit) resetWindow(now time.Time) {
+	c.gen++
+	c.windowStart = now
+	c.total, c.failures = 0, 0
+}
@@ -0,0 +1,136 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	go_orm "github.com/Andras5014/go-orm"
+	"github.com/Andras5014/go-orm/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	now := time.UnixMilli(1000)
+	m := NewMiddlewareBuilder().ErrorRate(0.5, 5).OpenTimeout(time.Second).HalfOpenRequests(2)
+	m.now = func() time.Time {
+		return now
+	}
+	var calls int
+	var err error
+	handler := m.Build()(func(ctx context.Context, qc *go_orm.QueryContext) *go_orm.QueryResult {
+		calls++
+		return &go_orm.QueryResult{Err: err}
+	})
+	qc := &go_orm.QueryContext{Type: "SELECT", Model: &model.Model{TableName: "order"}}
+	other := &go_orm.QueryContext{Type: "SELECT", Model: &model.Model{TableName: "user"}}
+	ctx := context.Background()
+
+	// 请求数不够时不会熔断
+	err = errors.New("db error")
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, err, handler(ctx, qc).Err)
+	}
+	assert.Equal(t, StateClosed, m.State("order"))
+	// ErrNoRows 不算失败
+	err = go_orm.ErrNoRows
+	handler(ctx, qc)
+	assert.Equal(t, StateClosed, m.State("order"))
+	err = errors.New("db error")
+	handler(ctx, qc)
+	assert.Equal(t, StateOpen, m.State("order"))
+
+	// 打开之后不再调用下游，其他表不受影响
+	calls = 0
+	res := handler(ctx, qc)
+	assert.ErrorIs(t, res.Err, ErrOpen)
+	assert.Equal(t, 0, calls)
+	err = nil
+	assert.NoError(t, handler(ctx, other).Err)
+	assert.Equal(t, 1, calls)
+
+	// half-open 探测失败，重新打开
+	now = now.Add(time.Second)
+	err = errors.New("db error")
+	assert.Equal(t, err, handler(ctx, qc).Err)
+	assert.Equal(t, StateOpen, m.State("order"))
+	assert.ErrorIs(t, handler(ctx, qc).Err, ErrOpen)
+
+	// half-open 探测全部成功，关闭
+	now = now.Add(time.Second)
+	err = nil
+	assert.NoError(t, handler(ctx, qc).Err)
+	assert.Equal(t, StateHalfOpen, m.State("order"))
+	assert.NoError(t, handler(ctx, qc).Err)
+	assert.Equal(t, StateClosed, m.State("order"))
+}
+
+func TestMiddlewareBuilder_HalfOpenLimit(t *testing.T) {
+	now := time.UnixMilli(1000)
+	m := NewMiddlewareBuilder().Key(ByType).ErrorRate(1, 1).OpenTimeout(time.Second)
+	m.now = func() time.Time {
+		return now
+	}
+	ctx := context.Background()
+	qc := &go_orm.QueryContext{Type: "UPDATE"}
+	var handler go_orm.Handler
+	inner := func(ctx context.Context, qc *go_orm.QueryContext) *go_orm.QueryResult {
+		// 探测请求还没有结束时，其余请求被拒绝
+		assert.ErrorIs(t, handler(ctx, qc).Err, ErrOpen)
+		return &go_orm.QueryResult{}
+	}
+	handler = m.Build()(func(ctx context.Context, qc *go_orm.QueryContext) *go_orm.QueryResult {
+		return &go_orm.QueryResult{Err: errors.New("db error")}
+	})
+	handler(ctx, qc)
+	assert.Equal(t, StateOpen, m.State("UPDATE"))
+
+	now = now.Add(time.Second)
+	probe := m.Build()(inner)
+	handler = probe
+	assert.NoError(t, probe(ctx, qc).Err)
+	assert.Equal(t, StateClosed, m.State("UPDATE"))
+}
+
+func TestMiddlewareBuilder_Window(t *testing.T) {
+	now := time.UnixMilli(1000)
+	m := NewMiddlewareBuilder().ErrorRate(0.5, 2).Window(time.Second)
+	m.now = func() time.Time {
+		return now
+	}
+	var err error
+	handler := m.Build()(func(ctx context.Context, qc *go_orm.QueryContext) *go_orm.QueryResult {
+		return &go_orm.QueryResult{Err: err}
+	})
+	qc := &go_orm.QueryContext{Type: "SELECT", Model: &model.Model{TableName: "order"}}
+	err = errors.New("db error")
+	handler(context.Background(), qc)
+	// 上一个窗口的失败不再计算
+	now = now.Add(time.Second)
+	err = nil
+	handler(context.Background(), qc)
+	assert.Equal(t, StateClosed, m.State("order"))
+}
+
+func TestCircuit_StaleResult(t *testing.T) {
+	now := time.UnixMilli(1000)
+	m := NewMiddlewareBuilder().ErrorRate(1, 1).OpenTimeout(time.Second)
+	c := &circuit{windowStart: now}
+
+	// 慢查询在 closed 时放行
+	slow, ok := c.allow(m, now)
+	assert.True(t, ok)
+	gen, ok := c.allow(m, now)
+	assert.True(t, ok)
+	c.record(m, now, gen, true)
+	assert.Equal(t, StateOpen, c.state)
+
+	// 进入 half-open 之后慢查询才返回，不能算作探测成功
+	now = now.Add(time.Second)
+	probe, ok := c.allow(m, now)
+	assert.True(t, ok)
+	c.record(m, now, slow, false)
+	assert.Equal(t, StateHalfOpen, c.state)
+	c.record(m, now, probe, false)
+	assert.Equal(t, StateClosed, c.state)
+}
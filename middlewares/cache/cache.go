@@ -0,0 +1,41 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"time"
+)
+
+var ErrKeyNotFound = errors.New("cache: key not found")
+
+// Cache 缓存的存储，可以是进程内的 LRU，也可以是 Redis 之类的远程存储
+// 查询结果由中间件使用 Codec 编码之后保存
+type Cache interface {
+	// Get 不存在或者过期时返回 ErrKeyNotFound
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set expiration 为 0 表示不过期
+	Set(ctx context.Context, key string, val []byte, expiration time.Duration) error
+}
+
+// Codec 查询结果的编码方式，Unmarshal 的 v 是 *T 或者 *[]*T
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// GobCodec 默认的编码方式，只会保存导出的字段
+type GobCodec struct{}
+
+func (GobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+var _ Cache = &LRU{}
+
+// LRU 进程内的缓存，超过容量时淘汰最久没有使用的数据
+type LRU struct {
+	mutex    sync.Mutex
+	capacity int
+	list     *list.List
+	items    map[string]*list.Element
+	now      func() time.Time
+}
+
+type lruEntry struct {
+	key      string
+	val      []byte
+	deadline time.Time
+}
+
+func NewLRU(capacity int) *LRU {
+	return &LRU{
+		capacity: capacity,
+		list:     list.New(),
+		items:    make(map[string]*list.Element, capacity),
+		now:      time.Now,
+	}
+}
+
+func (l *LRU) Get(ctx context.Context, key string) ([]byte, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	elem, ok := l.items[key]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	entry := elem.Value.(*lruEntry)
+	if !entry.deadline.IsZero() && !l.now().Before(entry.deadline) {
+		l.remove(elem)
+		return nil, ErrKeyNotFound
+	}
+	l.list.MoveToFront(elem)
+	return entry.val, nil
+}
+
+func (l *LRU) Set(ctx context.Context, key string, val []byte, expiration time.Duration) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	var deadline time.Time
+	if expiration > 0 {
+		deadline = l.now().Add(expiration)
+	}
+	if elem, ok := l.items[key]; ok {
+		entry := elem.Value.(*lruEntry)
+		entry.val = val
+		entry.deadline = deadline
+		l.list.MoveToFront(elem)
+		return nil
+	}
+	l.items[key] = l.list.PushFront(&lruEntry{
+		key:      key,
+		val:      val,
+		deadline: deadline,
+	})
+	for l.list.Len() > l.capacity {
+		l.remove(l.list.Back())
+	}
+	return nil
+}
+
+// Len 缓存中的数据个数，包含已经过期但还没有被清理的数据
+func (l *LRU) Len() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.list.Len()
+}
+
+func (l *LRU) remove(elem *list.Element) {
+	l.list.Remove(elem)
+	delete(l.items, elem.Value.(*lruEntry).key)
+}
@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	l := NewLRU(2)
+	l.now = func() time.Time {
+		return now
+	}
+
+	require.NoError(t, l.Set(ctx, "a", []byte("1"), time.Minute))
+	require.NoError(t, l.Set(ctx, "b", []byte("2"), 0))
+	val, err := l.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("1"), val)
+
+	// b 最久没有使用，被淘汰
+	require.NoError(t, l.Set(ctx, "c", []byte("3"), 0))
+	_, err = l.Get(ctx, "b")
+	assert.Equal(t, ErrKeyNotFound, err)
+	assert.Equal(t, 2, l.Len())
+
+	// 覆盖
+	require.NoError(t, l.Set(ctx, "c", []byte("4"), 0))
+	val, err = l.Get(ctx, "c")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("4"), val)
+
+	// 过期
+	now = now.Add(time.Minute)
+	_, err = l.Get(ctx, "a")
+	assert.Equal(t, ErrKeyNotFound, err)
+	assert.Equal(t, 1, l.Len())
+}
@@ -0,0 +1,232 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	go_orm "github.com/Andras5014/go-orm"
+	"github.com/Andras5014/go-orm/internal/sqlcomment"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// MiddlewareBuilder 缓存 Selector 的查询结果
+// 缓存的 key 由涉及的表的版本号、租户、SQL 和参数组成，INSERT、UPDATE、DELETE 成功后更新涉及的表的版本号，
+// 旧的缓存不再命中，等待过期或者被淘汰。版本号同样保存在 Cache 中，因此多个实例共享一个远程缓存时也能失效。
+// 涉及的表来自 go_orm.StatementInfo.Tables，包括 From、CTE 和子查询中的表。
+// 事务中的查询不读也不写缓存，避免其它会话读到未提交的数据；
+// 事务中的写操作在事务提交之后才失效缓存，提交之前其它会话缓存的依旧是已经提交的数据。
+// RawQuery 的 Exec 只失效模型对应的表，SQL 修改了其它表时需要自己保证缓存过期。
+// 缓存的 key 包含去掉末尾注释的 SQL，sqlcommenter 放在缓存中间件之前或者之后都可以
+type MiddlewareBuilder struct {
+	cache      Cache
+	codec      Codec
+	expiration time.Duration
+	prefix     string
+	group      group
+	seq        atomic.Uint64
+}
+
+func NewMiddlewareBuilder(cache Cache, expiration time.Duration) *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		cache:      cache,
+		codec:      GobCodec{},
+		expiration: expiration,
+		prefix:     "orm",
+	}
+}
+
+// Prefix 缓存 key 的前缀，默认为 orm
+func (m *MiddlewareBuilder) Prefix(prefix string) *MiddlewareBuilder {
+	m.prefix = prefix
+	return m
+}
+
+// Codec 查询结果的编码方式，默认为 GobCodec
+func (m *MiddlewareBuilder) Codec(codec Codec) *MiddlewareBuilder {
+	m.codec = codec
+	return m
+}
+
+func (m *MiddlewareBuilder) Build() go_orm.Middleware {
+	return func(next go_orm.Handler) go_orm.Handler {
+		return func(ctx context.Context, qc *go_orm.QueryContext) *go_orm.QueryResult {
+			switch qc.Type {
+			case "SELECT":
+				if _, ok := qc.Session.(*go_orm.Tx); ok {
+					return next(ctx, qc)
+				}
+				return m.query(ctx, qc, next)
+			case "INSERT", "UPDATE", "DELETE":
+				res := next(ctx, qc)
+				if res.Err == nil {
+					m.invalidateAll(ctx, qc, tables(qc))
+				}
+				return res
+			case "RAW":
+				res := next(ctx, qc)
+				// 只有 Exec 的结果是 go_orm.Result，无法解析 SQL，失效模型对应的表
+				if _, ok := res.Result.(go_orm.Result); ok && res.Err == nil && qc.Model != nil {
+					m.invalidateAll(ctx, qc, []string{qc.Model.TableName})
+				}
+				return res
+			default:
+				return next(ctx, qc)
+			}
+		}
+	}
+}
+
+// invalidateAll 失效写操作涉及的表，事务中的写操作在提交之后失效
+// 失效失败只会导致读到旧数据直到过期，不影响写操作的结果
+func (m *MiddlewareBuilder) invalidateAll(ctx context.Context, qc *go_orm.QueryContext, ts []string) {
+	invalidate := func(ctx context.Context) {
+		for _, table := range ts {
+			_ = m.invalidate(ctx, table)
+		}
+	}
+	if tx, ok := qc.Session.(*go_orm.Tx); ok {
+		// 提交的时候 ctx 可能已经取消
+		ctx = context.WithoutCancel(ctx)
+		tx.OnCommit(func() {
+			invalidate(ctx)
+		})
+		return
+	}
+	invalidate(ctx)
+}
+
+// loaded 合并的请求共享的结果，data 为 nil 表示结果无法编码
+type loaded struct {
+	data   []byte
+	result any
+}
+
+func (m *MiddlewareBuilder) query(ctx context.Context, qc *go_orm.QueryContext, next go_orm.Handler) *go_orm.QueryResult {
+	q, err := qc.Query()
+	if err != nil {
+		return &go_orm.QueryResult{
+			Err: err,
+		}
+	}
+	ts := tables(qc)
+	gens := make([]string, 0, len(ts))
+	for _, table := range ts {
+		gen, err := m.generation(ctx, table)
+		if err != nil {
+			// 缓存不可用时直接查询数据库
+			return next(ctx, qc)
+		}
+		gens = append(gens, gen)
+	}
+	key := m.key(qc, ts, gens, q)
+	if data, err := m.cache.Get(ctx, key); err == nil {
+		// 解码失败当作没有命中
+		if res, err := m.decode(qc, data); err == nil {
+			return &go_orm.QueryResult{
+				Result: res,
+			}
+		}
+	}
+	val, shared, err := m.group.do(ctx, key, func(ctx context.Context) (any, error) {
+		res := next(ctx, qc)
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		data, err := m.codec.Marshal(res.Result)
+		if err != nil {
+			return &loaded{result: res.Result}, nil
+		}
+		_ = m.cache.Set(ctx, key, data, m.expiration)
+		return &loaded{data: data, result: res.Result}, nil
+	})
+	if err != nil {
+		return &go_orm.QueryResult{
+			Err: err,
+		}
+	}
+	l := val.(*loaded)
+	if !shared {
+		return &go_orm.QueryResult{
+			Result: l.result,
+		}
+	}
+	if l.data == nil {
+		// 结果无法编码，不能共享，自己查询
+		return next(ctx, qc)
+	}
+	// 合并的请求各自解码，避免相互修改
+	res, err := m.decode(qc, l.data)
+	if err != nil {
+		return next(ctx, qc)
+	}
+	return &go_orm.QueryResult{
+		Result: res,
+	}
+}
+
+// decode Get 的结果解码为 *T，GetMulti 的结果解码为 []*T
+func (m *MiddlewareBuilder) decode(qc *go_orm.QueryContext, data []byte) (any, error) {
+	typ := qc.Model.Type
+	if typ == nil {
+		return nil, errors.New("cache: unknown model type")
+	}
+	if qc.Multi {
+		typ = reflect.SliceOf(reflect.PointerTo(typ))
+	}
+	ptr := reflect.New(typ)
+	if err := m.codec.Unmarshal(data, ptr.Interface()); err != nil {
+		return nil, err
+	}
+	if qc.Multi {
+		return ptr.Elem().Interface(), nil
+	}
+	return ptr.Interface(), nil
+}
+
+// tables 语句涉及的表，构造器不支持 StatementInspector 时使用模型的表名
+func tables(qc *go_orm.QueryContext) []string {
+	if si, ok := qc.Builder.(go_orm.StatementInspector); ok {
+		if ts := si.Statement().Tables; len(ts) > 0 {
+			return ts
+		}
+	}
+	return []string{qc.Model.TableName}
+}
+
+func (m *MiddlewareBuilder) key(qc *go_orm.QueryContext, tables []string, gens []string, q *go_orm.Query) string {
+	h := sha256.New()
+	// TenantDB 的租户共享中间件，相同的 SQL 在不同租户的数据库中结果不同
+	_, _ = fmt.Fprintf(h, "%t\x00%#v\x00%s\x00%#v", qc.Multi, qc.Tenant, sqlcomment.Strip(q.SQL), q.Args)
+	return fmt.Sprintf("%s:%s:%s:%s", m.prefix, strings.Join(tables, ","), strings.Join(gens, ","), hex.EncodeToString(h.Sum(nil)))
+}
+
+func (m *MiddlewareBuilder) generationKey(table string) string {
+	return fmt.Sprintf("%s:gen:%s", m.prefix, table)
+}
+
+// generation 表的版本号，不存在时生成一个新的
+func (m *MiddlewareBuilder) generation(ctx context.Context, table string) (string, error) {
+	val, err := m.cache.Get(ctx, m.generationKey(table))
+	if err == nil {
+		return string(val), nil
+	} else if !errors.Is(err, ErrKeyNotFound) {
+		return "", err
+	}
+	return m.newGeneration(ctx, table)
+}
+
+func (m *MiddlewareBuilder) invalidate(ctx context.Context, table string) error {
+	_, err := m.newGeneration(ctx, table)
+	return err
+}
+
+func (m *MiddlewareBuilder) newGeneration(ctx context.Context, table string) (string, error) {
+	gen := strconv.FormatInt(time.Now().UnixNano(), 36) + "-" + strconv.FormatUint(m.seq.Add(1), 36)
+	return gen, m.cache.Set(ctx, m.generationKey(table), []byte(gen), 0)
+}
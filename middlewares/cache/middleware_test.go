@@ -0,0 +1,408 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	go_orm "github.com/Andras5014/go-orm"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMiddlewareBuilder(t *testing.T) {
+	testCases := []struct {
+		name  string
+		cache Cache
+	}{
+		{
+			name:  "lru",
+			cache: NewLRU(16),
+		},
+		{
+			name:  "remote",
+			cache: newFakeRemote(),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			db, err := go_orm.OpenDB(mockDB,
+				go_orm.DBWithMiddlewares(NewMiddlewareBuilder(tc.cache, time.Minute).Build()))
+			require.NoError(t, err)
+			ctx := context.Background()
+
+			mock.ExpectQuery("SELECT .*").WithArgs(1).WillReturnRows(
+				sqlmock.NewRows([]string{"id", "first_name"}).AddRow(1, "Tom"))
+			res, err := go_orm.NewSelector[TestModel](db).Where(go_orm.C("Id").Eq(1)).Get(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, &TestModel{Id: 1, FirstName: "Tom"}, res)
+
+			// 命中缓存，修改返回值不会影响缓存
+			res.FirstName = "Jerry"
+			res, err = go_orm.NewSelector[TestModel](db).Where(go_orm.C("Id").Eq(1)).Get(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, &TestModel{Id: 1, FirstName: "Tom"}, res)
+
+			// 相同的 SQL，GetMulti 和 Get 不共享缓存
+			mock.ExpectQuery("SELECT .*").WithArgs(1).WillReturnRows(
+				sqlmock.NewRows([]string{"id", "first_name"}).AddRow(1, "Tom"))
+			multi, err := go_orm.NewSelector[TestModel](db).Where(go_orm.C("Id").Eq(1)).GetMulti(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, []*TestModel{{Id: 1, FirstName: "Tom"}}, multi)
+			multi, err = go_orm.NewSelector[TestModel](db).Where(go_orm.C("Id").Eq(1)).GetMulti(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, []*TestModel{{Id: 1, FirstName: "Tom"}}, multi)
+
+			// 不同的参数不命中
+			mock.ExpectQuery("SELECT .*").WithArgs(2).WillReturnRows(
+				sqlmock.NewRows([]string{"id", "first_name"}).AddRow(2, "Jerry"))
+			res, err = go_orm.NewSelector[TestModel](db).Where(go_orm.C("Id").Eq(2)).Get(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, &TestModel{Id: 2, FirstName: "Jerry"}, res)
+
+			// 其它表的写操作不影响缓存
+			mock.ExpectExec("UPDATE `other_model`.*").WillReturnResult(driver.RowsAffected(1))
+			require.NoError(t, go_orm.NewUpdater[OtherModel](db).Set(go_orm.Assign("Id", 1)).Exec(ctx).Err())
+			res, err = go_orm.NewSelector[TestModel](db).Where(go_orm.C("Id").Eq(1)).Get(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, &TestModel{Id: 1, FirstName: "Tom"}, res)
+
+			// 同一张表的写操作失效缓存
+			mock.ExpectExec("UPDATE `test_model`.*").WillReturnResult(driver.RowsAffected(1))
+			require.NoError(t, go_orm.NewUpdater[TestModel](db).
+				Set(go_orm.Assign("FirstName", "Tom2")).Where(go_orm.C("Id").Eq(1)).Exec(ctx).Err())
+			mock.ExpectQuery("SELECT .*").WithArgs(1).WillReturnRows(
+				sqlmock.NewRows([]string{"id", "first_name"}).AddRow(1, "Tom2"))
+			res, err = go_orm.NewSelector[TestModel](db).Where(go_orm.C("Id").Eq(1)).Get(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, &TestModel{Id: 1, FirstName: "Tom2"}, res)
+
+			// 错误不会被缓存
+			mock.ExpectExec("DELETE FROM `test_model`.*").WillReturnResult(driver.RowsAffected(1))
+			require.NoError(t, go_orm.NewDeleter[TestModel](db).Where(go_orm.C("Id").Eq(1)).Exec(ctx).Err())
+			mock.ExpectQuery("SELECT .*").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			_, err = go_orm.NewSelector[TestModel](db).Where(go_orm.C("Id").Eq(1)).Get(ctx)
+			assert.Equal(t, go_orm.ErrNoRows, err)
+			mock.ExpectQuery("SELECT .*").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			_, err = go_orm.NewSelector[TestModel](db).Where(go_orm.C("Id").Eq(1)).Get(ctx)
+			assert.Equal(t, go_orm.ErrNoRows, err)
+
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestMiddlewareBuilder_Singleflight(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := go_orm.OpenDB(mockDB,
+		go_orm.DBWithMiddlewares(NewMiddlewareBuilder(NewLRU(16), time.Minute).Build()))
+	require.NoError(t, err)
+
+	// 只有一次查询真正到达数据库
+	mock.ExpectQuery("SELECT .*").WillDelayFor(50 * time.Millisecond).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "first_name"}).AddRow(1, "Tom"))
+	var wg sync.WaitGroup
+	results := make([]*TestModel, 10)
+	errs := make([]error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = go_orm.NewSelector[TestModel](db).
+				Where(go_orm.C("Id").Eq(1)).Get(context.Background())
+		}(i)
+	}
+	wg.Wait()
+	for i := 0; i < 10; i++ {
+		require.NoError(t, errs[i])
+		assert.Equal(t, &TestModel{Id: 1, FirstName: "Tom"}, results[i])
+	}
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMiddlewareBuilder_Tx(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := go_orm.OpenDB(mockDB,
+		go_orm.DBWithMiddlewares(NewMiddlewareBuilder(NewLRU(16), time.Minute).Build()))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// 事务中修改之后读到的是未提交的数据，不能进入缓存
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE `test_model`.*").WillReturnResult(driver.RowsAffected(1))
+	mock.ExpectQuery("SELECT .*").WithArgs(1).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "first_name"}).AddRow(1, "Jerry"))
+	mock.ExpectRollback()
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	require.NoError(t, go_orm.NewUpdater[TestModel](tx).
+		Set(go_orm.Assign("FirstName", "Jerry")).Where(go_orm.C("Id").Eq(1)).Exec(ctx).Err())
+	res, err := go_orm.NewSelector[TestModel](tx).Where(go_orm.C("Id").Eq(1)).Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, &TestModel{Id: 1, FirstName: "Jerry"}, res)
+	require.NoError(t, tx.Rollback())
+
+	// 回滚之后从数据库读取
+	mock.ExpectQuery("SELECT .*").WithArgs(1).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "first_name"}).AddRow(1, "Tom"))
+	res, err = go_orm.NewSelector[TestModel](db).Where(go_orm.C("Id").Eq(1)).Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, &TestModel{Id: 1, FirstName: "Tom"}, res)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMiddlewareBuilder_TxCommit(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := go_orm.OpenDB(mockDB,
+		go_orm.DBWithMiddlewares(NewMiddlewareBuilder(NewLRU(16), time.Minute).Build()))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE `test_model`.*").WillReturnResult(driver.RowsAffected(1))
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	require.NoError(t, go_orm.NewUpdater[TestModel](tx).
+		Set(go_orm.Assign("FirstName", "Jerry")).Where(go_orm.C("Id").Eq(1)).Exec(ctx).Err())
+
+	// 提交之前其它会话读到并缓存的是已经提交的数据
+	mock.ExpectQuery("SELECT .*").WithArgs(1).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "first_name"}).AddRow(1, "Tom"))
+	for i := 0; i < 2; i++ {
+		res, err := go_orm.NewSelector[TestModel](db).Where(go_orm.C("Id").Eq(1)).Get(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, &TestModel{Id: 1, FirstName: "Tom"}, res)
+	}
+
+	// 提交之后失效缓存
+	mock.ExpectCommit()
+	require.NoError(t, tx.Commit())
+	mock.ExpectQuery("SELECT .*").WithArgs(1).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "first_name"}).AddRow(1, "Jerry"))
+	res, err := go_orm.NewSelector[TestModel](db).Where(go_orm.C("Id").Eq(1)).Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, &TestModel{Id: 1, FirstName: "Jerry"}, res)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMiddlewareBuilder_Raw(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := go_orm.OpenDB(mockDB,
+		go_orm.DBWithMiddlewares(NewMiddlewareBuilder(NewLRU(16), time.Minute).Build()))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	mock.ExpectQuery("SELECT .*").WithArgs(1).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "first_name"}).AddRow(1, "Tom"))
+	res, err := go_orm.NewSelector[TestModel](db).Where(go_orm.C("Id").Eq(1)).Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, &TestModel{Id: 1, FirstName: "Tom"}, res)
+
+	// RawQuery 的 Exec 失效模型对应的表
+	mock.ExpectExec("UPDATE `test_model`.*").WillReturnResult(driver.RowsAffected(1))
+	require.NoError(t, go_orm.RawQuery[TestModel](db, "UPDATE `test_model` SET `first_name` = 'Jerry'").Exec(ctx).Err())
+	mock.ExpectQuery("SELECT .*").WithArgs(1).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "first_name"}).AddRow(1, "Jerry"))
+	res, err = go_orm.NewSelector[TestModel](db).Where(go_orm.C("Id").Eq(1)).Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, &TestModel{Id: 1, FirstName: "Jerry"}, res)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMiddlewareBuilder_Tables(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := go_orm.OpenDB(mockDB,
+		go_orm.DBWithMiddlewares(NewMiddlewareBuilder(NewLRU(16), time.Minute).Build()))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	get := func(name string) {
+		res, err := go_orm.NewSelector[TestModel](db).Where(go_orm.C("Id").Eq(1)).Get(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, &TestModel{Id: 1, FirstName: name}, res)
+	}
+	expectGet := func(name string) {
+		mock.ExpectQuery("SELECT .*").WithArgs(1).WillReturnRows(
+			sqlmock.NewRows([]string{"id", "first_name"}).AddRow(1, name))
+	}
+	expectGet("Tom")
+	get("Tom")
+	get("Tom")
+
+	// From 指定的表
+	mock.ExpectExec("DELETE FROM `test_model`.*").WillReturnResult(driver.RowsAffected(1))
+	require.NoError(t, go_orm.NewDeleter[OtherModel](db).Form("`test_model`").
+		Where(go_orm.C("Id").Eq(2)).Exec(ctx).Err())
+	expectGet("Jerry")
+	get("Jerry")
+	get("Jerry")
+
+	// 查询中的子查询涉及的表，其它表的写操作失效缓存
+	sub := go_orm.NewSelector[OtherModel](db).Select(go_orm.C("Id"))
+	mock.ExpectQuery("SELECT .*").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "first_name"}).AddRow(1, "Tom"))
+	_, err = go_orm.NewSelector[TestModel](db).Where(go_orm.C("Id").InQuery(sub)).GetMulti(ctx)
+	require.NoError(t, err)
+	_, err = go_orm.NewSelector[TestModel](db).Where(go_orm.C("Id").InQuery(sub)).GetMulti(ctx)
+	require.NoError(t, err)
+	mock.ExpectExec("DELETE FROM `other_model`.*").WillReturnResult(driver.RowsAffected(1))
+	require.NoError(t, go_orm.NewDeleter[OtherModel](db).Where(go_orm.C("Id").Eq(2)).Exec(ctx).Err())
+	mock.ExpectQuery("SELECT .*").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "first_name"}).AddRow(1, "Tom"))
+	_, err = go_orm.NewSelector[TestModel](db).Where(go_orm.C("Id").InQuery(sub)).GetMulti(ctx)
+	require.NoError(t, err)
+
+	// CTE 中的表
+	mock.ExpectExec("WITH .* DELETE FROM `other_model`.*").WillReturnResult(driver.RowsAffected(1))
+	require.NoError(t, go_orm.NewDeleter[OtherModel](db).
+		With("t", go_orm.NewSelector[TestModel](db).Select(go_orm.C("Id"))).
+		Where(go_orm.C("Id").InQuery(go_orm.NewSelector[TestModel](db).From("t").Select(go_orm.C("Id")))).
+		Exec(ctx).Err())
+	expectGet("Tom")
+	get("Tom")
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMiddlewareBuilder_SingleflightCancel(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := go_orm.OpenDB(mockDB,
+		go_orm.DBWithMiddlewares(NewMiddlewareBuilder(NewLRU(16), time.Minute).Build()))
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT .*").WillDelayFor(100 * time.Millisecond).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "first_name"}).AddRow(1, "Tom"))
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := go_orm.NewSelector[TestModel](db).Where(go_orm.C("Id").Eq(1)).Get(leaderCtx)
+		leaderErr <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	waiter := make(chan *TestModel, 1)
+	go func() {
+		res, err := go_orm.NewSelector[TestModel](db).Where(go_orm.C("Id").Eq(1)).Get(context.Background())
+		assert.NoError(t, err)
+		waiter <- res
+	}()
+	time.Sleep(20 * time.Millisecond)
+	// 发起查询的请求取消之后，等待的请求依然拿到结果
+	cancel()
+	assert.Equal(t, context.Canceled, <-leaderErr)
+	assert.Equal(t, &TestModel{Id: 1, FirstName: "Tom"}, <-waiter)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// fakeRemote 模拟 Redis 之类的远程缓存，保存的是值的副本
+type fakeRemote struct {
+	mutex sync.Mutex
+	data  map[string][]byte
+}
+
+func newFakeRemote() *fakeRemote {
+	return &fakeRemote{
+		data: make(map[string][]byte),
+	}
+}
+
+func (f *fakeRemote) Get(ctx context.Context, key string) ([]byte, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	val, ok := f.data[key]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return bytes.Clone(val), nil
+}
+
+func (f *fakeRemote) Set(ctx context.Context, key string, val []byte, expiration time.Duration) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.data[key] = bytes.Clone(val)
+	return nil
+}
+
+type TestModel struct {
+	Id        int64
+	FirstName string
+}
+
+type OtherModel struct {
+	Id int64
+}
+
+func TestMiddlewareBuilder_Comment(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	// 模拟放在缓存中间件之前的 sqlcommenter，每次追加不同的注释
+	var cnt int
+	comment := func(next go_orm.Handler) go_orm.Handler {
+		return func(ctx context.Context, qc *go_orm.QueryContext) *go_orm.QueryResult {
+			q, err := qc.Query()
+			if err == nil {
+				cnt++
+				q.SQL = fmt.Sprintf("%s /*traceparent='%d'*/;", q.SQL[:len(q.SQL)-1], cnt)
+			}
+			return next(ctx, qc)
+		}
+	}
+	db, err := go_orm.OpenDB(mockDB, go_orm.DBWithMiddlewares(
+		comment, NewMiddlewareBuilder(NewLRU(16), time.Minute).Build()))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	mock.ExpectQuery("SELECT .*").WithArgs(1).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "first_name"}).AddRow(1, "Tom"))
+	for i := 0; i < 2; i++ {
+		res, err := go_orm.NewSelector[TestModel](db).Where(go_orm.C("Id").Eq(1)).Get(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, &TestModel{Id: 1, FirstName: "Tom"}, res)
+	}
+	assert.Equal(t, 2, cnt)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMiddlewareBuilder_TenantDB(t *testing.T) {
+	dbs := make(map[string]*sql.DB, 2)
+	mocks := make(map[string]sqlmock.Sqlmock, 2)
+	for _, tenant := range []string{"a", "b"} {
+		mockDB, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		dbs[tenant], mocks[tenant] = mockDB, mock
+	}
+	db, err := go_orm.OpenTenantDB(func(ctx context.Context, tenant any) (*sql.DB, error) {
+		return dbs[tenant.(string)], nil
+	}, go_orm.DBWithMiddlewares(NewMiddlewareBuilder(NewLRU(16), time.Minute).Build()))
+	require.NoError(t, err)
+
+	// 相同的 SQL，每个租户查询自己的数据库
+	for _, tenant := range []string{"a", "b"} {
+		mocks[tenant].ExpectQuery("SELECT .*").WithArgs(1).WillReturnRows(
+			sqlmock.NewRows([]string{"id", "first_name"}).AddRow(1, tenant))
+		ctx := go_orm.WithTenant(context.Background(), tenant)
+		for i := 0; i < 2; i++ {
+			res, err := go_orm.NewSelector[TestModel](db).Where(go_orm.C("Id").Eq(1)).Get(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, &TestModel{Id: 1, FirstName: tenant}, res)
+		}
+	}
+	for _, mock := range mocks {
+		require.NoError(t, mock.ExpectationsWereMet())
+	}
+}
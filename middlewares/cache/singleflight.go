@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var errPanicked = errors.New("cache: query panicked")
+
+// group 合并并发的相同请求，只有一个请求会真正执行
+type group struct {
+	mutex sync.Mutex
+	calls map[string]*call
+}
+
+type call struct {
+	done chan struct{}
+	val  any
+	err  error
+}
+
+// do 执行 fn 或者等待正在执行的相同请求，shared 表示结果来自其它请求
+// fn 使用不会被取消的 ctx 在单独的 goroutine 中执行，发起的请求被取消不会影响等待的请求，
+// 每个请求只在自己的 ctx 被取消时提前返回
+func (g *group) do(ctx context.Context, key string, fn func(ctx context.Context) (any, error)) (val any, shared bool, err error) {
+	g.mutex.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+	c, shared := g.calls[key]
+	if !shared {
+		// fn panic 时所有请求都会拿到 errPanicked
+		c = &call{done: make(chan struct{}), err: errPanicked}
+		g.calls[key] = c
+		go g.run(ctx, key, c, fn)
+	}
+	g.mutex.Unlock()
+
+	select {
+	case <-c.done:
+		return c.val, shared, c.err
+	case <-ctx.Done():
+		return nil, shared, ctx.Err()
+	}
+}
+
+func (g *group) run(ctx context.Context, key string, c *call, fn func(ctx context.Context) (any, error)) {
+	defer func() {
+		_ = recover()
+		g.mutex.Lock()
+		delete(g.calls, key)
+		g.mutex.Unlock()
+		close(c.done)
+	}()
+	c.val, c.err = fn(context.WithoutCancel(ctx))
+}
@@ -0,0 +1,169 @@
+package chaos
+
+import (
+	"context"
+	go_orm "github.com/Andras5014/go-orm"
+	"github.com/go-sql-driver/mysql"
+	"math/rand"
+	"slices"
+	"sync"
+	"time"
+)
+
+// 常用的数据库错误，driver.ErrBadConn 可以直接使用
+var (
+	ErrDeadlock = &mysql.MySQLError{
+		Number:  1213,
+		Message: "Deadlock found when trying to get lock; try restarting transaction",
+	}
+	ErrLockWaitTimeout = &mysql.MySQLError{
+		Number:  1205,
+		Message: "Lock wait timeout exceeded; try restarting transaction",
+	}
+)
+
+// Rule 故障注入规则，匹配条件为空表示匹配所有查询
+type Rule struct {
+	// Types 查询类型，例如 SELECT、UPDATE
+	Types []string
+	// Tables 表名
+	Tables []string
+	// Flag 不为空时只匹配 context 中带有这个标记的查询，见 WithFlag
+	Flag string
+
+	// Probability 触发概率，取值 [0, 1]，1 总是触发，0 从不触发
+	Probability float64
+
+	// Latency 执行查询之前的延迟，context 超时或者取消时提前返回
+	Latency time.Duration
+	// Err 不为 nil 时直接返回这个错误，不执行查询
+	Err error
+	// DropResult 查询执行之后丢弃结果
+	// Get 返回 ErrNoRows，GetMulti 返回空切片，Exec 返回空的 Result
+	DropResult bool
+}
+
+func (r Rule) match(ctx context.Context, qc *go_orm.QueryContext) bool {
+	if len(r.Types) > 0 && !slices.Contains(r.Types, qc.Type) {
+		return false
+	}
+	if len(r.Tables) > 0 && (qc.Model == nil || !slices.Contains(r.Tables, qc.Model.TableName)) {
+		return false
+	}
+	return r.Flag == "" || hasFlag(ctx, r.Flag)
+}
+
+type flagKey struct{}
+
+// WithFlag 给 context 加上标记，只有带标记的查询会匹配设置了 Flag 的规则
+func WithFlag(ctx context.Context, flags ...string) context.Context {
+	old, _ := ctx.Value(flagKey{}).(map[string]struct{})
+	res := make(map[string]struct{}, len(old)+len(flags))
+	for f := range old {
+		res[f] = struct{}{}
+	}
+	for _, f := range flags {
+		res[f] = struct{}{}
+	}
+	return context.WithValue(ctx, flagKey{}, res)
+}
+
+func hasFlag(ctx context.Context, flag string) bool {
+	flags, _ := ctx.Value(flagKey{}).(map[string]struct{})
+	_, ok := flags[flag]
+	return ok
+}
+
+// MiddlewareBuilder 按照规则注入延迟、错误或者丢弃结果，用于测试重试和事务的处理逻辑
+// 规则按照添加的顺序匹配，第一条匹配并且按概率触发的规则生效
+type MiddlewareBuilder struct {
+	rules []Rule
+	mu    sync.Mutex
+	rand  *rand.Rand
+}
+
+func NewMiddlewareBuilder(rules ...Rule) *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		rules: rules,
+		rand:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Rule 追加一条规则
+func (m *MiddlewareBuilder) Rule(r Rule) *MiddlewareBuilder {
+	m.rules = append(m.rules, r)
+	return m
+}
+
+// Seed 固定随机数种子，相同的种子和查询顺序会触发相同的故障
+func (m *MiddlewareBuilder) Seed(seed int64) *MiddlewareBuilder {
+	m.rand = rand.New(rand.NewSource(seed))
+	return m
+}
+
+func (m *MiddlewareBuilder) Build() go_orm.Middleware {
+	return func(next go_orm.Handler) go_orm.Handler {
+		return func(ctx context.Context, qc *go_orm.QueryContext) *go_orm.QueryResult {
+			r, ok := m.pick(ctx, qc)
+			if !ok {
+				return next(ctx, qc)
+			}
+			if r.Latency > 0 {
+				timer := time.NewTimer(r.Latency)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					return &go_orm.QueryResult{
+						Err: ctx.Err(),
+					}
+				}
+			}
+			if r.Err != nil {
+				return &go_orm.QueryResult{
+					Err: r.Err,
+				}
+			}
+			res := next(ctx, qc)
+			if !r.DropResult || res.Err != nil {
+				return res
+			}
+			return dropped(qc, res)
+		}
+	}
+}
+
+func (m *MiddlewareBuilder) pick(ctx context.Context, qc *go_orm.QueryContext) (Rule, bool) {
+	for _, r := range m.rules {
+		if !r.match(ctx, qc) {
+			continue
+		}
+		if r.Probability >= 1 || r.Probability > 0 && m.float64() < r.Probability {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+func (m *MiddlewareBuilder) float64() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.rand.Float64()
+}
+
+// dropped 丢弃结果，原生查询的 Exec 和 Get 类型都是 RAW，按照原来的结果区分
+func dropped(qc *go_orm.QueryContext, res *go_orm.QueryResult) *go_orm.QueryResult {
+	_, isExec := res.Result.(go_orm.Result)
+	switch {
+	case isExec || qc.Type != "SELECT" && qc.Type != "RAW":
+		return &go_orm.QueryResult{
+			Result: go_orm.Result{},
+		}
+	case qc.Multi:
+		return &go_orm.QueryResult{}
+	default:
+		return &go_orm.QueryResult{
+			Err: go_orm.ErrNoRows,
+		}
+	}
+}
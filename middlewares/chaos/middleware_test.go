@@ -0,0 +1,195 @@
+package chaos
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	go_orm "github.com/Andras5014/go-orm"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type TestModel struct {
+	Id        int
+	FirstName string
+	Age       int
+	LastName  *sql.NullString
+}
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	testCases := []struct {
+		name    string
+		rules   []Rule
+		ctx     context.Context
+		mock    func(mock sqlmock.Sqlmock)
+		query   func(ctx context.Context, db *go_orm.DB) error
+		wantErr error
+	}{
+		{
+			name:  "deadlock on update",
+			rules: []Rule{{Types: []string{"UPDATE"}, Err: ErrDeadlock, Probability: 1}},
+			ctx:   context.Background(),
+			query: func(ctx context.Context, db *go_orm.DB) error {
+				return go_orm.NewUpdater[TestModel](db).Set(go_orm.Assign("Age", 1)).Exec(ctx).Err()
+			},
+			wantErr: ErrDeadlock,
+		},
+		{
+			name:  "type not match",
+			rules: []Rule{{Types: []string{"UPDATE"}, Err: ErrDeadlock, Probability: 1}},
+			ctx:   context.Background(),
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("DELETE .*").WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			query: func(ctx context.Context, db *go_orm.DB) error {
+				return go_orm.NewDeleter[TestModel](db).Exec(ctx).Err()
+			},
+		},
+		{
+			name:  "bad conn on table",
+			rules: []Rule{{Tables: []string{"test_model"}, Err: driver.ErrBadConn, Probability: 1}},
+			ctx:   context.Background(),
+			query: func(ctx context.Context, db *go_orm.DB) error {
+				_, err := go_orm.NewSelector[TestModel](db).Get(ctx)
+				return err
+			},
+			wantErr: driver.ErrBadConn,
+		},
+		{
+			name:  "flag",
+			rules: []Rule{{Flag: "broken", Err: driver.ErrBadConn, Probability: 1}},
+			ctx:   WithFlag(context.Background(), "broken"),
+			query: func(ctx context.Context, db *go_orm.DB) error {
+				_, err := go_orm.NewSelector[TestModel](db).GetMulti(ctx)
+				return err
+			},
+			wantErr: driver.ErrBadConn,
+		},
+		{
+			name:  "flag not set",
+			rules: []Rule{{Flag: "broken", Err: driver.ErrBadConn, Probability: 1}},
+			ctx:   WithFlag(context.Background(), "other"),
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT .*").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			},
+			query: func(ctx context.Context, db *go_orm.DB) error {
+				_, err := go_orm.NewSelector[TestModel](db).GetMulti(ctx)
+				return err
+			},
+		},
+		{
+			name:  "drop get result",
+			rules: []Rule{{DropResult: true, Probability: 1}},
+			ctx:   context.Background(),
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT .*").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			},
+			query: func(ctx context.Context, db *go_orm.DB) error {
+				_, err := go_orm.NewSelector[TestModel](db).Get(ctx)
+				return err
+			},
+			wantErr: go_orm.ErrNoRows,
+		},
+		{
+			name:  "drop exec result",
+			rules: []Rule{{DropResult: true, Probability: 1}},
+			ctx:   context.Background(),
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("DELETE .*").WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			query: func(ctx context.Context, db *go_orm.DB) error {
+				affected, err := go_orm.NewDeleter[TestModel](db).Exec(ctx).RowsAffected()
+				assert.Equal(t, int64(0), affected)
+				return err
+			},
+		},
+		{
+			name:  "drop raw exec result",
+			rules: []Rule{{DropResult: true, Probability: 1}},
+			ctx:   context.Background(),
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("DELETE .*").WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			query: func(ctx context.Context, db *go_orm.DB) error {
+				affected, err := go_orm.RawQuery[TestModel](db, "DELETE FROM `test_model`").Exec(ctx).RowsAffected()
+				assert.Equal(t, int64(0), affected)
+				return err
+			},
+		},
+		{
+			name:  "drop raw get result",
+			rules: []Rule{{DropResult: true, Probability: 1}},
+			ctx:   context.Background(),
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT .*").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			},
+			query: func(ctx context.Context, db *go_orm.DB) error {
+				_, err := go_orm.RawQuery[TestModel](db, "SELECT * FROM `test_model`").Get(ctx)
+				return err
+			},
+			wantErr: go_orm.ErrNoRows,
+		},
+		{
+			name:  "zero probability",
+			rules: []Rule{{Err: ErrDeadlock}},
+			ctx:   context.Background(),
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("DELETE .*").WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			query: func(ctx context.Context, db *go_orm.DB) error {
+				return go_orm.NewDeleter[TestModel](db).Exec(ctx).Err()
+			},
+		},
+		{
+			name:  "latency timeout",
+			rules: []Rule{{Latency: time.Second, Probability: 1}},
+			ctx: func() context.Context {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+				t.Cleanup(cancel)
+				return ctx
+			}(),
+			query: func(ctx context.Context, db *go_orm.DB) error {
+				return go_orm.NewDeleter[TestModel](db).Exec(ctx).Err()
+			},
+			wantErr: context.DeadlineExceeded,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer func() {
+				_ = mockDB.Close()
+			}()
+			if tc.mock != nil {
+				tc.mock(mock)
+			}
+			db, err := go_orm.OpenDB(mockDB, go_orm.DBWithMiddlewares(NewMiddlewareBuilder(tc.rules...).Build()))
+			require.NoError(t, err)
+			err = tc.query(tc.ctx, db)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestMiddlewareBuilder_Seed(t *testing.T) {
+	run := func(seed int64) []bool {
+		m := NewMiddlewareBuilder().Rule(Rule{Probability: 0.5, Err: ErrDeadlock}).Seed(seed)
+		handler := m.Build()(func(ctx context.Context, qc *go_orm.QueryContext) *go_orm.QueryResult {
+			return &go_orm.QueryResult{}
+		})
+		res := make([]bool, 32)
+		for i := range res {
+			res[i] = handler(context.Background(), &go_orm.QueryContext{Type: "SELECT"}).Err != nil
+		}
+		return res
+	}
+	first := run(42)
+	assert.Equal(t, first, run(42))
+	assert.Contains(t, first, true)
+	assert.Contains(t, first, false)
+}
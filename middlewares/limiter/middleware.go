@@ -0,0 +1,96 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	go_orm "github.com/Andras5014/go-orm"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrRateLimited 令牌桶中没有令牌
+	ErrRateLimited = errors.New("limiter: rate limited")
+	// ErrTooManyInFlight 正在执行的查询达到上限
+	ErrTooManyInFlight = errors.New("limiter: too many in-flight queries")
+)
+
+// MiddlewareBuilder 在查询到达连接池之前限流，超出限制时直接返回错误而不是排队等待连接
+type MiddlewareBuilder struct {
+	bucket *tokenBucket
+	sem    chan struct{}
+	now    func() time.Time
+}
+
+func NewMiddlewareBuilder() *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		now: time.Now,
+	}
+}
+
+// TokenBucket 每秒生成 rate 个令牌，最多积攒 burst 个，每个查询消耗一个令牌
+func (m *MiddlewareBuilder) TokenBucket(rate float64, burst int) *MiddlewareBuilder {
+	m.bucket = &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+	}
+	return m
+}
+
+// MaxInFlight 同时执行的查询不超过 n 个
+func (m *MiddlewareBuilder) MaxInFlight(n int) *MiddlewareBuilder {
+	m.sem = make(chan struct{}, n)
+	return m
+}
+
+func (m *MiddlewareBuilder) Build() go_orm.Middleware {
+	return func(next go_orm.Handler) go_orm.Handler {
+		return func(ctx context.Context, qc *go_orm.QueryContext) *go_orm.QueryResult {
+			// 先占用并发数再取令牌，因为并发数超限被拒绝的查询不会消耗令牌
+			if m.sem != nil {
+				select {
+				case m.sem <- struct{}{}:
+					defer func() {
+						<-m.sem
+					}()
+				default:
+					return &go_orm.QueryResult{
+						Err: ErrTooManyInFlight,
+					}
+				}
+			}
+			if m.bucket != nil && !m.bucket.take(m.now()) {
+				return &go_orm.QueryResult{
+					Err: ErrRateLimited,
+				}
+			}
+			return next(ctx, qc)
+		}
+	}
+}
+
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func (b *tokenBucket) take(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,110 @@
+package limiter
+
+import (
+	"context"
+	go_orm "github.com/Andras5014/go-orm"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMiddlewareBuilder_TokenBucket(t *testing.T) {
+	now := time.UnixMilli(1000)
+	m := NewMiddlewareBuilder().TokenBucket(2, 2)
+	m.now = func() time.Time {
+		return now
+	}
+	var calls int
+	handler := m.Build()(func(ctx context.Context, qc *go_orm.QueryContext) *go_orm.QueryResult {
+		calls++
+		return &go_orm.QueryResult{}
+	})
+	ctx := context.Background()
+	qc := &go_orm.QueryContext{Type: "SELECT"}
+	assert.NoError(t, handler(ctx, qc).Err)
+	assert.NoError(t, handler(ctx, qc).Err)
+	assert.Equal(t, ErrRateLimited, handler(ctx, qc).Err)
+	assert.Equal(t, 2, calls)
+
+	// 500ms 生成一个令牌
+	now = now.Add(500 * time.Millisecond)
+	assert.NoError(t, handler(ctx, qc).Err)
+	assert.Equal(t, ErrRateLimited, handler(ctx, qc).Err)
+
+	// 最多积攒 burst 个令牌
+	now = now.Add(10 * time.Second)
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, handler(ctx, qc).Err)
+	}
+	assert.Equal(t, ErrRateLimited, handler(ctx, qc).Err)
+}
+
+func TestMiddlewareBuilder_MaxInFlight(t *testing.T) {
+	m := NewMiddlewareBuilder().MaxInFlight(2)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := m.Build()(func(ctx context.Context, qc *go_orm.QueryContext) *go_orm.QueryResult {
+		started <- struct{}{}
+		<-release
+		return &go_orm.QueryResult{}
+	})
+	ctx := context.Background()
+	qc := &go_orm.QueryContext{Type: "SELECT"}
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, handler(ctx, qc).Err)
+		}()
+		<-started
+	}
+	assert.Equal(t, ErrTooManyInFlight, handler(ctx, qc).Err)
+	close(release)
+	wg.Wait()
+
+	// 之前的查询结束之后可以继续执行
+	release = make(chan struct{})
+	go func() {
+		<-started
+		close(release)
+	}()
+	assert.NoError(t, handler(ctx, qc).Err)
+}
+
+func TestMiddlewareBuilder_RejectedKeepsToken(t *testing.T) {
+	now := time.UnixMilli(1000)
+	m := NewMiddlewareBuilder().TokenBucket(1, 2).MaxInFlight(1)
+	m.now = func() time.Time {
+		return now
+	}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := m.Build()(func(ctx context.Context, qc *go_orm.QueryContext) *go_orm.QueryResult {
+		started <- struct{}{}
+		<-release
+		return &go_orm.QueryResult{}
+	})
+	ctx := context.Background()
+	qc := &go_orm.QueryContext{Type: "SELECT"}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(t, handler(ctx, qc).Err)
+	}()
+	<-started
+	// 并发数超限被拒绝，不消耗令牌
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, ErrTooManyInFlight, handler(ctx, qc).Err)
+	}
+	close(release)
+	<-done
+
+	release = make(chan struct{})
+	close(release)
+	go func() {
+		<-started
+	}()
+	assert.NoError(t, handler(ctx, qc).Err)
+}
@@ -2,42 +2,151 @@ package opentelemetry
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	go_orm "github.com/Andras5014/go-orm"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
 	"go.opentelemetry.io/otel/trace"
+	"strings"
+	"time"
 )
 
 const (
 	instrumentationName = "github.com/Andras5014/go-orm/middlewares/opentelemetry"
 )
 
+// MiddlewareBuilder 按照 OpenTelemetry 数据库语义约定记录 span 和指标
+// span 属性包括 db.system、db.statement、db.operation 和 db.sql.table
+// 指标包括耗时直方图 db.client.operation.duration 和错误计数 db.client.errors
 type MiddlewareBuilder struct {
 	Tracer trace.Tracer
+	Meter  metric.Meter
+
+	system   string
+	sanitize bool
+}
+
+func NewMiddlewareBuilder() *MiddlewareBuilder {
+	return &MiddlewareBuilder{}
+}
+
+// DBSystem 指定 db.system，例如 mysql、sqlite、postgresql，默认使用 DB 的方言
+func (m *MiddlewareBuilder) DBSystem(system string) *MiddlewareBuilder {
+	m.system = system
+	return m
+}
+
+// SanitizeStatement 记录 db.statement 之前把 SQL 中的字符串和数字字面量替换为 ?
+// 参数本身从来不会被记录，这个选项用于原生查询中直接拼接了敏感数据的场景
+func (m *MiddlewareBuilder) SanitizeStatement() *MiddlewareBuilder {
+	m.sanitize = true
+	return m
 }
 
+// Builder 已废弃，请使用 Build
 func (m *MiddlewareBuilder) Builder() go_orm.Middleware {
-	if m.Tracer == nil {
-		m.Tracer = otel.GetTracerProvider().Tracer(instrumentationName)
+	return m.Build()
+}
+
+func (m *MiddlewareBuilder) Build() go_orm.Middleware {
+	tracer := m.Tracer
+	if tracer == nil {
+		tracer = otel.GetTracerProvider().Tracer(instrumentationName)
+	}
+	meter := m.Meter
+	if meter == nil {
+		meter = otel.GetMeterProvider().Meter(instrumentationName)
+	}
+	duration, err := meter.Float64Histogram("db.client.operation.duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("Duration of database client operations."))
+	if err != nil {
+		otel.Handle(err)
+		duration, _ = noop.Meter{}.Float64Histogram("db.client.operation.duration")
 	}
+	errCounter, err := meter.Int64Counter("db.client.errors",
+		metric.WithUnit("{error}"),
+		metric.WithDescription("Number of failed database client operations."))
+	if err != nil {
+		otel.Handle(err)
+		errCounter, _ = noop.Meter{}.Int64Counter("db.client.errors")
+	}
+
 	return func(next go_orm.Handler) go_orm.Handler {
 		return func(ctx context.Context, qc *go_orm.QueryContext) *go_orm.QueryResult {
+			var tbl string
+			if qc.Model != nil {
+				tbl = qc.Model.TableName
+			}
+			// 和 handler 共享同一次 Build 的结果
+			q, _ := qc.Query()
+			op := operation(qc.Type, q)
 
-			// span name: select-test_model
-			tbl := qc.Model.TableName
-			spanCtx, span := m.Tracer.Start(ctx, fmt.Sprintf("%s-%s", qc.Type, tbl))
+			attrs := []attribute.KeyValue{
+				semconv.DBSystemKey.String(m.dbSystem(qc)),
+				semconv.DBOperation(op),
+			}
+			if tbl != "" {
+				attrs = append(attrs, semconv.DBSQLTable(tbl))
+			}
+			spanName := op
+			if tbl != "" {
+				spanName = op + " " + tbl
+			}
+			spanCtx, span := tracer.Start(ctx, spanName,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(attrs...))
 			defer span.End()
-			q, _ := qc.Builder.Build()
 			if q != nil {
-				span.SetAttributes(attribute.String("sql", q.SQL))
+				stmt := q.SQL
+				if m.sanitize {
+					stmt = Sanitize(stmt)
+				}
+				span.SetAttributes(semconv.DBStatement(stmt))
 			}
-			span.SetAttributes(attribute.String("table", tbl))
+
+			start := time.Now()
 			res := next(spanCtx, qc)
-			if res.Err != nil {
+			set := metric.WithAttributes(attrs...)
+			// 使用 span 的 ctx，指标的 exemplar 可以关联到这个 span
+			duration.Record(spanCtx, time.Since(start).Seconds(), set)
+			// 没有数据不是错误
+			if res.Err != nil && !errors.Is(res.Err, go_orm.ErrNoRows) {
 				span.RecordError(res.Err)
+				span.SetStatus(codes.Error, res.Err.Error())
+				errCounter.Add(spanCtx, 1, set)
 			}
 			return res
 		}
 	}
 }
+
+// dbSystem 没有指定 DBSystem 时使用方言的名字
+func (m *MiddlewareBuilder) dbSystem(qc *go_orm.QueryContext) string {
+	if m.system != "" {
+		return m.system
+	}
+	if qc.Dialect != nil {
+		return qc.Dialect.Name()
+	}
+	return semconv.DBSystemOtherSQL.Value.AsString()
+}
+
+// operation 原生查询的类型是 RAW，用 SQL 的第一个关键字作为 db.operation
+func operation(typ string, q *go_orm.Query) string {
+	if typ != "RAW" || q == nil {
+		return typ
+	}
+	stmt := strings.TrimSpace(q.SQL)
+	if idx := strings.IndexAny(stmt, " \t\n\r;("); idx >= 0 {
+		stmt = stmt[:idx]
+	}
+	if stmt == "" {
+		return typ
+	}
+	return strings.ToUpper(stmt)
+}
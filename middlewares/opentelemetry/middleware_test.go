@@ -0,0 +1,188 @@
+package opentelemetry
+
+import (
+	"context"
+	"errors"
+	go_orm "github.com/Andras5014/go-orm"
+	"github.com/Andras5014/go-orm/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"testing"
+)
+
+type countBuilder struct {
+	query *go_orm.Query
+	cnt   int
+}
+
+func (c *countBuilder) Build() (*go_orm.Query, error) {
+	c.cnt++
+	return c.query, nil
+}
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	testCases := []struct {
+		name      string
+		mb        func(m *MiddlewareBuilder) *MiddlewareBuilder
+		qc        *go_orm.QueryContext
+		err       error
+		wantName  string
+		wantAttrs []attribute.KeyValue
+		wantErrs  int64
+	}{
+		{
+			name: "select",
+			qc: &go_orm.QueryContext{
+				Type:    "SELECT",
+				Model:   &model.Model{TableName: "test_model"},
+				Dialect: go_orm.DialectMySQL,
+				Builder: &countBuilder{query: &go_orm.Query{
+					SQL:  "SELECT * FROM `test_model` WHERE `id` = ?;",
+					Args: []any{1},
+				}},
+			},
+			wantName: "SELECT test_model",
+			wantAttrs: []attribute.KeyValue{
+				attribute.String("db.system", "mysql"),
+				attribute.String("db.operation", "SELECT"),
+				attribute.String("db.sql.table", "test_model"),
+				attribute.String("db.statement", "SELECT * FROM `test_model` WHERE `id` = ?;"),
+			},
+		},
+		{
+			name: "no rows is not error",
+			qc: &go_orm.QueryContext{
+				Type:    "SELECT",
+				Model:   &model.Model{TableName: "test_model"},
+				Dialect: go_orm.DialectPostgreSQL,
+				Builder: &countBuilder{query: &go_orm.Query{SQL: "SELECT * FROM `test_model`;"}},
+			},
+			err:      go_orm.ErrNoRows,
+			wantName: "SELECT test_model",
+			wantAttrs: []attribute.KeyValue{
+				attribute.String("db.system", "postgresql"),
+				attribute.String("db.operation", "SELECT"),
+				attribute.String("db.sql.table", "test_model"),
+				attribute.String("db.statement", "SELECT * FROM `test_model`;"),
+			},
+		},
+		{
+			name: "raw sanitized",
+			mb: func(m *MiddlewareBuilder) *MiddlewareBuilder {
+				return m.DBSystem("sqlite").SanitizeStatement()
+			},
+			qc: &go_orm.QueryContext{
+				Type:    "RAW",
+				Model:   &model.Model{TableName: "test_model"},
+				Dialect: go_orm.DialectMySQL,
+				Builder: &countBuilder{query: &go_orm.Query{
+					SQL: "update `test_model2` set `first_name` = 'it''s' where `id` = 12",
+				}},
+			},
+			err:      errors.New("db error"),
+			wantName: "UPDATE test_model",
+			wantAttrs: []attribute.KeyValue{
+				attribute.String("db.system", "sqlite"),
+				attribute.String("db.operation", "UPDATE"),
+				attribute.String("db.sql.table", "test_model"),
+				attribute.String("db.statement", "update `test_model2` set `first_name` = ? where `id` = ?"),
+			},
+			wantErrs: 1,
+		},
+		{
+			name: "unknown dialect",
+			qc: &go_orm.QueryContext{
+				Type:    "DELETE",
+				Builder: &countBuilder{query: &go_orm.Query{SQL: "DELETE FROM `test_model`;"}},
+			},
+			wantName: "DELETE",
+			wantAttrs: []attribute.KeyValue{
+				attribute.String("db.system", "other_sql"),
+				attribute.String("db.operation", "DELETE"),
+				attribute.String("db.statement", "DELETE FROM `test_model`;"),
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// exemplar 记录指标对应的 span
+			t.Setenv("OTEL_GO_X_EXEMPLAR", "true")
+			recorder := tracetest.NewSpanRecorder()
+			reader := sdkmetric.NewManualReader()
+			m := NewMiddlewareBuilder()
+			m.Tracer = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+			m.Meter = sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test")
+			if tc.mb != nil {
+				m = tc.mb(m)
+			}
+			handler := m.Build()(func(ctx context.Context, qc *go_orm.QueryContext) *go_orm.QueryResult {
+				_, err := qc.Query()
+				require.NoError(t, err)
+				return &go_orm.QueryResult{Err: tc.err}
+			})
+			res := handler(context.Background(), tc.qc)
+			assert.Equal(t, tc.err, res.Err)
+			// 中间件和 handler 只构造一次
+			assert.Equal(t, 1, tc.qc.Builder.(*countBuilder).cnt)
+
+			spans := recorder.Ended()
+			require.Len(t, spans, 1)
+			span := spans[0]
+			assert.Equal(t, tc.wantName, span.Name())
+			assert.Equal(t, trace.SpanKindClient, span.SpanKind())
+			assert.ElementsMatch(t, tc.wantAttrs, span.Attributes())
+			if tc.wantErrs > 0 {
+				assert.Equal(t, codes.Error, span.Status().Code)
+			} else {
+				assert.Equal(t, codes.Unset, span.Status().Code)
+			}
+
+			var rm metricdata.ResourceMetrics
+			require.NoError(t, reader.Collect(context.Background(), &rm))
+			require.Len(t, rm.ScopeMetrics, 1)
+			metrics := make(map[string]metricdata.Metrics, 2)
+			for _, mt := range rm.ScopeMetrics[0].Metrics {
+				metrics[mt.Name] = mt
+			}
+			hist := metrics["db.client.operation.duration"].Data.(metricdata.Histogram[float64])
+			require.Len(t, hist.DataPoints, 1)
+			assert.Equal(t, uint64(1), hist.DataPoints[0].Count)
+			require.Len(t, hist.DataPoints[0].Exemplars, 1)
+			traceID := span.SpanContext().TraceID()
+			assert.Equal(t, traceID[:], hist.DataPoints[0].Exemplars[0].TraceID)
+			assert.Equal(t, "s", metrics["db.client.operation.duration"].Unit)
+			if tc.wantErrs == 0 {
+				_, ok := metrics["db.client.errors"]
+				assert.False(t, ok)
+				return
+			}
+			sum := metrics["db.client.errors"].Data.(metricdata.Sum[int64])
+			require.Len(t, sum.DataPoints, 1)
+			assert.Equal(t, tc.wantErrs, sum.DataPoints[0].Value)
+		})
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	testCases := []struct {
+		query string
+		want  string
+	}{
+		{query: "SELECT * FROM `t1` WHERE `id` = ?", want: "SELECT * FROM `t1` WHERE `id` = ?"},
+		{query: "SELECT * FROM t WHERE name = 'a\\'b' AND age > 18.5", want: "SELECT * FROM t WHERE name = ? AND age > ?"},
+		{query: `SELECT "col1" FROM t LIMIT 10`, want: `SELECT "col1" FROM t LIMIT ?`},
+		{query: "SELECT x2 FROM t WHERE y IN (1,2)", want: "SELECT x2 FROM t WHERE y IN (?,?)"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			assert.Equal(t, tc.want, Sanitize(tc.query))
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package opentelemetry
+
+import "strings"
+
+// Sanitize 把 SQL 中的字符串和数字字面量替换为 ?，保留反引号和双引号括起来的标识符
+func Sanitize(query string) string {
+	var sb strings.Builder
+	sb.Grow(len(query))
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case ch == '`' || ch == '"':
+			end := strings.IndexByte(query[i+1:], ch)
+			if end < 0 {
+				sb.WriteString(query[i:])
+				return sb.String()
+			}
+			sb.WriteString(query[i : i+end+2])
+			i += end + 1
+		case ch == '\'':
+			// '' 是字符串中转义的单引号
+			j := i + 1
+			for j < len(query) {
+				if query[j] == '\\' {
+					j += 2
+					continue
+				}
+				if query[j] == '\'' {
+					if j+1 < len(query) && query[j+1] == '\'' {
+						j += 2
+						continue
+					}
+					break
+				}
+				j++
+			}
+			sb.WriteByte('?')
+			i = j
+		case isDigit(ch) && (i == 0 || !isIdent(query[i-1])):
+			j := i
+			for j < len(query) && (isDigit(query[j]) || query[j] == '.') {
+				j++
+			}
+			sb.WriteByte('?')
+			i = j - 1
+		default:
+			sb.WriteByte(ch)
+		}
+	}
+	return sb.String()
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func isIdent(ch byte) bool {
+	return isDigit(ch) || ch == '_' || ch == '$' ||
+		(ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
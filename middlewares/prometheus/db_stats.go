@@ -0,0 +1,44 @@
+package prometheus
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DBStatsCollector 在采集时读取连接池统计信息
+type DBStatsCollector struct {
+	db DBStater
+
+	open      *prometheus.Desc
+	inUse     *prometheus.Desc
+	idle      *prometheus.Desc
+	waitCount *prometheus.Desc
+}
+
+func NewDBStatsCollector(db DBStater, namespace, subsystem string) *DBStatsCollector {
+	return &DBStatsCollector{
+		db: db,
+		open: prometheus.NewDesc(prometheus.BuildFQName(namespace, subsystem, "db_open_connections"),
+			"The number of established connections both in use and idle.", nil, nil),
+		inUse: prometheus.NewDesc(prometheus.BuildFQName(namespace, subsystem, "db_in_use_connections"),
+			"The number of connections currently in use.", nil, nil),
+		idle: prometheus.NewDesc(prometheus.BuildFQName(namespace, subsystem, "db_idle_connections"),
+			"The number of idle connections.", nil, nil),
+		waitCount: prometheus.NewDesc(prometheus.BuildFQName(namespace, subsystem, "db_wait_count_total"),
+			"The total number of connections waited for.", nil, nil),
+	}
+}
+
+func (c *DBStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.open
+	ch <- c.inUse
+	ch <- c.idle
+	ch <- c.waitCount
+}
+
+func (c *DBStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.db.Stats()
+	ch <- prometheus.MustNewConstMetric(c.open, prometheus.GaugeValue, float64(stats.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.inUse, prometheus.GaugeValue, float64(stats.InUse))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stats.Idle))
+	ch <- prometheus.MustNewConstMetric(c.waitCount, prometheus.CounterValue, float64(stats.WaitCount))
+}
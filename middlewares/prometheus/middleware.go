@@ -2,44 +2,102 @@ package prometheus
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	go_orm "github.com/Andras5014/go-orm"
 	"github.com/prometheus/client_golang/prometheus"
 	"time"
 )
 
+const (
+	StatusOK     = "ok"
+	StatusError  = "error"
+	StatusNoRows = "no_rows"
+)
+
+// DBStater 提供连接池统计信息，*sql.DB 和 *go_orm.DB 都实现了这个接口
+type DBStater interface {
+	Stats() sql.DBStats
+}
+
+// MiddlewareBuilder 用直方图统计查询耗时，单位是秒，标签为 type、table 和 status
 type MiddlewareBuilder struct {
 	Namespace string
 	Subsystem string
-	Name      string
-	Help      string
+	// Name 默认为 query_duration_seconds
+	Name string
+	Help string
+	// Buckets 默认为 prometheus.DefBuckets
+	Buckets []float64
+	// Registerer 默认为 prometheus.DefaultRegisterer
+	// 重复 Build 会复用已经注册的指标
+	Registerer prometheus.Registerer
+	// DBStats 不为 nil 时额外注册连接池指标
+	DBStats DBStater
 }
 
 func (m *MiddlewareBuilder) Build() go_orm.Middleware {
-	vector := prometheus.NewSummaryVec(prometheus.SummaryOpts{
+	reg := m.Registerer
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	name := m.Name
+	if name == "" {
+		name = "query_duration_seconds"
+	}
+	help := m.Help
+	if help == "" {
+		help = "Duration of ORM queries in seconds."
+	}
+	vector := register(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Namespace: m.Namespace,
 		Subsystem: m.Subsystem,
-		Name:      m.Name,
-		Help:      m.Help,
-		Objectives: map[float64]float64{
-			0.5:   0.01,
-			0.75:  0.01,
-			0.90:  0.01,
-			0.99:  0.001,
-			0.999: 0.0001,
-		},
-	}, []string{"type", "table"})
-
-	prometheus.MustRegister(vector)
+		Name:      name,
+		Help:      help,
+		Buckets:   m.Buckets,
+	}, []string{"type", "table", "status"}))
+	if m.DBStats != nil {
+		register(reg, NewDBStatsCollector(m.DBStats, m.Namespace, m.Subsystem))
+	}
+
 	return func(next go_orm.Handler) go_orm.Handler {
 		return func(ctx context.Context, qc *go_orm.QueryContext) *go_orm.QueryResult {
 			startTime := time.Now()
-			defer func() {
-				// 统计耗时
-				vector.WithLabelValues(qc.Type, qc.Model.TableName).Observe(float64(time.Since(startTime).Milliseconds()))
-			}()
+			res := next(ctx, qc)
+			var tbl string
+			if qc.Model != nil {
+				tbl = qc.Model.TableName
+			}
+			vector.WithLabelValues(qc.Type, tbl, status(res.Err)).
+				Observe(time.Since(startTime).Seconds())
+			return res
+		}
+	}
+}
 
-			return next(ctx, qc)
+func status(err error) string {
+	switch {
+	case err == nil:
+		return StatusOK
+	case errors.Is(err, go_orm.ErrNoRows):
+		return StatusNoRows
+	default:
+		return StatusError
+	}
+}
 
+// register 注册指标，已经注册过同样的指标时返回已有的指标
+// 其余错误说明配置有问题，例如同名指标的标签不同，直接 panic
+func register[C prometheus.Collector](reg prometheus.Registerer, c C) C {
+	err := reg.Register(c)
+	if err == nil {
+		return c
+	}
+	var are prometheus.AlreadyRegisteredError
+	if errors.As(err, &are) {
+		if existing, ok := are.ExistingCollector.(C); ok {
+			return existing
 		}
 	}
+	panic(err)
 }
@@ -0,0 +1,105 @@
+package prometheus
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	go_orm "github.com/Andras5014/go-orm"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+type TestModel struct {
+	Id        int
+	FirstName string
+	Age       int
+	LastName  *sql.NullString
+}
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	reg := prometheus.NewRegistry()
+	m := &MiddlewareBuilder{
+		Namespace:  "orm",
+		Buckets:    []float64{0.001, 0.01, 0.1, 1},
+		Registerer: reg,
+	}
+	// 重复 Build 不会 panic，并且共享同一个指标
+	_ = m.Build()
+	db, err := go_orm.OpenDB(mockDB, go_orm.DBWithMiddlewares(m.Build()))
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT .*").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT .*").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("SELECT .*").WillReturnError(errors.New("db error"))
+	for i := 0; i < 3; i++ {
+		_, _ = go_orm.NewSelector[TestModel](db).Get(context.Background())
+	}
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	assert.Equal(t, 3, testutil.CollectAndCount(reg, "orm_query_duration_seconds"))
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	require.Len(t, families, 1)
+	statuses := make(map[string]uint64, 3)
+	for _, mt := range families[0].GetMetric() {
+		labels := make(map[string]string, 3)
+		for _, l := range mt.GetLabel() {
+			labels[l.GetName()] = l.GetValue()
+		}
+		assert.Equal(t, "SELECT", labels["type"])
+		assert.Equal(t, "test_model", labels["table"])
+		assert.Len(t, mt.GetHistogram().GetBucket(), 4)
+		statuses[labels["status"]] = mt.GetHistogram().GetSampleCount()
+	}
+	assert.Equal(t, map[string]uint64{
+		StatusOK:     1,
+		StatusNoRows: 1,
+		StatusError:  1,
+	}, statuses)
+}
+
+type fakeStater struct {
+	stats sql.DBStats
+}
+
+func (f fakeStater) Stats() sql.DBStats {
+	return f.stats
+}
+
+func TestDBStatsCollector(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := &MiddlewareBuilder{
+		Namespace:  "orm",
+		Registerer: reg,
+		DBStats: fakeStater{stats: sql.DBStats{
+			OpenConnections: 5,
+			InUse:           3,
+			Idle:            2,
+			WaitCount:       7,
+		}},
+	}
+	_ = m.Build()
+	_ = m.Build()
+	err := testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP orm_db_idle_connections The number of idle connections.
+# TYPE orm_db_idle_connections gauge
+orm_db_idle_connections 2
+# HELP orm_db_in_use_connections The number of connections currently in use.
+# TYPE orm_db_in_use_connections gauge
+orm_db_in_use_connections 3
+# HELP orm_db_open_connections The number of established connections both in use and idle.
+# TYPE orm_db_open_connections gauge
+orm_db_open_connections 5
+# HELP orm_db_wait_count_total The total number of connections waited for.
+# TYPE orm_db_wait_count_total counter
+orm_db_wait_count_total 7
+`), "orm_db_idle_connections", "orm_db_in_use_connections", "orm_db_open_connections", "orm_db_wait_count_total")
+	require.NoError(t, err)
+}
@@ -0,0 +1,50 @@
+package querylog
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	go_orm "github.com/Andras5014/go-orm"
+	"strings"
+)
+
+// SQLExplain 通过 db 执行 prefix + SQL 获取执行计划，例如 MySQL 的 EXPLAIN，SQLite 的 EXPLAIN QUERY PLAN
+// 每一行的各列用 | 分隔，行之间用换行分隔
+func SQLExplain(db *sql.DB, prefix string) ExplainFunc {
+	return func(ctx context.Context, q *go_orm.Query) (string, error) {
+		rows, err := db.QueryContext(ctx, prefix+" "+strings.TrimSuffix(q.SQL, ";"), q.Args...)
+		if err != nil {
+			return "", err
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+		cs, err := rows.Columns()
+		if err != nil {
+			return "", err
+		}
+		var sb strings.Builder
+		sb.WriteString(strings.Join(cs, "|"))
+		vals := make([]any, len(cs))
+		ptrs := make([]any, len(cs))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		for rows.Next() {
+			if err = rows.Scan(ptrs...); err != nil {
+				return "", err
+			}
+			sb.WriteByte('\n')
+			for i, val := range vals {
+				if i > 0 {
+					sb.WriteByte('|')
+				}
+				if bs, ok := val.([]byte); ok {
+					val = string(bs)
+				}
+				sb.WriteString(fmt.Sprint(val))
+			}
+		}
+		return sb.String(), rows.Err()
+	}
+}
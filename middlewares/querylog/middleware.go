@@ -4,12 +4,30 @@ import (
 	"context"
 	go_orm "github.com/Andras5014/go-orm"
 	"log"
+	"log/slog"
+	"math/rand"
+	"reflect"
+	"time"
 )
 
 type MiddlewareBuilder struct {
 	logFunc func(query string, args []any)
+
+	// slow 开启慢查询模式，只在执行完之后记录耗时超过 threshold 的查询
+	slow       bool
+	threshold  time.Duration
+	sampleRate float64
+	logger     *slog.Logger
+	// redacted 需要脱敏的列名
+	redacted map[string]struct{}
+
+	explainThreshold time.Duration
+	explain          ExplainFunc
 }
 
+// ExplainFunc 获取查询的执行计划
+type ExplainFunc func(ctx context.Context, q *go_orm.Query) (string, error)
+
 func NewMiddlewareBuilder() *MiddlewareBuilder {
 	return &MiddlewareBuilder{
 		logFunc: func(query string, args []any) {
@@ -22,10 +40,53 @@ func (m *MiddlewareBuilder) LogFunc(logFunc func(query string, args []any)) *Mid
 	m.logFunc = logFunc
 	return m
 }
+
+// SlowQuery 开启慢查询模式，执行耗时不低于 threshold 的查询才会被记录
+// 慢查询通过 slog 输出，包含耗时、影响行数和错误
+func (m *MiddlewareBuilder) SlowQuery(threshold time.Duration) *MiddlewareBuilder {
+	m.slow = true
+	m.threshold = threshold
+	return m
+}
+
+// Logger 慢查询使用的 slog.Logger，默认为 slog.Default()
+func (m *MiddlewareBuilder) Logger(logger *slog.Logger) *MiddlewareBuilder {
+	m.logger = logger
+	return m
+}
+
+// SampleRate 慢查询的采样率，取值 (0, 1]，默认全部记录
+func (m *MiddlewareBuilder) SampleRate(rate float64) *MiddlewareBuilder {
+	m.sampleRate = rate
+	return m
+}
+
+// Redact 慢查询日志中，这些列对应的参数会被替换为 ***
+// 原生查询的参数无法对应到列，会全部被替换
+func (m *MiddlewareBuilder) Redact(cols ...string) *MiddlewareBuilder {
+	if m.redacted == nil {
+		m.redacted = make(map[string]struct{}, len(cols))
+	}
+	for _, col := range cols {
+		m.redacted[col] = struct{}{}
+	}
+	return m
+}
+
+// Explain 耗时不低于 threshold 的慢查询会额外记录执行计划
+func (m *MiddlewareBuilder) Explain(threshold time.Duration, fn ExplainFunc) *MiddlewareBuilder {
+	m.explainThreshold = threshold
+	m.explain = fn
+	return m
+}
+
 func (m *MiddlewareBuilder) Build() go_orm.Middleware {
+	if m.slow {
+		return m.buildSlow()
+	}
 	return func(next go_orm.Handler) go_orm.Handler {
 		return func(ctx context.Context, qc *go_orm.QueryContext) *go_orm.QueryResult {
-			q, err := qc.Builder.Build()
+			q, err := qc.Query()
 			if err != nil {
 				return &go_orm.QueryResult{
 					Err: err,
@@ -37,3 +98,105 @@ func (m *MiddlewareBuilder) Build() go_orm.Middleware {
 		}
 	}
 }
+
+func (m *MiddlewareBuilder) buildSlow() go_orm.Middleware {
+	logger := m.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return func(next go_orm.Handler) go_orm.Handler {
+		return func(ctx context.Context, qc *go_orm.QueryContext) *go_orm.QueryResult {
+			start := time.Now()
+			res := next(ctx, qc)
+			duration := time.Since(start)
+			if duration < m.threshold || !m.sampled() {
+				return res
+			}
+			// 只有慢查询才构造 SQL，避免额外的开销
+			q, err := qc.Query()
+			if err != nil {
+				return res
+			}
+			attrs := []slog.Attr{
+				slog.String("type", qc.Type),
+				slog.String("sql", q.SQL),
+				slog.Any("args", m.redact(q)),
+				slog.Duration("duration", duration),
+			}
+			if qc.Model != nil {
+				attrs = append(attrs, slog.String("table", qc.Model.TableName))
+			}
+			if rows, ok := affected(res); ok {
+				attrs = append(attrs, slog.Int64("rows", rows))
+			}
+			if res.Err != nil {
+				attrs = append(attrs, slog.String("error", res.Err.Error()))
+			}
+			if m.explain != nil && duration >= m.explainThreshold {
+				plan, err := m.explain(ctx, q)
+				if err != nil {
+					attrs = append(attrs, slog.String("explain_error", err.Error()))
+				} else {
+					attrs = append(attrs, slog.String("explain", plan))
+				}
+			}
+			logger.LogAttrs(ctx, slog.LevelWarn, "slow query", attrs...)
+			return res
+		}
+	}
+}
+
+func (m *MiddlewareBuilder) sampled() bool {
+	return m.sampleRate <= 0 || m.sampleRate >= 1 || rand.Float64() < m.sampleRate
+}
+
+// redact 替换需要脱敏的参数，不会修改原始参数
+// 参数对应的列来自构造器生成的 Query.ArgColumns，
+// 原生查询或者被改写过参数的查询无法确定参数对应的列，全部脱敏
+func (m *MiddlewareBuilder) redact(q *go_orm.Query) []any {
+	if len(m.redacted) == 0 || len(q.Args) == 0 {
+		return q.Args
+	}
+	args := make([]any, len(q.Args))
+	known := len(q.ArgColumns) == len(q.Args)
+	for i, arg := range q.Args {
+		args[i] = arg
+		if !known {
+			args[i] = "***"
+			continue
+		}
+		if _, ok := m.redacted[q.ArgColumns[i]]; ok {
+			args[i] = "***"
+		}
+	}
+	return args
+}
+
+// affected select 返回查询到的行数，其它语句返回影响的行数
+func affected(res *go_orm.QueryResult) (int64, bool) {
+	if res.Err != nil {
+		return 0, false
+	}
+	switch r := res.Result.(type) {
+	case go_orm.Result:
+		rows, err := r.RowsAffected()
+		return rows, err == nil
+	case nil:
+		return 0, false
+	}
+	return selectedRows(res.Result)
+}
+
+func selectedRows(result any) (int64, bool) {
+	rv := reflect.ValueOf(result)
+	switch rv.Kind() {
+	case reflect.Slice:
+		return int64(rv.Len()), true
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return 0, true
+		}
+		return 1, true
+	}
+	return 0, false
+}
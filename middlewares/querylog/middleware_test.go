@@ -1,13 +1,20 @@
 package querylog
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	go_orm "github.com/Andras5014/go-orm"
+	"github.com/DATA-DOG/go-sqlmock"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"log/slog"
 	"testing"
+	"time"
 )
 
 func TestNewMiddlewareBuilder(t *testing.T) {
@@ -35,3 +42,103 @@ type TestModel struct {
 	Age       int
 	LastName  *sql.NullString
 }
+
+func TestMiddlewareBuilder_SlowQuery(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	var explained string
+	m := NewMiddlewareBuilder().SlowQuery(0).Logger(logger).Redact("first_name").
+		Explain(0, func(ctx context.Context, q *go_orm.Query) (string, error) {
+			explained = q.SQL
+			return "plan", nil
+		})
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := go_orm.OpenDB(mockDB, go_orm.DBWithMiddlewares(m.Build()))
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT .*").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "first_name"}).AddRow(1, "Tom").AddRow(2, "Jerry"))
+	_, err = go_orm.NewSelector[TestModel](db).
+		Where(go_orm.C("FirstName").Eq("Tom"), go_orm.C("Id").Eq(1)).Limit(10).GetMulti(context.Background())
+	require.NoError(t, err)
+	record := map[string]any{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "slow query", record["msg"])
+	assert.Equal(t, "WARN", record["level"])
+	assert.Equal(t, "SELECT", record["type"])
+	assert.Equal(t, "test_model", record["table"])
+	assert.Equal(t, "SELECT * FROM `test_model` WHERE (`first_name` = ?) AND (`id` = ?) LIMIT ?;", record["sql"])
+	assert.Equal(t, []any{"***", float64(1), float64(10)}, record["args"])
+	assert.Equal(t, float64(2), record["rows"])
+	assert.Equal(t, "plan", record["explain"])
+	assert.Equal(t, record["sql"], explained)
+
+	buf.Reset()
+	mock.ExpectExec("INSERT INTO .*").WillReturnError(errors.New("db error"))
+	_ = go_orm.NewInserter[TestModel](db).Values(&TestModel{Id: 1, FirstName: "Tom"}, &TestModel{Id: 2, FirstName: "Jerry"}).
+		Exec(context.Background())
+	record = map[string]any{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "INSERT", record["type"])
+	assert.Equal(t, []any{float64(1), "***", float64(0), nil, float64(2), "***", float64(0), nil}, record["args"])
+	assert.Equal(t, "db error", record["error"])
+	assert.NotContains(t, record, "rows")
+}
+
+func TestMiddlewareBuilder_SlowQueryThreshold(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewMiddlewareBuilder().SlowQuery(time.Hour).Logger(slog.New(slog.NewJSONHandler(&buf, nil)))
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := go_orm.OpenDB(mockDB, go_orm.DBWithMiddlewares(m.Build()))
+	require.NoError(t, err)
+
+	mock.ExpectExec("DELETE .*").WillReturnResult(driver.RowsAffected(1))
+	res := go_orm.NewDeleter[TestModel](db).Where(go_orm.C("Id").Eq(1)).Exec(context.Background())
+	require.NoError(t, res.Err())
+	assert.Empty(t, buf.String())
+}
+
+func TestMiddlewareBuilder_Redact(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	m := NewMiddlewareBuilder().SlowQuery(0).Logger(logger).Redact("first_name")
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := go_orm.OpenDB(mockDB, go_orm.DBWithMiddlewares(m.Build()))
+	require.NoError(t, err)
+
+	// 参数对应的列来自构造器，SQL 中的字符串常量不影响
+	mock.ExpectExec("UPDATE .*").WillReturnResult(driver.RowsAffected(1))
+	require.NoError(t, go_orm.NewUpdater[TestModel](db).
+		Set(go_orm.Assign("FirstName", "Tom"), go_orm.Assign("Age", go_orm.C("Age").Add(1))).
+		Where(go_orm.Raw("`last_name` = '?'").AsPredicate().And(go_orm.C("Id").Eq(1))).
+		Exec(context.Background()).Err())
+	record := map[string]any{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, []any{"***", float64(1), float64(1)}, record["args"])
+
+	// 原生查询无法确定参数对应的列，全部脱敏
+	buf.Reset()
+	mock.ExpectExec("UPDATE .*").WillReturnResult(driver.RowsAffected(1))
+	require.NoError(t, go_orm.RawQuery[TestModel](db, "UPDATE `test_model` SET `age` = ? WHERE `id` = ?", 18, 1).
+		Exec(context.Background()).Err())
+	record = map[string]any{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, []any{"***", "***"}, record["args"])
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSQLExplain(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	mock.ExpectQuery("EXPLAIN SELECT \\* FROM `test_model` WHERE `id` = \\?$").WithArgs(1).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "type", "key"}).AddRow(1, []byte("const"), "PRIMARY"))
+	plan, err := SQLExplain(mockDB, "EXPLAIN")(context.Background(), &go_orm.Query{
+		SQL:  "SELECT * FROM `test_model` WHERE `id` = ?;",
+		Args: []any{1},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "id|type|key\n1|const|PRIMARY", plan)
+}
@@ -0,0 +1,147 @@
+package safety
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	go_orm "github.com/Andras5014/go-orm"
+	"strings"
+)
+
+var (
+	ErrNoWhere            = errors.New("safety: statement without WHERE")
+	ErrNoLimit            = errors.New("safety: SELECT without LIMIT on large table")
+	ErrMultipleStatements = errors.New("safety: raw query contains multiple statements")
+)
+
+// MiddlewareBuilder 拦截危险的语句
+//   - 没有 WHERE 的 UPDATE 和 DELETE
+//   - 大表上没有 LIMIT 的 SELECT
+//   - 包含多条语句的原生查询
+//
+// 构造器调用 AllowFullTableScan 后不会被拦截
+type MiddlewareBuilder struct {
+	largeTables  map[string]struct{}
+	allowNoWhere map[string]struct{}
+	allowMulti   bool
+}
+
+func NewMiddlewareBuilder() *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		largeTables:  map[string]struct{}{},
+		allowNoWhere: map[string]struct{}{},
+	}
+}
+
+// LargeTables 这些表上的 SELECT 必须有 LIMIT
+func (m *MiddlewareBuilder) LargeTables(tables ...string) *MiddlewareBuilder {
+	for _, tbl := range tables {
+		m.largeTables[tbl] = struct{}{}
+	}
+	return m
+}
+
+// AllowNoWhere 这些表上允许没有 WHERE 的 UPDATE 和 DELETE
+func (m *MiddlewareBuilder) AllowNoWhere(tables ...string) *MiddlewareBuilder {
+	for _, tbl := range tables {
+		m.allowNoWhere[tbl] = struct{}{}
+	}
+	return m
+}
+
+// AllowMultipleStatements 允许原生查询包含多条语句
+func (m *MiddlewareBuilder) AllowMultipleStatements() *MiddlewareBuilder {
+	m.allowMulti = true
+	return m
+}
+
+func (m *MiddlewareBuilder) Build() go_orm.Middleware {
+	return func(next go_orm.Handler) go_orm.Handler {
+		return func(ctx context.Context, qc *go_orm.QueryContext) *go_orm.QueryResult {
+			if err := m.check(qc); err != nil {
+				return &go_orm.QueryResult{
+					Err: err,
+				}
+			}
+			return next(ctx, qc)
+		}
+	}
+}
+
+func (m *MiddlewareBuilder) check(qc *go_orm.QueryContext) error {
+	var table string
+	if qc.Model != nil {
+		table = qc.Model.TableName
+	}
+	if qc.Type == "RAW" {
+		if m.allowMulti {
+			return nil
+		}
+		q, err := qc.Query()
+		if err != nil {
+			return err
+		}
+		if multipleStatements(q.SQL) {
+			return ErrMultipleStatements
+		}
+		return nil
+	}
+	inspector, ok := qc.Builder.(go_orm.StatementInspector)
+	if !ok {
+		return nil
+	}
+	stmt := inspector.Statement()
+	if stmt.FullTableScanAllowed {
+		return nil
+	}
+	switch qc.Type {
+	case "UPDATE", "DELETE":
+		if _, ok = m.allowNoWhere[table]; !ok && !stmt.HasWhere {
+			return fmt.Errorf("%w: %s %s", ErrNoWhere, qc.Type, table)
+		}
+	case "SELECT":
+		if _, ok = m.largeTables[table]; ok && !stmt.HasLimit {
+			return fmt.Errorf("%w: %s", ErrNoLimit, table)
+		}
+	}
+	return nil
+}
+
+// multipleStatements 判断 SQL 中是否有多条语句，忽略字符串、引号和注释中的分号以及末尾的分号
+func multipleStatements(query string) bool {
+	ended := false
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case ch == '\'' || ch == '"' || ch == '`':
+			end := strings.IndexByte(query[i+1:], ch)
+			if end < 0 {
+				return ended
+			}
+			if ended {
+				return true
+			}
+			i += end + 1
+		case strings.HasPrefix(query[i:], "--") || ch == '#':
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				return false
+			}
+			i += end
+		case strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return false
+			}
+			i += end + 3
+		case ch == ';':
+			ended = true
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+		default:
+			if ended {
+				return true
+			}
+		}
+	}
+	return false
+}
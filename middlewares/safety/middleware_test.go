@@ -0,0 +1,169 @@
+package safety
+
+import (
+	"context"
+	"database/sql"
+	go_orm "github.com/Andras5014/go-orm"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type TestModel struct {
+	Id        int
+	FirstName string
+	Age       int
+	LastName  *sql.NullString
+}
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	testCases := []struct {
+		name    string
+		mb      *MiddlewareBuilder
+		mock    func(mock sqlmock.Sqlmock)
+		query   func(db *go_orm.DB) error
+		wantErr error
+	}{
+		{
+			name: "delete without where",
+			mb:   NewMiddlewareBuilder(),
+			query: func(db *go_orm.DB) error {
+				return go_orm.NewDeleter[TestModel](db).Exec(context.Background()).Err()
+			},
+			wantErr: ErrNoWhere,
+		},
+		{
+			name: "delete with where",
+			mb:   NewMiddlewareBuilder(),
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("DELETE .*").WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			query: func(db *go_orm.DB) error {
+				return go_orm.NewDeleter[TestModel](db).Where(go_orm.C("Id").Eq(1)).Exec(context.Background()).Err()
+			},
+		},
+		{
+			name: "delete allow full table scan",
+			mb:   NewMiddlewareBuilder(),
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("DELETE .*").WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			query: func(db *go_orm.DB) error {
+				return go_orm.NewDeleter[TestModel](db).AllowFullTableScan().Exec(context.Background()).Err()
+			},
+		},
+		{
+			name: "update without where",
+			mb:   NewMiddlewareBuilder(),
+			query: func(db *go_orm.DB) error {
+				return go_orm.NewUpdater[TestModel](db).Set(go_orm.Assign("Age", 18)).Exec(context.Background()).Err()
+			},
+			wantErr: ErrNoWhere,
+		},
+		{
+			name: "update table allowed",
+			mb:   NewMiddlewareBuilder().AllowNoWhere("test_model"),
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("UPDATE .*").WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			query: func(db *go_orm.DB) error {
+				return go_orm.NewUpdater[TestModel](db).Set(go_orm.Assign("Age", 18)).Exec(context.Background()).Err()
+			},
+		},
+		{
+			name: "select large table without limit",
+			mb:   NewMiddlewareBuilder().LargeTables("test_model"),
+			query: func(db *go_orm.DB) error {
+				_, err := go_orm.NewSelector[TestModel](db).Where(go_orm.C("Id").Eq(1)).GetMulti(context.Background())
+				return err
+			},
+			wantErr: ErrNoLimit,
+		},
+		{
+			name: "select large table with limit",
+			mb:   NewMiddlewareBuilder().LargeTables("test_model"),
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT .*").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			},
+			query: func(db *go_orm.DB) error {
+				_, err := go_orm.NewSelector[TestModel](db).Limit(10).GetMulti(context.Background())
+				return err
+			},
+		},
+		{
+			name: "select small table without limit",
+			mb:   NewMiddlewareBuilder().LargeTables("order"),
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT .*").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			},
+			query: func(db *go_orm.DB) error {
+				_, err := go_orm.NewSelector[TestModel](db).GetMulti(context.Background())
+				return err
+			},
+		},
+		{
+			name: "raw multiple statements",
+			mb:   NewMiddlewareBuilder(),
+			query: func(db *go_orm.DB) error {
+				return go_orm.RawQuery[TestModel](db, "DELETE FROM test_model WHERE id = 1; DROP TABLE test_model").
+					Exec(context.Background()).Err()
+			},
+			wantErr: ErrMultipleStatements,
+		},
+		{
+			name: "raw single statement",
+			mb:   NewMiddlewareBuilder(),
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("DELETE .*").WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			query: func(db *go_orm.DB) error {
+				return go_orm.RawQuery[TestModel](db, "DELETE FROM test_model WHERE first_name = 'a;b'; -- x;y").
+					Exec(context.Background()).Err()
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer func() {
+				_ = mockDB.Close()
+			}()
+			if tc.mock != nil {
+				tc.mock(mock)
+			}
+			db, err := go_orm.OpenDB(mockDB, go_orm.DBWithMiddlewares(tc.mb.Build()))
+			require.NoError(t, err)
+			err = tc.query(db)
+			assert.ErrorIs(t, err, tc.wantErr)
+			if tc.wantErr == nil {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestMultipleStatements(t *testing.T) {
+	testCases := []struct {
+		query string
+		want  bool
+	}{
+		{query: "SELECT 1", want: false},
+		{query: "SELECT 1;", want: false},
+		{query: "SELECT 1;  \n", want: false},
+		{query: "SELECT ';'", want: false},
+		{query: "SELECT `a;b` FROM t", want: false},
+		{query: "SELECT 1 /* ; */", want: false},
+		{query: "SELECT 1; -- comment", want: false},
+		{query: "SELECT 1; SELECT 2", want: true},
+		{query: "SELECT 1;'x'", want: true},
+		{query: "SELECT 1 /* x */; DROP TABLE t", want: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			assert.Equal(t, tc.want, multipleStatements(tc.query))
+		})
+	}
+}
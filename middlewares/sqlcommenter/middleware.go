@@ -0,0 +1,114 @@
+package sqlcommenter
+
+import (
+	"context"
+	"fmt"
+	go_orm "github.com/Andras5014/go-orm"
+	"github.com/Andras5014/go-orm/internal/sqlcomment"
+	"go.opentelemetry.io/otel/trace"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+type tagsKey struct{}
+
+// WithTag 在 context 中加入标签，例如 route、controller
+func WithTag(ctx context.Context, key, value string) context.Context {
+	old, _ := ctx.Value(tagsKey{}).(map[string]string)
+	tags := make(map[string]string, len(old)+1)
+	for k, v := range old {
+		tags[k] = v
+	}
+	tags[key] = value
+	return context.WithValue(ctx, tagsKey{}, tags)
+}
+
+// MiddlewareBuilder 按照 sqlcommenter 的格式在 SQL 末尾追加注释
+// 例如 SELECT * FROM `user` /*app='order',route='%2Fusers',traceparent='00-...-01'*/;
+// 注释包括当前 span 的 traceparent、app 以及 WithTag 放入的标签
+// traceparent 每次都不同，预编译语句缓存和 cache 中间件计算 key 时会去掉注释；
+// 开启 DBWithStmtCache 时预编译的是去掉注释的 SQL，注释不会发送到数据库
+type MiddlewareBuilder struct {
+	app  string
+	tags func(ctx context.Context) map[string]string
+}
+
+func NewMiddlewareBuilder() *MiddlewareBuilder {
+	return &MiddlewareBuilder{}
+}
+
+// App 应用名，对应注释中的 app
+func (m *MiddlewareBuilder) App(app string) *MiddlewareBuilder {
+	m.app = app
+	return m
+}
+
+// Tags 从 context 中获取额外的标签，和 WithTag 放入的标签合并
+func (m *MiddlewareBuilder) Tags(fn func(ctx context.Context) map[string]string) *MiddlewareBuilder {
+	m.tags = fn
+	return m
+}
+
+func (m *MiddlewareBuilder) Build() go_orm.Middleware {
+	return func(next go_orm.Handler) go_orm.Handler {
+		return func(ctx context.Context, qc *go_orm.QueryContext) *go_orm.QueryResult {
+			q, err := qc.Query()
+			if err != nil {
+				return next(ctx, qc)
+			}
+			if comment := m.comment(ctx); comment != "" {
+				q.SQL = sqlcomment.Append(q.SQL, comment)
+			}
+			return next(ctx, qc)
+		}
+	}
+}
+
+func (m *MiddlewareBuilder) comment(ctx context.Context) string {
+	tags := make(map[string]string, 4)
+	if m.tags != nil {
+		for k, v := range m.tags(ctx) {
+			tags[k] = v
+		}
+	}
+	ctxTags, _ := ctx.Value(tagsKey{}).(map[string]string)
+	for k, v := range ctxTags {
+		tags[k] = v
+	}
+	if m.app != "" {
+		tags["app"] = m.app
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		tags["traceparent"] = fmt.Sprintf("00-%s-%s-%s", sc.TraceID(), sc.SpanID(), sc.TraceFlags())
+		if ts := sc.TraceState().String(); ts != "" {
+			tags["tracestate"] = ts
+		}
+	}
+	if len(tags) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	sb.WriteString("/*")
+	for i, k := range keys {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(encode(k))
+		sb.WriteString("='")
+		sb.WriteString(encode(tags[k]))
+		sb.WriteByte('\'')
+	}
+	sb.WriteString("*/")
+	return sb.String()
+}
+
+// encode URL 编码，单引号编码为 %27，空格编码为 %20
+func encode(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
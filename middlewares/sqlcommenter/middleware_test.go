@@ -0,0 +1,74 @@
+package sqlcommenter
+
+import (
+	"context"
+	"database/sql"
+	go_orm "github.com/Andras5014/go-orm"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	"testing"
+)
+
+type TestModel struct {
+	Id        int
+	FirstName string
+	Age       int
+	LastName  *sql.NullString
+}
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	traceID, err := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	require.NoError(t, err)
+	spanID, err := trace.SpanIDFromHex("00f067aa0ba902b7")
+	require.NoError(t, err)
+	spanCtx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	testCases := []struct {
+		name    string
+		mb      *MiddlewareBuilder
+		ctx     context.Context
+		wantSQL string
+	}{
+		{
+			name:    "no tags",
+			mb:      NewMiddlewareBuilder(),
+			ctx:     context.Background(),
+			wantSQL: "SELECT * FROM `test_model` WHERE `id` = ?;",
+		},
+		{
+			name: "trace and tags",
+			mb: NewMiddlewareBuilder().App("order").Tags(func(ctx context.Context) map[string]string {
+				return map[string]string{"framework": "gin", "route": "default"}
+			}),
+			ctx:     WithTag(spanCtx, "route", "/users/:id"),
+			wantSQL: "SELECT * FROM `test_model` WHERE `id` = ? /*app='order',framework='gin',route='%2Fusers%2F%3Aid',traceparent='00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01'*/;",
+		},
+		{
+			name:    "escape",
+			mb:      NewMiddlewareBuilder(),
+			ctx:     WithTag(context.Background(), "name", "it's a b"),
+			wantSQL: "SELECT * FROM `test_model` WHERE `id` = ? /*name='it%27s%20a%20b'*/;",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			require.NoError(t, err)
+			defer func() {
+				_ = mockDB.Close()
+			}()
+			db, err := go_orm.OpenDB(mockDB, go_orm.DBWithMiddlewares(tc.mb.Build()))
+			require.NoError(t, err)
+			mock.ExpectQuery(tc.wantSQL).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			_, err = go_orm.NewSelector[TestModel](db).Where(go_orm.C("Id").Eq(1)).Get(tc.ctx)
+			require.NoError(t, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -1,28 +1,64 @@
 package model
 
 import (
+	"database/sql"
 	"github.com/Andras5014/go-orm/internal/errs"
+	"github.com/Andras5014/go-orm/serializer"
 	"reflect"
 	"strings"
 	"sync"
+	"time"
 	"unicode"
 )
 
 const (
-	tagKeyColumn = "column"
+	tagKeyColumn         = "column"
+	tagKeySoftDelete     = "soft_delete"
+	tagKeyAutoCreateTime = "auto_create_time"
+	tagKeyAutoUpdateTime = "auto_update_time"
+	tagKeySerializer     = "serializer"
+	tagKeyEncrypt        = "encrypt"
+	tagKeyBlindIndex     = "blind_index"
+	tagKeyTenant         = "tenant"
 )
 
+// EncryptSerializer encrypt 标签使用的编解码器名字
+// 通过 RegistryWithSerializer 或者 serializer.Register 注册
+const EncryptSerializer = "encrypt"
+
+// 整数类型的自动时间字段的精度
+// 例如 `orm:"auto_create_time:milli"`
+const (
+	TimeUnitSecond = "second"
+	TimeUnitMilli  = "milli"
+)
+
+// flagTags 不需要值的标签
+var flagTags = map[string]struct{}{
+	tagKeySoftDelete:     {},
+	tagKeyAutoCreateTime: {},
+	tagKeyAutoUpdateTime: {},
+	tagKeyEncrypt:        {},
+	tagKeyTenant:         {},
+}
+
 type Registry interface {
 	Get(entity any) (*Model, error)
 	Register(entity any, opts ...Option) (*Model, error)
 }
 type Model struct {
 	TableName string
-	Fields    []*Field
+	// Type 模型对应的结构体类型
+	Type   reflect.Type
+	Fields []*Field
 	// 字段名 -> 字段
 	FieldMap map[string]*Field
 	// 列名 -> 字段
 	ColumnMap map[string]*Field
+	// SoftDeleteField 软删除字段，nil 表示该模型不支持软删除
+	SoftDeleteField *Field
+	// TenantField 租户字段，nil 表示该模型不区分租户
+	TenantField *Field
 }
 
 type Option func(model *Model) error
@@ -36,6 +72,20 @@ type Field struct {
 
 	// 字段相对于结构体偏移量
 	Offset uintptr
+
+	// AutoCreateTime 插入时自动填充当前时间
+	AutoCreateTime bool
+	// AutoUpdateTime 插入和更新时自动填充当前时间
+	AutoUpdateTime bool
+	// TimeUnit 整数类型的自动时间字段的精度，默认为 TimeUnitSecond
+	TimeUnit string
+
+	// Serializer 列值的编解码器，nil 表示直接读写字段
+	Serializer serializer.Serializer
+	// Encrypt 字段是否加密存储
+	Encrypt bool
+	// BlindIndex 加密字段对应的盲索引字段，用于等值查询
+	BlindIndex *Field
 }
 
 //var defaultRegistry = &registry{
@@ -45,12 +95,52 @@ type Field struct {
 // registry 元数据注册中心
 type registry struct {
 	models sync.Map
+	naming NamingStrategy
+	// serializers 优先于全局注册的编解码器
+	serializers map[string]serializer.Serializer
 	//lock   sync.RWMutex
 	//models map[reflect.Type]*Model
 }
 
-func NewRegistry() Registry {
-	return &registry{}
+type RegistryOption func(r *registry)
+
+func NewRegistry(opts ...RegistryOption) Registry {
+	res := &registry{
+		naming: UnderscoreNaming{},
+	}
+	for _, opt := range opts {
+		opt(res)
+	}
+	return res
+}
+
+// RegistryWithSerializer 注册只在该 registry 中生效的编解码器
+// 例如加密字段使用的 EncryptSerializer
+func RegistryWithSerializer(name string, s serializer.Serializer) RegistryOption {
+	return func(r *registry) {
+		if r.serializers == nil {
+			r.serializers = make(map[string]serializer.Serializer, 2)
+		}
+		r.serializers[name] = s
+	}
+}
+
+func (r *registry) serializer(name string) (serializer.Serializer, error) {
+	if s, ok := r.serializers[name]; ok {
+		return s, nil
+	}
+	s, ok := serializer.Get(name)
+	if !ok {
+		return nil, errs.NewErrUnknownSerializer(name)
+	}
+	return s, nil
+}
+
+// RegistryWithNamingStrategy 指定表名和列名的命名策略，默认为 UnderscoreNaming
+func RegistryWithNamingStrategy(ns NamingStrategy) RegistryOption {
+	return func(r *registry) {
+		r.naming = ns
+	}
 }
 
 func (r *registry) Get(entity any) (*Model, error) {
@@ -106,6 +196,9 @@ func (r *registry) Register(entity any, opts ...Option) (*Model, error) {
 	fieldMap := make(map[string]*Field, numField)
 	columnMap := make(map[string]*Field, numField)
 	fields := make([]*Field, 0, numField)
+	var softDeleteField, tenantField *Field
+	// 加密字段名 -> 盲索引字段
+	blindIndexes := make(map[string]*Field)
 	for i := 0; i < numField; i++ {
 		fd := elemTyp.Field(i)
 		pairTag, err := r.parseTag(fd.Tag)
@@ -115,7 +208,7 @@ func (r *registry) Register(entity any, opts ...Option) (*Model, error) {
 		colName := pairTag[tagKeyColumn]
 		if colName == "" {
 			// 如果没设置column
-			colName = underscoreName(fd.Name)
+			colName = r.naming.ColumnName(fd.Name)
 		}
 		fdMeta := &Field{
 			ColName: colName,
@@ -123,23 +216,70 @@ func (r *registry) Register(entity any, opts ...Option) (*Model, error) {
 			GoName:  fd.Name,
 			Offset:  fd.Offset,
 		}
+		if _, ok := pairTag[tagKeySoftDelete]; ok {
+			if softDeleteField != nil {
+				return nil, errs.ErrMultipleSoftDeleteField
+			}
+			if !isSoftDeleteType(fd.Type) {
+				return nil, errs.NewErrUnsupportedSoftDeleteType(fd.Type)
+			}
+			softDeleteField = fdMeta
+		}
+		if _, ok := pairTag[tagKeyTenant]; ok {
+			if tenantField != nil {
+				return nil, errs.ErrMultipleTenantField
+			}
+			tenantField = fdMeta
+		}
+		if err = parseAutoTime(fdMeta, pairTag); err != nil {
+			return nil, err
+		}
+		if name, ok := pairTag[tagKeySerializer]; ok {
+			fdMeta.Serializer, err = r.serializer(name)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if _, ok := pairTag[tagKeyEncrypt]; ok {
+			if fdMeta.Serializer != nil {
+				return nil, errs.NewErrInvalidTagContent(tagKeyEncrypt)
+			}
+			fdMeta.Serializer, err = r.serializer(EncryptSerializer)
+			if err != nil {
+				return nil, err
+			}
+			fdMeta.Encrypt = true
+		}
+		if src, ok := pairTag[tagKeyBlindIndex]; ok {
+			blindIndexes[src] = fdMeta
+		}
 		fieldMap[fd.Name] = fdMeta
 		columnMap[colName] = fdMeta
 		fields = append(fields, fdMeta)
 	}
+	for src, idx := range blindIndexes {
+		srcField, ok := fieldMap[src]
+		if !ok || !srcField.Encrypt || idx.Typ.Kind() != reflect.String {
+			return nil, errs.NewErrInvalidTagContent(tagKeyBlindIndex + ":" + src)
+		}
+		srcField.BlindIndex = idx
+	}
 	var tableName string
 	if tbname, ok := entity.(TableName); ok {
 		tableName = tbname.TableName()
 	}
 	if tableName == "" {
-		tableName = underscoreName(elemTyp.Name())
+		tableName = r.naming.TableName(elemTyp.Name())
 	}
 
 	res := &Model{
-		TableName: tableName,
-		FieldMap:  fieldMap,
-		ColumnMap: columnMap,
-		Fields:    fields,
+		TableName:       tableName,
+		Type:            elemTyp,
+		FieldMap:        fieldMap,
+		ColumnMap:       columnMap,
+		Fields:          fields,
+		SoftDeleteField: softDeleteField,
+		TenantField:     tenantField,
 	}
 	for _, opt := range opts {
 		err := opt(res)
@@ -181,6 +321,12 @@ func (r *registry) parseTag(tag reflect.StructTag) (map[string]string, error) {
 	res := make(map[string]string, len(pairs))
 	for _, pair := range pairs {
 		segs := strings.Split(pair, ":")
+		if len(segs) == 1 {
+			if _, ok := flagTags[segs[0]]; ok {
+				res[segs[0]] = ""
+				continue
+			}
+		}
 		if len(segs) != 2 {
 			return nil, errs.NewErrInvalidTagContent(pair)
 		}
@@ -193,20 +339,81 @@ func (r *registry) parseTag(tag reflect.StructTag) (map[string]string, error) {
 
 // underscoreName 将驼峰命名转换为下划线命名
 func underscoreName(tableName string) string {
-	var buf []byte
+	var buf []rune
 	for i, V := range tableName {
 		if unicode.IsUpper(V) {
 			if i != 0 {
 				buf = append(buf, '_')
 			}
-			buf = append(buf, byte(unicode.ToLower(V)))
+			buf = append(buf, unicode.ToLower(V))
 		} else {
-			buf = append(buf, byte(V))
+			buf = append(buf, V)
 		}
 	}
 	return string(buf)
 }
 
+// parseAutoTime 解析 auto_create_time 和 auto_update_time 标签
+func parseAutoTime(fd *Field, pairTag map[string]string) error {
+	for _, key := range []string{tagKeyAutoCreateTime, tagKeyAutoUpdateTime} {
+		unit, ok := pairTag[key]
+		if !ok {
+			continue
+		}
+		if !isAutoTimeType(fd.Typ) {
+			return errs.NewErrUnsupportedAutoTimeType(fd.Typ)
+		}
+		switch unit {
+		case "":
+		case TimeUnitSecond, TimeUnitMilli:
+			fd.TimeUnit = unit
+		default:
+			return errs.NewErrInvalidTagContent(key + ":" + unit)
+		}
+		if key == tagKeyAutoCreateTime {
+			fd.AutoCreateTime = true
+		} else {
+			fd.AutoUpdateTime = true
+		}
+	}
+	return nil
+}
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	timePtrType     = reflect.TypeOf(&time.Time{})
+	nullTimeType    = reflect.TypeOf(sql.NullTime{})
+	nullTimePtrType = reflect.TypeOf(&sql.NullTime{})
+)
+
+// isSoftDeleteType 软删除字段只支持可为 NULL 的时间类型，以及 bool 和整数标记
+func isSoftDeleteType(typ reflect.Type) bool {
+	switch typ {
+	case timePtrType, nullTimeType, nullTimePtrType:
+		return true
+	}
+	switch typ.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// isAutoTimeType 自动时间字段支持时间类型和 unix 时间戳整数
+func isAutoTimeType(typ reflect.Type) bool {
+	switch typ {
+	case timeType, timePtrType, nullTimeType, nullTimePtrType:
+		return true
+	}
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
 type TableName interface {
 	TableName() string
 }
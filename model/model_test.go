@@ -3,10 +3,12 @@ package model
 import (
 	"database/sql"
 	"github.com/Andras5014/go-orm/internal/errs"
+	"github.com/Andras5014/go-orm/serializer"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestRegistry_Register(t *testing.T) {
@@ -85,6 +87,7 @@ func TestRegistry_Register(t *testing.T) {
 			}
 			tc.wantModel.FieldMap = fieldMap
 			tc.wantModel.ColumnMap = columnMap
+			tc.wantModel.Type = reflect.TypeOf(tc.entity).Elem()
 			assert.EqualValues(t, tc.wantModel, m)
 
 		})
@@ -198,6 +201,160 @@ func TestRegistry_get(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "soft delete",
+			entity: func() any {
+				type SoftDeleteTable struct {
+					DeletedAt *time.Time `orm:"column:deleted_at_t,soft_delete"`
+				}
+				return &SoftDeleteTable{}
+			}(),
+			wantModel: func() *Model {
+				fd := &Field{
+					ColName: "deleted_at_t",
+					GoName:  "DeletedAt",
+					Typ:     reflect.TypeOf(&time.Time{}),
+				}
+				return &Model{
+					TableName:       "soft_delete_table",
+					Fields:          []*Field{fd},
+					SoftDeleteField: fd,
+				}
+			}(),
+		},
+		{
+			name: "multiple soft delete",
+			entity: func() any {
+				type SoftDeleteTable struct {
+					DeletedAt *time.Time `orm:"soft_delete"`
+					Deleted   bool       `orm:"soft_delete"`
+				}
+				return &SoftDeleteTable{}
+			}(),
+			wantErr: errs.ErrMultipleSoftDeleteField,
+		},
+		{
+			name: "invalid soft delete type",
+			entity: func() any {
+				type SoftDeleteTable struct {
+					DeletedAt time.Time `orm:"soft_delete"`
+				}
+				return &SoftDeleteTable{}
+			}(),
+			wantErr: errs.NewErrUnsupportedSoftDeleteType(reflect.TypeOf(time.Time{})),
+		},
+		{
+			name: "auto time",
+			entity: func() any {
+				type AutoTimeTable struct {
+					CreatedAt time.Time `orm:"auto_create_time"`
+					UpdatedAt int64     `orm:"auto_update_time:milli"`
+				}
+				return &AutoTimeTable{}
+			}(),
+			wantModel: &Model{
+				TableName: "auto_time_table",
+				Fields: []*Field{
+					{
+						ColName:        "created_at",
+						GoName:         "CreatedAt",
+						Typ:            reflect.TypeOf(time.Time{}),
+						AutoCreateTime: true,
+					},
+					{
+						ColName:        "updated_at",
+						GoName:         "UpdatedAt",
+						Typ:            reflect.TypeOf(int64(0)),
+						Offset:         24,
+						AutoUpdateTime: true,
+						TimeUnit:       TimeUnitMilli,
+					},
+				},
+			},
+		},
+		{
+			name: "invalid auto time unit",
+			entity: func() any {
+				type AutoTimeTable struct {
+					UpdatedAt int64 `orm:"auto_update_time:nano"`
+				}
+				return &AutoTimeTable{}
+			}(),
+			wantErr: errs.NewErrInvalidTagContent("auto_update_time:nano"),
+		},
+		{
+			name: "invalid auto time type",
+			entity: func() any {
+				type AutoTimeTable struct {
+					UpdatedAt string `orm:"auto_update_time"`
+				}
+				return &AutoTimeTable{}
+			}(),
+			wantErr: errs.NewErrUnsupportedAutoTimeType(reflect.TypeOf("")),
+		},
+		{
+			name: "unknown serializer",
+			entity: func() any {
+				type SerializerTable struct {
+					Attrs map[string]string `orm:"serializer:xml"`
+				}
+				return &SerializerTable{}
+			}(),
+			wantErr: errs.NewErrUnknownSerializer("xml"),
+		},
+		{
+			name: "encrypt without serializer",
+			entity: func() any {
+				type EncryptTable struct {
+					Email string `orm:"encrypt"`
+				}
+				return &EncryptTable{}
+			}(),
+			wantErr: errs.NewErrUnknownSerializer(EncryptSerializer),
+		},
+		{
+			name: "blind index without encrypt",
+			entity: func() any {
+				type EncryptTable struct {
+					Email    string
+					EmailIdx string `orm:"blind_index:Email"`
+				}
+				return &EncryptTable{}
+			}(),
+			wantErr: errs.NewErrInvalidTagContent("blind_index:Email"),
+		},
+		{
+			name: "tenant",
+			entity: func() any {
+				type TenantTable struct {
+					TenantId int64 `orm:"column:tenant_id_t,tenant"`
+				}
+				return &TenantTable{}
+			}(),
+			wantModel: func() *Model {
+				fd := &Field{
+					ColName: "tenant_id_t",
+					GoName:  "TenantId",
+					Typ:     reflect.TypeOf(int64(0)),
+				}
+				return &Model{
+					TableName:   "tenant_table",
+					Fields:      []*Field{fd},
+					TenantField: fd,
+				}
+			}(),
+		},
+		{
+			name: "multiple tenant",
+			entity: func() any {
+				type TenantTable struct {
+					TenantId int64  `orm:"tenant"`
+					OrgId    string `orm:"tenant"`
+				}
+				return &TenantTable{}
+			}(),
+			wantErr: errs.ErrMultipleTenantField,
+		},
 		{
 			name:   "table name",
 			entity: &CustomTableName{},
@@ -243,6 +400,7 @@ func TestRegistry_get(t *testing.T) {
 			}
 			tc.wantModel.FieldMap = fieldMap
 			tc.wantModel.ColumnMap = columnMap
+			tc.wantModel.Type = reflect.TypeOf(tc.entity).Elem()
 			assert.EqualValues(t, tc.wantModel, m)
 			//assert.Equal(t, tc.cacheSize, r.models.Range)
 
@@ -326,3 +484,18 @@ type TestModel struct {
 	Age       int8
 	LastName  *sql.NullString
 }
+
+func TestRegistryWithSerializer(t *testing.T) {
+	type EncryptTable struct {
+		Email    string `orm:"encrypt"`
+		EmailIdx string `orm:"blind_index:Email"`
+	}
+	s := serializer.JSON{}
+	r := NewRegistry(RegistryWithSerializer(EncryptSerializer, s))
+	m, err := r.Get(&EncryptTable{})
+	require.NoError(t, err)
+	email := m.FieldMap["Email"]
+	assert.True(t, email.Encrypt)
+	assert.Equal(t, s, email.Serializer)
+	assert.Equal(t, m.FieldMap["EmailIdx"], email.BlindIndex)
+}
@@ -0,0 +1,125 @@
+package model
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NamingStrategy 决定结构体名和字段名如何映射为表名和列名
+// 通过 TableName 接口或者 column 标签显式指定的名字不受影响
+type NamingStrategy interface {
+	TableName(structName string) string
+	ColumnName(fieldName string) string
+}
+
+// UnderscoreNaming 默认的命名策略，每个大写字母前都加下划线
+// 例如 UserID 会被映射为 user_i_d
+type UnderscoreNaming struct{}
+
+func (UnderscoreNaming) TableName(structName string) string {
+	return underscoreName(structName)
+}
+
+func (UnderscoreNaming) ColumnName(fieldName string) string {
+	return underscoreName(fieldName)
+}
+
+// SnakeCaseNaming 能够识别缩写词的蛇形命名
+// 例如 UserID 映射为 user_id，HTTPServer 映射为 http_server
+type SnakeCaseNaming struct{}
+
+func (SnakeCaseNaming) TableName(structName string) string {
+	return snakeCase(structName)
+}
+
+func (SnakeCaseNaming) ColumnName(fieldName string) string {
+	return snakeCase(fieldName)
+}
+
+// NamingFunc 用自定义方法命名，Table 和 Column 为 nil 时使用 UnderscoreNaming
+type NamingFunc struct {
+	Table  func(structName string) string
+	Column func(fieldName string) string
+}
+
+func (n NamingFunc) TableName(structName string) string {
+	if n.Table == nil {
+		return underscoreName(structName)
+	}
+	return n.Table(structName)
+}
+
+func (n NamingFunc) ColumnName(fieldName string) string {
+	if n.Column == nil {
+		return underscoreName(fieldName)
+	}
+	return n.Column(fieldName)
+}
+
+// TablePrefix 在 ns 生成的表名前加上前缀
+func TablePrefix(prefix string, ns NamingStrategy) NamingStrategy {
+	return tableNaming{
+		NamingStrategy: ns,
+		fn: func(name string) string {
+			return prefix + name
+		},
+	}
+}
+
+// PluralTable 将 ns 生成的表名转换为英文复数形式
+func PluralTable(ns NamingStrategy) NamingStrategy {
+	return tableNaming{
+		NamingStrategy: ns,
+		fn:             plural,
+	}
+}
+
+// tableNaming 在原有命名策略的基础上改写表名
+type tableNaming struct {
+	NamingStrategy
+	fn func(name string) string
+}
+
+func (t tableNaming) TableName(structName string) string {
+	return t.fn(t.NamingStrategy.TableName(structName))
+}
+
+// snakeCase 驼峰转蛇形，连续的大写字母视为一个单词
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var sb strings.Builder
+	sb.Grow(len(name) + 4)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				prev := runes[i-1]
+				nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+				if (!unicode.IsUpper(prev) && prev != '_') ||
+					(unicode.IsUpper(prev) && nextLower) {
+					sb.WriteByte('_')
+				}
+			}
+			sb.WriteRune(unicode.ToLower(r))
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// plural 简单的英文复数规则
+func plural(name string) string {
+	switch {
+	case name == "":
+		return name
+	case strings.HasSuffix(name, "s"), strings.HasSuffix(name, "x"),
+		strings.HasSuffix(name, "z"), strings.HasSuffix(name, "ch"),
+		strings.HasSuffix(name, "sh"):
+		return name + "es"
+	case strings.HasSuffix(name, "y") && len(name) > 1 &&
+		!strings.ContainsRune("aeiou", rune(name[len(name)-2])):
+		return name[:len(name)-1] + "ies"
+	default:
+		return name + "s"
+	}
+}
@@ -0,0 +1,128 @@
+package model
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestNamingStrategy(t *testing.T) {
+	testCases := []struct {
+		name       string
+		ns         NamingStrategy
+		input      string
+		wantTable  string
+		wantColumn string
+	}{
+		{
+			name:       "underscore",
+			ns:         UnderscoreNaming{},
+			input:      "UserID",
+			wantTable:  "user_i_d",
+			wantColumn: "user_i_d",
+		},
+		{
+			name:       "underscore non-ascii",
+			ns:         UnderscoreNaming{},
+			input:      "ÜberName",
+			wantTable:  "über_name",
+			wantColumn: "über_name",
+		},
+		{
+			name:       "snake case acronym",
+			ns:         SnakeCaseNaming{},
+			input:      "UserID",
+			wantTable:  "user_id",
+			wantColumn: "user_id",
+		},
+		{
+			name:       "snake case leading acronym",
+			ns:         SnakeCaseNaming{},
+			input:      "HTTPServer",
+			wantTable:  "http_server",
+			wantColumn: "http_server",
+		},
+		{
+			name:       "snake case digit",
+			ns:         SnakeCaseNaming{},
+			input:      "OrderV2Item",
+			wantTable:  "order_v2_item",
+			wantColumn: "order_v2_item",
+		},
+		{
+			name:       "snake case non-ascii",
+			ns:         SnakeCaseNaming{},
+			input:      "Über用户Name",
+			wantTable:  "über用户_name",
+			wantColumn: "über用户_name",
+		},
+		{
+			name:       "table prefix",
+			ns:         TablePrefix("t_", SnakeCaseNaming{}),
+			input:      "UserID",
+			wantTable:  "t_user_id",
+			wantColumn: "user_id",
+		},
+		{
+			name:       "plural",
+			ns:         PluralTable(SnakeCaseNaming{}),
+			input:      "Category",
+			wantTable:  "categories",
+			wantColumn: "category",
+		},
+		{
+			name:       "plural es",
+			ns:         PluralTable(SnakeCaseNaming{}),
+			input:      "Address",
+			wantTable:  "addresses",
+			wantColumn: "address",
+		},
+		{
+			name:       "plural key",
+			ns:         PluralTable(SnakeCaseNaming{}),
+			input:      "ApiKey",
+			wantTable:  "api_keys",
+			wantColumn: "api_key",
+		},
+		{
+			name:       "prefix and plural",
+			ns:         TablePrefix("t_", PluralTable(SnakeCaseNaming{})),
+			input:      "User",
+			wantTable:  "t_users",
+			wantColumn: "user",
+		},
+		{
+			name: "custom func",
+			ns: NamingFunc{
+				Table: strings.ToUpper,
+			},
+			input:      "UserID",
+			wantTable:  "USERID",
+			wantColumn: "user_i_d",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.wantTable, tc.ns.TableName(tc.input))
+			assert.Equal(t, tc.wantColumn, tc.ns.ColumnName(tc.input))
+		})
+	}
+}
+
+func TestRegistryWithNamingStrategy(t *testing.T) {
+	type UserInfo struct {
+		UserID   int64
+		NickName string `orm:"column:nick"`
+	}
+	r := NewRegistry(RegistryWithNamingStrategy(TablePrefix("t_", SnakeCaseNaming{})))
+	m, err := r.Get(&UserInfo{})
+	require.NoError(t, err)
+	assert.Equal(t, "t_user_info", m.TableName)
+	assert.Equal(t, "user_id", m.FieldMap["UserID"].ColName)
+	assert.Equal(t, "nick", m.FieldMap["NickName"].ColName)
+
+	m, err = r.Get(&CustomTableName{})
+	require.NoError(t, err)
+	assert.Equal(t, "custom_table_name_t", m.TableName)
+}
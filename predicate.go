@@ -9,6 +9,15 @@ const (
 	opNot op = "NOT"
 	opAnd op = "AND"
 	opOr  op = "OR"
+
+	opIsNull op = "IS NULL"
+	opIn     op = "IN"
+
+	opAdd op = "+"
+	opSub op = "-"
+	opMul op = "*"
+	opDiv op = "/"
+	opMod op = "%"
 )
 
 func (o op) String() string {
@@ -33,14 +42,22 @@ func (c Column) Lt(arg any) Predicate {
 	return Predicate{
 		left:  c,
 		op:    opLt,
-		right: value{arg: arg},
+		right: valueOf(arg),
 	}
 }
 func (c Column) Gt(arg any) Predicate {
 	return Predicate{
 		left:  c,
 		op:    opGt,
-		right: value{arg: arg},
+		right: valueOf(arg),
+	}
+}
+
+// IsNull 构造 col IS NULL
+func (c Column) IsNull() Predicate {
+	return Predicate{
+		left: c,
+		op:   opIsNull,
 	}
 }
 
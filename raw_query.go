@@ -2,7 +2,6 @@ package go_orm
 
 import (
 	"context"
-	"database/sql"
 )
 
 type RawQuerier[T any] struct {
@@ -29,6 +28,9 @@ func RawQuery[T any](sess Session, query string, args ...any) *RawQuerier[T] {
 	}
 }
 func (i *RawQuerier[T]) Exec(ctx context.Context) Result {
+	// 在副本上执行，同一个 RawQuerier 可以并发执行
+	cp := *i
+	i = &cp
 	var err error
 	i.model, err = i.r.Get(new(T))
 	if err != nil {
@@ -47,11 +49,12 @@ func (i *RawQuerier[T]) Exec(ctx context.Context) Result {
 	}
 	return Result{
 		err: res.Err,
-		res: res.Result.(sql.Result),
 	}
 }
 
 func (s *RawQuerier[T]) Get(ctx context.Context) (*T, error) {
+	cp := *s
+	s = &cp
 	var err error
 	s.model, err = s.r.Get(new(T))
 	if err != nil {
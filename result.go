@@ -11,6 +11,10 @@ func (r Result) LastInsertId() (int64, error) {
 	if r.err != nil {
 		return 0, r.err
 	}
+	// 例如被中间件丢弃的结果
+	if r.res == nil {
+		return 0, nil
+	}
 	return r.res.LastInsertId()
 }
 
@@ -18,6 +22,9 @@ func (r Result) RowsAffected() (int64, error) {
 	if r.err != nil {
 		return 0, r.err
 	}
+	if r.res == nil {
+		return 0, nil
+	}
 	return r.res.RowsAffected()
 }
 func (r Result) Err() error {
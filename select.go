@@ -3,6 +3,8 @@ package go_orm
 import (
 	"context"
 	"github.com/Andras5014/go-orm/internal/errs"
+	"github.com/Andras5014/go-orm/model"
+	"slices"
 )
 
 // Selectable 是一个标记接口
@@ -20,6 +22,8 @@ type Selector[T any] struct {
 	orderBys []OrderBy
 	offset   int
 	limit    int
+	// unscoped 不追加软删除等自动过滤条件
+	unscoped bool
 
 	sess Session
 	//r *registry
@@ -35,7 +39,14 @@ func NewSelector[T any](sess Session) *Selector[T] {
 		sess: sess,
 	}
 }
+
+// Build 在副本上构造 SQL，不会修改 Selector 本身，可以重复调用，也可以并发调用
 func (s *Selector[T]) Build() (*Query, error) {
+	b := *s
+	return b.build()
+}
+
+func (s *Selector[T]) build() (*Query, error) {
 	if s.model == nil {
 		var err error
 		s.model, err = s.r.Get(new(T))
@@ -44,21 +55,27 @@ func (s *Selector[T]) Build() (*Query, error) {
 		}
 	}
 
+	if err := s.checkTenant(); err != nil {
+		return nil, err
+	}
+
+	if err := s.buildWith(); err != nil {
+		return nil, err
+	}
 	s.sb.WriteString("SELECT ")
 	if err := s.buildColumns(); err != nil {
 		return nil, err
 	}
 	s.sb.WriteString(" FROM ")
 
-	if s.table == "" {
-		s.quote(s.model.TableName)
-	} else {
-		s.sb.WriteString(s.table)
+	if err := s.buildTable(s.table); err != nil {
+		return nil, err
 	}
 
-	if len(s.where) > 0 {
+	where := s.scope(s.where, s.unscoped, s.table)
+	if len(where) > 0 {
 		s.sb.WriteString(" WHERE ")
-		if err := s.buildPredicates(s.where); err != nil {
+		if err := s.buildPredicates(where); err != nil {
 			return nil, err
 		}
 	}
@@ -97,10 +114,7 @@ func (s *Selector[T]) Build() (*Query, error) {
 		}
 	}
 	s.sb.WriteByte(';')
-	return &Query{
-		SQL:  s.sb.String(),
-		Args: s.args,
-	}, nil
+	return s.query(), nil
 
 }
 
@@ -127,6 +141,26 @@ func (s *Selector[T]) buildColumns() error {
 		case RawExpr:
 			s.sb.WriteString(c.raw)
 			s.addArg(c.args...)
+		case MathExpr:
+			if err := s.buildExpression(c); err != nil {
+				return err
+			}
+			s.buildAs(c.alias)
+		case FuncExpr:
+			if err := s.buildExpression(c); err != nil {
+				return err
+			}
+			s.buildAs(c.alias)
+		case CaseExpr:
+			if err := s.buildExpression(c); err != nil {
+				return err
+			}
+			s.buildAs(c.alias)
+		case WindowExpr:
+			if err := s.buildExpression(c); err != nil {
+				return err
+			}
+			s.buildAs(c.alias)
 		}
 
 	}
@@ -135,17 +169,18 @@ func (s *Selector[T]) buildColumns() error {
 }
 
 func (s *Selector[T]) buildAggregate(a Aggregate, useAlias bool) error {
-	s.sb.WriteString(a.fn)
-	s.sb.WriteByte('(')
-	err := s.buildColumn(Column{name: a.arg})
-	if err != nil {
+	if c, ok := a.arg.(Column); ok {
+		s.sb.WriteString(a.fn)
+		s.sb.WriteByte('(')
+		if err := s.buildColumn(Column{name: c.name}); err != nil {
+			return err
+		}
+		s.sb.WriteByte(')')
+	} else if err := s.builder.buildAggregate(a); err != nil {
 		return err
 	}
-	s.sb.WriteByte(')')
-	if a.alias != "" {
-		s.sb.WriteString(" AS `")
-		s.sb.WriteString(a.alias)
-		s.sb.WriteByte('`')
+	if useAlias {
+		s.buildAs(a.alias)
 	}
 	return nil
 }
@@ -155,11 +190,7 @@ func (s *Selector[T]) buildColumn(c Column) error {
 		return errs.NewErrUnknownField(c.name)
 	}
 	s.quote(fd.ColName)
-	if c.alias != "" {
-		s.sb.WriteString(" AS `")
-		s.sb.WriteString(c.alias)
-		s.sb.WriteByte('`')
-	}
+	s.buildAs(c.alias)
 	return nil
 }
 func (s *Selector[T]) buildOrderBy() error {
@@ -212,45 +243,132 @@ func (s *Selector[T]) Limit(limit int) *Selector[T] {
 	return s
 }
 
-func (s *Selector[T]) Get(ctx context.Context) (*T, error) {
+// With 添加公共表表达式 WITH name AS (q)，可以在 From 或者子查询中把 name 当作表使用
+func (s *Selector[T]) With(name string, q QueryBuilder) *Selector[T] {
+	s.ctes = append(s.ctes, cte{name: name, query: q})
+	return s
+}
+
+// WithRecursive 添加递归公共表表达式 WITH RECURSIVE name AS (anchor UNION ALL recursive)
+// anchor 和 recursive 都是 Selector 时必须查询同一个模型
+func (s *Selector[T]) WithRecursive(name string, anchor, recursive QueryBuilder) *Selector[T] {
+	s.ctes = append(s.ctes, cte{name: name, query: anchor, recursive: recursive})
+	return s
+}
+
+// Unscoped 查询时不再自动过滤软删除的数据
+func (s *Selector[T]) Unscoped() *Selector[T] {
+	s.unscoped = true
+	return s
+}
 
+// AllowFullTableScan 显式允许全表扫描，例如 safety 中间件不会拦截没有 LIMIT 的查询
+func (s *Selector[T]) AllowFullTableScan() *Selector[T] {
+	s.allowFullTableScan = true
+	return s
+}
+
+func (s *Selector[T]) Statement() StatementInfo {
+	m, _ := s.r.Get(new(T))
+	exprs := make([]Expression, 0, len(s.columns)+len(s.where)+len(s.having))
+	for _, col := range s.columns {
+		if e, ok := col.(Expression); ok {
+			exprs = append(exprs, e)
+		}
+	}
+	for _, p := range s.where {
+		exprs = append(exprs, p)
+	}
+	for _, p := range s.having {
+		exprs = append(exprs, p)
+	}
+	return StatementInfo{
+		HasWhere:             len(s.where) > 0,
+		HasLimit:             s.limit > 0,
+		FullTableScanAllowed: s.allowFullTableScan,
+		Tables:               s.tables(m, s.table, exprs...),
+	}
+}
+
+// Clone 复制一个 Selector，副本和原来的 Selector 互不影响，
+// 例如在不同的 goroutine 中基于同一个 Selector 追加不同的条件
+func (s *Selector[T]) Clone() *Selector[T] {
+	res := *s
+	res.where = slices.Clone(s.where)
+	res.having = slices.Clone(s.having)
+	res.columns = slices.Clone(s.columns)
+	res.groupBys = slices.Clone(s.groupBys)
+	res.orderBys = slices.Clone(s.orderBys)
+	res.ctes = slices.Clone(s.ctes)
+	return &res
+}
+
+func (s *Selector[T]) buildSubquery(tenant any) (*Query, error) {
+	b := *s
+	if b.tenant == nil {
+		b.tenant = tenant
+	}
+	return b.build()
+}
+
+func (s *Selector[T]) subqueryModel() (*model.Model, error) {
+	return s.r.Get(new(T))
+}
+
+func (s *Selector[T]) Get(ctx context.Context) (*T, error) {
+	// 在副本上执行，同一个 Selector 可以并发执行
+	sel := *s
+	s = &sel
 	var err error
 	s.model, err = s.r.Get(new(T))
 	if err != nil {
 		return nil, err
 	}
+	if err = s.bindTenant(ctx); err != nil {
+		return nil, err
+	}
 	res := get[T](ctx, s.sess, s.core, &QueryContext{
 		Model:   s.model,
 		Type:    "SELECT",
 		Builder: s,
 	})
-	if res.Result != nil {
-		return res.Result.(*T), res.Err
+	if res.Result == nil {
+		return nil, res.Err
+	}
+	t := res.Result.(*T)
+	if res.Err != nil {
+		return t, res.Err
 	}
-	return nil, res.Err
+	return t, afterFind(ctx, t)
 }
 
 func (s *Selector[T]) GetMulti(ctx context.Context) ([]*T, error) {
-	q, err := s.Build()
+	sel := *s
+	s = &sel
+	var err error
+	s.model, err = s.r.Get(new(T))
 	if err != nil {
 		return nil, err
 	}
-	// 执行查询, 处理结果集
-	rows, err := s.sess.queryContext(ctx, q.SQL, q.Args...)
-	if err != nil {
+	if err = s.bindTenant(ctx); err != nil {
 		return nil, err
 	}
-	var res []*T
-	for rows.Next() {
-		tp := new(T)
-		val := s.creator(s.model, tp)
-		err = val.SetColumns(rows)
-		if err != nil {
+	res := getMulti[T](ctx, s.sess, s.core, &QueryContext{
+		Model:   s.model,
+		Type:    "SELECT",
+		Multi:   true,
+		Builder: s,
+	})
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	ts, _ := res.Result.([]*T)
+	for _, t := range ts {
+		if err = afterFind(ctx, t); err != nil {
 			return nil, err
 		}
-		res = append(res, tp)
 	}
-	return res, nil
+	return ts, nil
 }
 
 type OrderBy struct {
@@ -9,7 +9,9 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestSelector_Build(t *testing.T) {
@@ -50,32 +52,36 @@ func TestSelector_Build(t *testing.T) {
 			name:    "where",
 			builder: NewSelector[TestModel](db).Where(C("FirstName").Eq("Tom")),
 			wantQuery: &Query{
-				SQL:  "SELECT * FROM `test_model` WHERE `first_name` = ?;",
-				Args: []any{"Tom"},
+				SQL:        "SELECT * FROM `test_model` WHERE `first_name` = ?;",
+				Args:       []any{"Tom"},
+				ArgColumns: []string{"first_name"},
 			},
 		},
 		{
 			name:    "not",
 			builder: NewSelector[TestModel](db).Where(Not(C("FirstName").Eq("Tom"))),
 			wantQuery: &Query{
-				SQL:  "SELECT * FROM `test_model` WHERE  NOT (`first_name` = ?);",
-				Args: []any{"Tom"},
+				SQL:        "SELECT * FROM `test_model` WHERE  NOT (`first_name` = ?);",
+				Args:       []any{"Tom"},
+				ArgColumns: []string{"first_name"},
 			},
 		},
 		{
 			name:    "and",
 			builder: NewSelector[TestModel](db).Where(C("FirstName").Eq("Tom").And(C("Id").Eq(123))),
 			wantQuery: &Query{
-				SQL:  "SELECT * FROM `test_model` WHERE (`first_name` = ?) AND (`id` = ?);",
-				Args: []any{"Tom", 123},
+				SQL:        "SELECT * FROM `test_model` WHERE (`first_name` = ?) AND (`id` = ?);",
+				Args:       []any{"Tom", 123},
+				ArgColumns: []string{"first_name", "id"},
 			},
 		},
 		{
 			name:    "or",
 			builder: NewSelector[TestModel](db).Where(C("FirstName").Eq("Tom").Or(C("Id").Eq(123))),
 			wantQuery: &Query{
-				SQL:  "SELECT * FROM `test_model` WHERE (`first_name` = ?) OR (`id` = ?);",
-				Args: []any{"Tom", 123},
+				SQL:        "SELECT * FROM `test_model` WHERE (`first_name` = ?) OR (`id` = ?);",
+				Args:       []any{"Tom", 123},
+				ArgColumns: []string{"first_name", "id"},
 			},
 		},
 		{
@@ -87,16 +93,18 @@ func TestSelector_Build(t *testing.T) {
 			name:    "raw expression as predicate",
 			builder: NewSelector[TestModel](db).Where(Raw("`id`<?", 18).AsPredicate()),
 			wantQuery: &Query{
-				SQL:  "SELECT * FROM `test_model` WHERE `id`<?;",
-				Args: []any{18},
+				SQL:        "SELECT * FROM `test_model` WHERE `id`<?;",
+				Args:       []any{18},
+				ArgColumns: []string{""},
 			},
 		},
 		{
 			name:    "raw expression used in predicate",
 			builder: NewSelector[TestModel](db).Where(C("Id").Eq(Raw("`age`+?", 18))),
 			wantQuery: &Query{
-				SQL:  "SELECT * FROM `test_model` WHERE `id` = `age`+?;",
-				Args: []any{18},
+				SQL:        "SELECT * FROM `test_model` WHERE `id` = `age`+?;",
+				Args:       []any{18},
+				ArgColumns: []string{"id"},
 			},
 		},
 		{
@@ -106,6 +114,13 @@ func TestSelector_Build(t *testing.T) {
 				SQL: "SELECT `first_name` AS `my_name` FROM `test_model`;",
 			},
 		},
+		{
+			name:    "columns alias field name",
+			builder: NewSelector[TestModel](db).Select(C("Id"), C("FirstName").As("LastName")),
+			wantQuery: &Query{
+				SQL: "SELECT `id`,`first_name` AS `last_name` FROM `test_model`;",
+			},
+		},
 		{
 			name:    "avg alias",
 			builder: NewSelector[TestModel](db).Select(Avg("FirstName").As("my_name")),
@@ -117,8 +132,9 @@ func TestSelector_Build(t *testing.T) {
 			name:    "columns alias in where",
 			builder: NewSelector[TestModel](db).Where(C("FirstName").As("my_name").Eq("Tom")),
 			wantQuery: &Query{
-				SQL:  "SELECT * FROM `test_model` WHERE `first_name` = ?;",
-				Args: []any{"Tom"},
+				SQL:        "SELECT * FROM `test_model` WHERE `first_name` = ?;",
+				Args:       []any{"Tom"},
+				ArgColumns: []string{"first_name"},
 			},
 		},
 		{
@@ -144,31 +160,57 @@ func TestSelector_Build(t *testing.T) {
 			name:    "having",
 			builder: NewSelector[TestModel](db).GroupBy(C("FirstName")).Having(C("FirstName").Eq("Tom")),
 			wantQuery: &Query{
-				SQL:  "SELECT * FROM `test_model` GROUP BY `first_name` HAVING `first_name` = ?;",
-				Args: []any{"Tom"},
+				SQL:        "SELECT * FROM `test_model` GROUP BY `first_name` HAVING `first_name` = ?;",
+				Args:       []any{"Tom"},
+				ArgColumns: []string{"first_name"},
 			},
 		}, {
 			name:    "offset only",
 			builder: NewSelector[TestModel](db).Offset(10),
 			wantQuery: &Query{
-				SQL:  "SELECT * FROM `test_model` OFFSET ?;",
-				Args: []any{10},
+				SQL:        "SELECT * FROM `test_model` OFFSET ?;",
+				Args:       []any{10},
+				ArgColumns: []string{""},
 			},
 		},
 		{
 			name:    "limit only",
 			builder: NewSelector[TestModel](db).Limit(10),
 			wantQuery: &Query{
-				SQL:  "SELECT * FROM `test_model` LIMIT ?;",
-				Args: []any{10},
+				SQL:        "SELECT * FROM `test_model` LIMIT ?;",
+				Args:       []any{10},
+				ArgColumns: []string{""},
 			},
 		},
 		{
 			name:    "offset and limit",
 			builder: NewSelector[TestModel](db).Offset(10).Limit(10),
 			wantQuery: &Query{
-				SQL:  "SELECT * FROM `test_model` LIMIT ? OFFSET ?;",
-				Args: []any{10, 10},
+				SQL:        "SELECT * FROM `test_model` LIMIT ? OFFSET ?;",
+				Args:       []any{10, 10},
+				ArgColumns: []string{"", ""},
+			},
+		}, {
+			name:    "soft delete",
+			builder: NewSelector[SoftDeleteModel](db).Where(C("Id").Eq(1)),
+			wantQuery: &Query{
+				SQL:        "SELECT * FROM `soft_delete_model` WHERE (`id` = ?) AND (`deleted_at` IS NULL);",
+				Args:       []any{1},
+				ArgColumns: []string{"id"},
+			},
+		}, {
+			name:    "soft delete without where",
+			builder: NewSelector[SoftDeleteModel](db),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `soft_delete_model` WHERE `deleted_at` IS NULL;",
+			},
+		}, {
+			name:    "soft delete unscoped",
+			builder: NewSelector[SoftDeleteModel](db).Where(C("Id").Eq(1)).Unscoped(),
+			wantQuery: &Query{
+				SQL:        "SELECT * FROM `soft_delete_model` WHERE `id` = ?;",
+				Args:       []any{1},
+				ArgColumns: []string{"id"},
 			},
 		}, {
 			name:    "order by",
@@ -360,6 +402,125 @@ func TestSelector_Select(t *testing.T) {
 				SQL: "SELECT COUNT(DISTINCT `first_name`) FROM `test_model`;",
 			},
 		},
+		{
+			name:    "math expression",
+			builder: NewSelector[TestModel](db).Select(C("Age").Add(1).Mul(C("Id")).As("age"), C("Id").Mod(2)),
+			wantQuery: &Query{
+				SQL:        "SELECT (`age` + ?) * `id` AS `age`,`id` % ? FROM `test_model`;",
+				Args:       []any{1, 2},
+				ArgColumns: []string{"", ""},
+			},
+		},
+		{
+			name:    "function",
+			builder: NewSelector[TestModel](db).Select(Coalesce(C("LastName"), C("FirstName"), "").As("last_name"), Lower(C("FirstName")), Now()),
+			wantQuery: &Query{
+				SQL:        "SELECT COALESCE(`last_name`,`first_name`,?) AS `last_name`,LOWER(`first_name`),CURRENT_TIMESTAMP FROM `test_model`;",
+				Args:       []any{""},
+				ArgColumns: []string{""},
+			},
+		},
+		{
+			name:    "concat",
+			builder: NewSelector[TestModel](db).Select(C("FirstName").Concat(" ", C("LastName")).As("first_name")),
+			wantQuery: &Query{
+				SQL:        "SELECT CONCAT(`first_name`,?,`last_name`) AS `first_name` FROM `test_model`;",
+				Args:       []any{" "},
+				ArgColumns: []string{""},
+			},
+		},
+		{
+			name:    "where expression",
+			builder: NewSelector[TestModel](db).Where(C("Age").Sub(C("Id")).Gt(10), Lower(C("FirstName")).Eq("tom")),
+			wantQuery: &Query{
+				SQL:        "SELECT * FROM `test_model` WHERE (`age` - `id` > ?) AND (LOWER(`first_name`) = ?);",
+				Args:       []any{10, "tom"},
+				ArgColumns: []string{"", ""},
+			},
+		},
+		{
+			name: "conditional aggregate",
+			builder: NewSelector[TestModel](db).Select(
+				Sum(Case().When(C("Age").Gt(18), 1).Else(0)).As("age"),
+				Count(Distinct(C("FirstName"))),
+				Count(Distinct("LastName")).As("id")),
+			wantQuery: &Query{
+				SQL:        "SELECT SUM(CASE WHEN `age` > ? THEN ? ELSE ? END) AS `age`,COUNT(DISTINCT `first_name`),COUNT(DISTINCT `last_name`) AS `id` FROM `test_model`;",
+				Args:       []any{18, 1, 0},
+				ArgColumns: []string{"age", "", ""},
+			},
+		},
+		{
+			name: "case",
+			builder: NewSelector[TestModel](db).Select(C("Id"),
+				Case().When(C("Age").Lt(18), "child").When(C("Age").Lt(60), C("FirstName")).As("first_name")),
+			wantQuery: &Query{
+				SQL:        "SELECT `id`,CASE WHEN `age` < ? THEN ? WHEN `age` < ? THEN `first_name` END AS `first_name` FROM `test_model`;",
+				Args:       []any{18, "child", 60},
+				ArgColumns: []string{"age", "", "age"},
+			},
+		},
+		{
+			name:    "having aggregate expression",
+			builder: NewSelector[TestModel](db).Select(C("FirstName"), Count("Id")).GroupBy(C("FirstName")).Having(Count(Distinct("Age")).Gt(1)),
+			wantQuery: &Query{
+				SQL:        "SELECT `first_name`,COUNT(`id`) FROM `test_model` GROUP BY `first_name` HAVING COUNT(DISTINCT `age`) > ?;",
+				Args:       []any{1},
+				ArgColumns: []string{""},
+			},
+		},
+		{
+			name: "row number",
+			builder: NewSelector[TestModel](db).Select(C("Id"),
+				RowNumber().Over(Window().PartitionBy(C("FirstName")).OrderBy(Desc("Age"), Asc("Id"))).As("Age")),
+			wantQuery: &Query{
+				SQL: "SELECT `id`,ROW_NUMBER() OVER (PARTITION BY `first_name` ORDER BY `age` DESC,`id` ASC) AS `age` FROM `test_model`;",
+			},
+		},
+		{
+			name: "rank and lag",
+			builder: NewSelector[TestModel](db).Select(
+				Rank().Over(Window().OrderBy(Desc("Age"))),
+				DenseRank().Over(Window()),
+				Lag("Age", 1, 0).Over(Window().OrderBy(Asc("Id"))),
+				Lead(C("Age")).Over(Window().PartitionBy(C("FirstName")))),
+			wantQuery: &Query{
+				SQL: "SELECT RANK() OVER (ORDER BY `age` DESC),DENSE_RANK() OVER (),LAG(`age`,?,?) OVER (ORDER BY `id` ASC)," +
+					"LEAD(`age`) OVER (PARTITION BY `first_name`) FROM `test_model`;",
+				Args:       []any{1, 0},
+				ArgColumns: []string{"", ""},
+			},
+		},
+		{
+			name: "aggregate over rows",
+			builder: NewSelector[TestModel](db).Select(
+				Sum("Age").Over(Window().OrderBy(Asc("Id")).Rows(Preceding(2), CurrentRow())).As("running"),
+				Avg("Age").Over(Window().Rows(UnboundedPreceding(), Following(1)))),
+			wantQuery: &Query{
+				SQL: "SELECT SUM(`age`) OVER (ORDER BY `id` ASC ROWS BETWEEN 2 PRECEDING AND CURRENT ROW) AS `running`," +
+					"AVG(`age`) OVER (ROWS BETWEEN UNBOUNDED PRECEDING AND 1 FOLLOWING) FROM `test_model`;",
+			},
+		},
+		{
+			name:    "window invalid column",
+			builder: NewSelector[TestModel](db).Select(RowNumber().Over(Window().OrderBy(Asc("Invalid")))),
+			wantErr: errs.NewErrUnknownColumn("Invalid"),
+		},
+		{
+			name:    "empty case",
+			builder: NewSelector[TestModel](db).Select(Case().Else(1)),
+			wantErr: errs.ErrEmptyCase,
+		},
+		{
+			name:    "case invalid column",
+			builder: NewSelector[TestModel](db).Select(Sum(Case().When(C("Invalid").Eq(1), 1))),
+			wantErr: errs.NewErrUnknownColumn("Invalid"),
+		},
+		{
+			name:    "function invalid column",
+			builder: NewSelector[TestModel](db).Select(Fn("ABS", C("Invalid"))),
+			wantErr: errs.NewErrUnknownColumn("Invalid"),
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -372,3 +533,276 @@ func TestSelector_Select(t *testing.T) {
 		})
 	}
 }
+
+func TestSelector_Clone(t *testing.T) {
+	db := memoryDB(t)
+	base := NewSelector[TestModel](db).Where(C("Age").Gt(18)).OrderBy(Asc("Id"))
+	// Build 可以重复调用
+	q1, err := base.Build()
+	require.NoError(t, err)
+	q2, err := base.Build()
+	require.NoError(t, err)
+	assert.Equal(t, q1, q2)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			q, err := base.Build()
+			assert.NoError(t, err)
+			assert.Equal(t, q1, q)
+
+			q, err = base.Clone().Where(C("Age").Gt(18), C("Id").Eq(i)).Limit(i + 1).Build()
+			assert.NoError(t, err)
+			assert.Equal(t, &Query{
+				SQL:        "SELECT * FROM `test_model` WHERE (`age` > ?) AND (`id` = ?) LIMIT ? ORDER BY `id` ASC;",
+				Args:       []any{18, i, i + 1},
+				ArgColumns: []string{"age", "id", ""},
+			}, q)
+		}(i)
+	}
+	wg.Wait()
+	// 副本的修改不影响原来的 Selector
+	q, err := base.Build()
+	require.NoError(t, err)
+	assert.Equal(t, q1, q)
+}
+
+func TestSelector_ConcurrentGet(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	mock.MatchExpectationsInOrder(false)
+	db, err := OpenDB(mockDB)
+	require.NoError(t, err)
+	const n = 8
+	for i := 0; i < n; i++ {
+		mock.ExpectQuery("SELECT .*").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	}
+	base := NewSelector[TestModel](db).Where(C("Id").Eq(1))
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := base.Get(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, &TestModel{Id: 1}, res)
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSelector_ConditionalAggregate_SQLite(t *testing.T) {
+	db, err := Open("sqlite3", "file:"+t.Name()+"?mode=memory&cache=shared", DBWithDialect(DialectSQLite))
+	require.NoError(t, err)
+	ctx := context.Background()
+	err = RawQuery[TestModel](db, "CREATE TABLE test_model (id INTEGER PRIMARY KEY, first_name TEXT, age INTEGER, last_name TEXT)").Exec(ctx).Err()
+	require.NoError(t, err)
+	err = NewInserter[TestModel](db).Values(
+		&TestModel{Id: 1, FirstName: "Tom", Age: 10},
+		&TestModel{Id: 2, FirstName: "Tom", Age: 20},
+		&TestModel{Id: 3, FirstName: "Jerry", Age: 30},
+	).Exec(ctx).Err()
+	require.NoError(t, err)
+
+	res, err := NewSelector[TestModel](db).Select(
+		Sum(Case().When(C("Age").Gt(15), 1).Else(0)).As("age"),
+		Count(Distinct(C("FirstName"))).As("id"),
+	).Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, &TestModel{Id: 2, Age: 2}, res)
+}
+
+func TestSelector_Window_SQLite(t *testing.T) {
+	db, err := Open("sqlite3", "file:"+t.Name()+"?mode=memory&cache=shared", DBWithDialect(DialectSQLite))
+	require.NoError(t, err)
+	ctx := context.Background()
+	err = RawQuery[TestModel](db, "CREATE TABLE test_model (id INTEGER PRIMARY KEY, first_name TEXT, age INTEGER, last_name TEXT)").Exec(ctx).Err()
+	require.NoError(t, err)
+	err = NewInserter[TestModel](db).Values(
+		&TestModel{Id: 1, FirstName: "Tom", Age: 10},
+		&TestModel{Id: 2, FirstName: "Tom", Age: 20},
+		&TestModel{Id: 3, FirstName: "Jerry", Age: 30},
+	).Exec(ctx).Err()
+	require.NoError(t, err)
+
+	res, err := NewSelector[RankModel](db).From("`test_model`").Select(
+		C("Id"), C("FirstName"),
+		RowNumber().Over(Window().PartitionBy(C("FirstName")).OrderBy(Desc("Age"))).As("Rn"),
+		Lag("Age", 1, 0).Over(Window().PartitionBy(C("FirstName")).OrderBy(Asc("Id"))).As("PrevAge"),
+	).OrderBy(Asc("Id")).GetMulti(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []*RankModel{
+		{Id: 1, FirstName: "Tom", Rn: 2, PrevAge: 0},
+		{Id: 2, FirstName: "Tom", Rn: 1, PrevAge: 10},
+		{Id: 3, FirstName: "Jerry", Rn: 1, PrevAge: 0},
+	}, res)
+}
+
+// RankModel 窗口函数的结果，窗口中用到的列也需要是字段
+type RankModel struct {
+	Id        int64
+	FirstName string
+	Age       int8
+	Rn        int64
+	PrevAge   int64
+}
+
+func TestSelector_With(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name      string
+		builder   QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "with",
+			builder: NewSelector[TestModel](db).
+				With("young", NewSelector[TestModel](db).Where(C("Age").Lt(18))).
+				With("tom", NewSelector[TestModel](db).From("`young`").Where(C("FirstName").Eq("Tom"))).
+				From("`tom`").Where(C("Id").Gt(5)),
+			wantQuery: &Query{
+				SQL: "WITH `young` AS (SELECT * FROM `test_model` WHERE `age` < ?), `tom` AS (SELECT * FROM `young` WHERE `first_name` = ?)" +
+					" SELECT * FROM `tom` WHERE `id` > ?;",
+				Args:       []any{18, "Tom", 5},
+				ArgColumns: []string{"age", "first_name", "id"},
+			},
+		},
+		{
+			name: "with recursive",
+			builder: NewSelector[TestModel](db).
+				WithRecursive("tree",
+					NewSelector[TestModel](db).Where(C("Id").Eq(1)),
+					NewSelector[TestModel](db).Select(Raw("`test_model`.*")).
+						From("`test_model` JOIN `tree` ON `test_model`.`age` = `tree`.`id`")).
+				From("`tree`"),
+			wantQuery: &Query{
+				SQL: "WITH RECURSIVE `tree` AS (SELECT * FROM `test_model` WHERE `id` = ? UNION ALL" +
+					" SELECT `test_model`.* FROM `test_model` JOIN `tree` ON `test_model`.`age` = `tree`.`id`) SELECT * FROM `tree`;",
+				Args:       []any{1},
+				ArgColumns: []string{"id"},
+			},
+		},
+		{
+			name:    "empty name",
+			builder: NewSelector[TestModel](db).With("", NewSelector[TestModel](db)),
+			wantErr: errs.ErrEmptyCTEName,
+		},
+		{
+			name: "duplicate name",
+			builder: NewSelector[TestModel](db).
+				With("t", NewSelector[TestModel](db)).With("t", NewSelector[TestModel](db)),
+			wantErr: errs.NewErrDuplicateCTE("t"),
+		},
+		{
+			name: "recursive model mismatch",
+			builder: NewSelector[TestModel](db).
+				WithRecursive("tree", NewSelector[TestModel](db), NewSelector[SoftDeleteModel](db)),
+			wantErr: errs.NewErrCTEModelMismatch("tree", "test_model", "soft_delete_model"),
+		},
+		{
+			name:    "invalid column",
+			builder: NewSelector[TestModel](db).With("t", NewSelector[TestModel](db).Where(C("Invalid").Eq(1))),
+			wantErr: errs.NewErrUnknownColumn("Invalid"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.builder.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+}
+
+func TestSelector_WithRecursive_SQLite(t *testing.T) {
+	db, err := Open("sqlite3", "file:"+t.Name()+"?mode=memory&cache=shared", DBWithDialect(DialectSQLite))
+	require.NoError(t, err)
+	ctx := context.Background()
+	err = RawQuery[Category](db, "CREATE TABLE category (id INTEGER PRIMARY KEY, parent_id INTEGER, name TEXT)").Exec(ctx).Err()
+	require.NoError(t, err)
+	err = NewInserter[Category](db).Values(
+		&Category{Id: 1, Name: "root"},
+		&Category{Id: 2, ParentId: 1, Name: "a"},
+		&Category{Id: 3, ParentId: 2, Name: "b"},
+		&Category{Id: 4, Name: "other"},
+	).Exec(ctx).Err()
+	require.NoError(t, err)
+
+	subtree := func(id int64) (QueryBuilder, QueryBuilder) {
+		return NewSelector[Category](db).Where(C("Id").Eq(id)),
+			NewSelector[Category](db).Select(Raw("`category`.*")).
+				From("`category` JOIN `tree` ON `category`.`parent_id` = `tree`.`id`")
+	}
+	anchor, recursive := subtree(2)
+	res, err := NewSelector[Category](db).WithRecursive("tree", anchor, recursive).
+		From("`tree`").Where(C("Name").Eq("b")).GetMulti(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []*Category{{Id: 3, ParentId: 2, Name: "b"}}, res)
+
+	// 删除整棵子树
+	anchor, recursive = subtree(1)
+	affected, err := NewDeleter[Category](db).WithRecursive("tree", anchor, recursive).
+		Where(C("Id").InQuery(NewSelector[Category](db).Select(C("Id")).From("`tree`"))).
+		Exec(ctx).RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), affected)
+	left, err := NewSelector[Category](db).GetMulti(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []*Category{{Id: 4, Name: "other"}}, left)
+}
+
+func TestSelector_WithRecursive_SoftDelete(t *testing.T) {
+	db, err := Open("sqlite3", "file:"+t.Name()+"?mode=memory&cache=shared", DBWithDialect(DialectSQLite))
+	require.NoError(t, err)
+	ctx := context.Background()
+	err = RawQuery[SoftDeleteCategory](db, "CREATE TABLE soft_delete_category (id INTEGER PRIMARY KEY, parent_id INTEGER, name TEXT, deleted_at DATETIME)").Exec(ctx).Err()
+	require.NoError(t, err)
+	err = NewInserter[SoftDeleteCategory](db).Values(
+		&SoftDeleteCategory{Id: 1, Name: "root"},
+		&SoftDeleteCategory{Id: 2, ParentId: 1, Name: "a"},
+		&SoftDeleteCategory{Id: 3, ParentId: 2, Name: "b"},
+		&SoftDeleteCategory{Id: 4, ParentId: 1, Name: "deleted"},
+		&SoftDeleteCategory{Id: 5, ParentId: 4, Name: "c"},
+	).Exec(ctx).Err()
+	require.NoError(t, err)
+	require.NoError(t, NewDeleter[SoftDeleteCategory](db).Where(C("Id").Eq(4)).Exec(ctx).Err())
+
+	// JOIN 中自动追加的软删除条件加上模型的表名，避免列名有歧义
+	recursive := NewSelector[SoftDeleteCategory](db).Select(Raw("`soft_delete_category`.*")).
+		From("`soft_delete_category` JOIN `tree` ON `soft_delete_category`.`parent_id` = `tree`.`id`")
+	q, err := recursive.Build()
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT `soft_delete_category`.* FROM `soft_delete_category` JOIN `tree` ON `soft_delete_category`.`parent_id` = `tree`.`id`"+
+		" WHERE `soft_delete_category`.`deleted_at` IS NULL;", q.SQL)
+
+	res, err := NewSelector[SoftDeleteCategory](db).
+		WithRecursive("tree", NewSelector[SoftDeleteCategory](db).Where(C("Id").Eq(1)), recursive).
+		From("`tree`").GetMulti(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []*SoftDeleteCategory{
+		{Id: 1, Name: "root"},
+		{Id: 2, ParentId: 1, Name: "a"},
+		{Id: 3, ParentId: 2, Name: "b"},
+	}, res)
+}
+
+type SoftDeleteCategory struct {
+	Id        int64
+	ParentId  int64
+	Name      string
+	DeletedAt *time.Time `orm:"soft_delete"`
+}
+
+type Category struct {
+	Id       int64
+	ParentId int64
+	Name     string
+}
@@ -0,0 +1,125 @@
+package serializer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Serializer 负责列值的编解码
+// 任意类型的字段（结构体、map、切片）都可以通过 `orm:"serializer:name"` 标签存储为一列
+type Serializer interface {
+	// Serialize 把字段值编码为写入数据库的字节
+	Serialize(val any) ([]byte, error)
+	// Deserialize 把数据库中的字节解码到 ptr，ptr 是指向字段的指针
+	Deserialize(data []byte, ptr any) error
+}
+
+var serializers sync.Map
+
+func init() {
+	Register("json", JSON{})
+	Register("gob", Gob{})
+	Register("csv", CSV{})
+}
+
+// Register 注册编解码器，同名会覆盖
+// 需要在注册模型之前调用
+func Register(name string, s Serializer) {
+	serializers.Store(name, s)
+}
+
+func Get(name string) (Serializer, bool) {
+	s, ok := serializers.Load(name)
+	if !ok {
+		return nil, false
+	}
+	return s.(Serializer), true
+}
+
+// Encode 编码字段值，nil 的指针、map、切片编码为 NULL
+func Encode(s Serializer, val any) (any, error) {
+	if isNil(val) {
+		return nil, nil
+	}
+	return s.Serialize(val)
+}
+
+func isNil(val any) bool {
+	if val == nil {
+		return true
+	}
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface:
+		return rv.IsNil()
+	default:
+		return false
+	}
+}
+
+type JSON struct{}
+
+func (JSON) Serialize(val any) ([]byte, error) {
+	return json.Marshal(val)
+}
+
+func (JSON) Deserialize(data []byte, ptr any) error {
+	return json.Unmarshal(data, ptr)
+}
+
+type Gob struct{}
+
+func (Gob) Serialize(val any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(val); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (Gob) Deserialize(data []byte, ptr any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(ptr)
+}
+
+// CSV 把 []string 编码为一行 CSV
+type CSV struct{}
+
+func (CSV) Serialize(val any) ([]byte, error) {
+	record, ok := val.([]string)
+	if !ok {
+		return nil, fmt.Errorf("orm: csv serializer only supports []string, got %T", val)
+	}
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(record); err != nil {
+		return nil, err
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	// 去掉末尾的换行
+	return bytes.TrimRight(buf.Bytes(), "\r\n"), nil
+}
+
+func (CSV) Deserialize(data []byte, ptr any) error {
+	dst, ok := ptr.(*[]string)
+	if !ok {
+		return fmt.Errorf("orm: csv serializer only supports *[]string, got %T", ptr)
+	}
+	if len(data) == 0 {
+		*dst = []string{}
+		return nil
+	}
+	record, err := csv.NewReader(bytes.NewReader(data)).Read()
+	if err != nil {
+		return err
+	}
+	*dst = record
+	return nil
+}
@@ -0,0 +1,114 @@
+package serializer
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type address struct {
+	City   string
+	Street string
+}
+
+func TestSerializer(t *testing.T) {
+	testCases := []struct {
+		name     string
+		s        Serializer
+		val      any
+		ptr      any
+		wantData []byte
+		wantErr  string
+	}{
+		{
+			name:     "json struct",
+			s:        JSON{},
+			val:      address{City: "sz", Street: "nanshan"},
+			ptr:      &address{},
+			wantData: []byte(`{"City":"sz","Street":"nanshan"}`),
+		},
+		{
+			name:     "json map",
+			s:        JSON{},
+			val:      map[string]int{"a": 1},
+			ptr:      &map[string]int{},
+			wantData: []byte(`{"a":1}`),
+		},
+		{
+			name: "gob",
+			s:    Gob{},
+			val:  []int{1, 2, 3},
+			ptr:  &[]int{},
+		},
+		{
+			name:     "csv",
+			s:        CSV{},
+			val:      []string{"a", "b,c", `d"e`},
+			ptr:      &[]string{},
+			wantData: []byte(`a,"b,c","d""e"`),
+		},
+		{
+			name:    "csv invalid type",
+			s:       CSV{},
+			val:     []int{1},
+			wantErr: "orm: csv serializer only supports []string, got []int",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := tc.s.Serialize(tc.val)
+			if tc.wantErr != "" {
+				assert.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			if tc.wantData != nil {
+				assert.Equal(t, tc.wantData, data)
+			}
+			err = tc.s.Deserialize(data, tc.ptr)
+			require.NoError(t, err)
+			// ptr 解码后应当与原值相等
+			switch ptr := tc.ptr.(type) {
+			case *address:
+				assert.Equal(t, tc.val, *ptr)
+			case *map[string]int:
+				assert.Equal(t, tc.val, *ptr)
+			case *[]int:
+				assert.Equal(t, tc.val, *ptr)
+			case *[]string:
+				assert.Equal(t, tc.val, *ptr)
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	var m map[string]int
+	val, err := Encode(JSON{}, m)
+	require.NoError(t, err)
+	assert.Nil(t, val)
+
+	val, err = Encode(JSON{}, map[string]int{"a": 1})
+	require.NoError(t, err)
+	assert.Equal(t, []byte(`{"a":1}`), val)
+}
+
+type upper struct{}
+
+func (upper) Serialize(val any) ([]byte, error) {
+	return []byte(val.(string)), nil
+}
+
+func (upper) Deserialize(data []byte, ptr any) error {
+	*(ptr.(*string)) = string(data)
+	return nil
+}
+
+func TestRegister(t *testing.T) {
+	_, ok := Get("upper")
+	assert.False(t, ok)
+	Register("upper", upper{})
+	s, ok := Get("upper")
+	assert.True(t, ok)
+	assert.Equal(t, upper{}, s)
+}
@@ -0,0 +1,115 @@
+package go_orm
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Snapshotter 获取一次修改涉及的数据，每一行是列名到列值的映射
+// audit 等中间件把 QueryContext.Builder 断言为 Snapshotter，在 QueryContext.Session 上调用
+type Snapshotter interface {
+	Snapshot(ctx context.Context, sess Session) ([]map[string]any, error)
+}
+
+var (
+	_ Snapshotter = &Inserter[any]{}
+	_ Snapshotter = &Updater[any]{}
+	_ Snapshotter = &Deleter[any]{}
+)
+
+// Snapshot 用同样的条件查询会被更新的数据，在 Exec 前后调用分别得到修改前后的数据
+// 注意如果更新修改了条件中的列，Exec 之后可能查不到对应的数据
+func (u *Updater[T]) Snapshot(ctx context.Context, sess Session) ([]map[string]any, error) {
+	return u.snapshot(ctx, sess, u.table, u.scope(u.where, u.unscoped, u.table))
+}
+
+// Snapshot 用同样的条件查询会被删除的数据
+func (d *Deleter[T]) Snapshot(ctx context.Context, sess Session) ([]map[string]any, error) {
+	return d.snapshot(ctx, sess, d.table, d.scope(d.where, d.unscoped, d.table))
+}
+
+// Snapshot 返回插入的数据，Exec 之后调用可以拿到自动填充的字段
+func (i *Inserter[T]) Snapshot(ctx context.Context, sess Session) ([]map[string]any, error) {
+	res := make([]map[string]any, 0, len(i.values))
+	for _, v := range i.values {
+		val := i.creator(i.model, v)
+		row := make(map[string]any, len(i.model.Fields))
+		for _, fd := range i.model.Fields {
+			arg, err := val.Field(fd.GoName)
+			if err != nil {
+				return nil, err
+			}
+			if bs, ok := arg.([]byte); ok {
+				arg = string(bs)
+			}
+			row[fd.ColName] = arg
+		}
+		res = append(res, row)
+	}
+	return res, nil
+}
+
+func (b *builder) snapshot(ctx context.Context, sess Session, table string, where []Predicate) ([]map[string]any, error) {
+	if err := b.checkTenant(); err != nil {
+		return nil, err
+	}
+	sb := &builder{
+		core:   b.core,
+		quoter: b.quoter,
+		tenant: b.tenant,
+		ctes:   b.ctes,
+	}
+	// 条件中可能引用 CTE
+	if err := sb.buildWith(); err != nil {
+		return nil, err
+	}
+	sb.sb.WriteString("SELECT * FROM ")
+	if table == "" {
+		sb.quoteTable(b.model.TableName)
+	} else {
+		sb.sb.WriteString(table)
+	}
+	if len(where) > 0 {
+		sb.sb.WriteString(" WHERE ")
+		if err := sb.buildPredicates(where); err != nil {
+			return nil, err
+		}
+	}
+	sb.sb.WriteByte(';')
+	rows, err := sess.queryContext(ctx, sb.sb.String(), sb.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	return scanMaps(rows)
+}
+
+// scanMaps 把结果集转换为列名到列值的映射，[]byte 转换为 string
+func scanMaps(rows *sql.Rows) ([]map[string]any, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var res []map[string]any
+	for rows.Next() {
+		vals := make([]any, len(cols))
+		ptrs := make([]any, len(cols))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err = rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]any, len(cols))
+		for i, col := range cols {
+			if bs, ok := vals[i].([]byte); ok {
+				vals[i] = string(bs)
+			}
+			row[col] = vals[i]
+		}
+		res = append(res, row)
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,35 @@
+package go_orm
+
+import (
+	"github.com/Andras5014/go-orm/model"
+	"reflect"
+	"time"
+)
+
+// notDeleted 构造“未被软删除”的谓词
+// 时间类型的字段用 IS NULL 判断，bool 和整数标记用零值判断
+func notDeleted(fd *model.Field, table string) Predicate {
+	col := Column{name: fd.GoName, table: table}
+	switch fd.Typ.Kind() {
+	case reflect.Bool:
+		return col.Eq(false)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return col.Eq(0)
+	default:
+		return col.IsNull()
+	}
+}
+
+// deletedValue 软删除时写入字段的值
+func deletedValue(fd *model.Field, now time.Time) any {
+	switch fd.Typ.Kind() {
+	case reflect.Bool:
+		return true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return 1
+	default:
+		return now
+	}
+}
@@ -0,0 +1,113 @@
+package go_orm
+
+import (
+	"github.com/Andras5014/go-orm/model"
+	"slices"
+	"strings"
+)
+
+// StatementInfo 描述构造器的结构，中间件可以把 QueryContext.Builder 断言为 StatementInspector 获取
+type StatementInfo struct {
+	// HasWhere 是否有用户指定的 WHERE 条件，不包括软删除等自动追加的条件
+	HasWhere bool
+	HasLimit bool
+	// FullTableScanAllowed 是否调用了 AllowFullTableScan
+	FullTableScanAllowed bool
+	// Tables 语句涉及的表，包括 From 指定的表以及 CTE 和子查询中的表，
+	// 缓存之类的中间件可以据此失效。From 中的 JOIN 等复杂写法无法解析，使用模型的表名
+	Tables []string
+}
+
+type StatementInspector interface {
+	Statement() StatementInfo
+}
+
+var (
+	_ StatementInspector = &Selector[any]{}
+	_ StatementInspector = &Updater[any]{}
+	_ StatementInspector = &Deleter[any]{}
+)
+
+// tables 收集语句涉及的表
+func (b *builder) tables(m *model.Model, table string, exprs ...Expression) []string {
+	res := make([]string, 0, 2)
+	names := make([]string, 0, len(b.ctes))
+	for _, c := range b.ctes {
+		names = append(names, c.name)
+	}
+	add := func(ts ...string) {
+		for _, t := range ts {
+			if t != "" && !slices.Contains(res, t) && !slices.Contains(names, t) {
+				res = append(res, t)
+			}
+		}
+	}
+	if t := tableName(table); t != "" {
+		add(t)
+	} else if m != nil {
+		add(m.TableName)
+	}
+	for _, c := range b.ctes {
+		add(subqueryTables(c.query)...)
+		if c.recursive != nil {
+			add(subqueryTables(c.recursive)...)
+		}
+	}
+	for _, expr := range exprs {
+		exprTables(expr, add)
+	}
+	return res
+}
+
+// tableName 解析 From 指定的表名，去掉引号，无法解析时返回空字符串
+func tableName(table string) string {
+	table = strings.TrimSpace(table)
+	for _, r := range table {
+		if !(r == '_' || r == '$' || r == '.' || r == '`' || r == '"' ||
+			r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return ""
+		}
+	}
+	return strings.NewReplacer("`", "", `"`, "").Replace(table)
+}
+
+func subqueryTables(q QueryBuilder) []string {
+	if si, ok := q.(StatementInspector); ok {
+		return si.Statement().Tables
+	}
+	return nil
+}
+
+// exprTables 收集表达式中子查询涉及的表
+func exprTables(expr Expression, add func(ts ...string)) {
+	switch exp := expr.(type) {
+	case Predicate:
+		exprTables(exp.left, add)
+		exprTables(exp.right, add)
+	case MathExpr:
+		exprTables(exp.left, add)
+		exprTables(exp.right, add)
+	case FuncExpr:
+		for _, arg := range exp.args {
+			exprTables(arg, add)
+		}
+	case CaseExpr:
+		for _, w := range exp.whens {
+			exprTables(w.cond, add)
+			exprTables(w.then, add)
+		}
+		exprTables(exp.els, add)
+	case Aggregate:
+		exprTables(exp.arg, add)
+	case distinct:
+		exprTables(exp.arg, add)
+	case WindowExpr:
+		exprTables(exp.fn, add)
+	case Assignment:
+		if e, ok := exp.val.(Expression); ok {
+			exprTables(e, add)
+		}
+	case subqueryExpr:
+		add(subqueryTables(exp.q)...)
+	}
+}
@@ -0,0 +1,58 @@
+package go_orm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestStatement_Tables(t *testing.T) {
+	db, err := OpenDB(nil, DBWithDialect(DialectMySQL))
+	require.NoError(t, err)
+	testCases := []struct {
+		name       string
+		b          StatementInspector
+		wantTables []string
+	}{
+		{
+			name:       "model",
+			b:          NewSelector[TestModel](db),
+			wantTables: []string{"test_model"},
+		},
+		{
+			name:       "from",
+			b:          NewSelector[TestModel](db).From("`test_db`.`users`"),
+			wantTables: []string{"test_db.users"},
+		},
+		{
+			// 无法解析的 From 使用模型的表名
+			name:       "join",
+			b:          NewSelector[TestModel](db).From("`test_model` JOIN `rank_model`"),
+			wantTables: []string{"test_model"},
+		},
+		{
+			name: "subquery",
+			b: NewSelector[TestModel](db).
+				Where(C("Id").InQuery(NewSelector[RankModel](db).Select(C("Id")))),
+			wantTables: []string{"test_model", "rank_model"},
+		},
+		{
+			name: "cte",
+			b: NewUpdater[TestModel](db).
+				With("ranked", NewSelector[RankModel](db).Select(C("Id"))).
+				Set(Assign("Age", 18)).
+				Where(C("Id").InQuery(NewSelector[TestModel](db).From("ranked").Select(C("Id")))),
+			wantTables: []string{"test_model", "rank_model"},
+		},
+		{
+			name:       "deleter",
+			b:          NewDeleter[TestModel](db).Form("`category`"),
+			wantTables: []string{"category"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.wantTables, tc.b.Statement().Tables)
+		})
+	}
+}
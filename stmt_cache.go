@@ -0,0 +1,141 @@
+package go_orm
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"github.com/Andras5014/go-orm/internal/sqlcomment"
+	"sync"
+)
+
+// DBWithStmtCache 缓存最近使用的 size 条预编译语句，相同的 SQL 复用同一个 *sql.Stmt
+// 在事务中通过 tx.StmtContext 使用缓存的语句
+// size 小于等于 0 时不缓存
+// SQL 末尾的注释（例如 sqlcommenter 追加的注释）不参与缓存，预编译的是去掉注释的 SQL
+func DBWithStmtCache(size int) DBOption {
+	return func(db *DB) {
+		// 缓存不下任何语句时每次都要预编译再关闭，比不缓存还慢
+		if size <= 0 {
+			db.stmtCache = nil
+			return
+		}
+		db.stmtCache = newStmtCache(size)
+	}
+}
+
+// StmtCacheStats 预编译语句缓存的统计信息
+type StmtCacheStats struct {
+	// Size 当前缓存的语句数
+	Size      int
+	Hits      int64
+	Misses    int64
+	Evictions int64
+}
+
+type stmtEntry struct {
+	query string
+	stmt  *sql.Stmt
+	// refs 正在使用的次数，被淘汰时等到没有使用才关闭
+	refs    int
+	evicted bool
+}
+
+// stmtCache LRU 缓存
+type stmtCache struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+	stats StmtCacheStats
+}
+
+func newStmtCache(size int) *stmtCache {
+	return &stmtCache{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[string]*list.Element, size),
+	}
+}
+
+// acquire 获取 query 对应的预编译语句，用完之后需要调用 release
+func (c *stmtCache) acquire(ctx context.Context, db *sql.DB, query string) (*stmtEntry, error) {
+	// 注释中的 traceparent 每次都不同，带着注释缓存不会命中
+	query = sqlcomment.Strip(query)
+	c.mu.Lock()
+	if elem, ok := c.items[query]; ok {
+		c.ll.MoveToFront(elem)
+		entry := elem.Value.(*stmtEntry)
+		entry.refs++
+		c.stats.Hits++
+		c.mu.Unlock()
+		return entry, nil
+	}
+	c.stats.Misses++
+	c.mu.Unlock()
+
+	// 预编译的时候不持有锁，并发预编译同一条语句时只保留一个
+	stmt, err := db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[query]; ok {
+		_ = stmt.Close()
+		c.ll.MoveToFront(elem)
+		entry := elem.Value.(*stmtEntry)
+		entry.refs++
+		return entry, nil
+	}
+	entry := &stmtEntry{query: query, stmt: stmt, refs: 1}
+	c.items[query] = c.ll.PushFront(entry)
+	for c.ll.Len() > c.size {
+		c.evict(c.ll.Back())
+	}
+	return entry, nil
+}
+
+func (c *stmtCache) release(entry *stmtEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry.refs--
+	if entry.evicted && entry.refs == 0 {
+		_ = entry.stmt.Close()
+	}
+}
+
+func (c *stmtCache) evict(elem *list.Element) {
+	entry := c.ll.Remove(elem).(*stmtEntry)
+	delete(c.items, entry.query)
+	entry.evicted = true
+	c.stats.Evictions++
+	if entry.refs == 0 {
+		_ = entry.stmt.Close()
+	}
+}
+
+func (c *stmtCache) Stats() StmtCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res := c.stats
+	res.Size = c.ll.Len()
+	return res
+}
+
+// close 关闭所有缓存的语句
+func (c *stmtCache) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.ll.Len() > 0 {
+		c.evict(c.ll.Back())
+	}
+}
+
+// StmtCacheStats 返回预编译语句缓存的统计信息，没有开启缓存时返回零值
+func (d *DB) StmtCacheStats() StmtCacheStats {
+	if d.stmtCache == nil {
+		return StmtCacheStats{}
+	}
+	return d.stmtCache.Stats()
+}
@@ -0,0 +1,137 @@
+package go_orm
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+func TestDBWithStmtCache(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	db, err := OpenDB(mockDB, DBWithStmtCache(2))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	q1 := "SELECT * FROM `test_model` WHERE `id` = ?;"
+	q2 := "DELETE FROM `test_model` WHERE `id` = ?;"
+	q3 := "SELECT * FROM `test_model` WHERE `age` = ?;"
+	p1 := mock.ExpectPrepare(q1).WillBeClosed()
+	p1.ExpectQuery().WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	p1.ExpectQuery().WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	p2 := mock.ExpectPrepare(q2)
+	p2.ExpectExec().WithArgs(1).WillReturnResult(driver.RowsAffected(1))
+	p3 := mock.ExpectPrepare(q3)
+	p3.ExpectQuery().WithArgs(18).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+
+	res, err := NewSelector[TestModel](db).Where(C("Id").Eq(1)).Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), res.Id)
+	// 命中缓存，不会再预编译
+	res, err = NewSelector[TestModel](db).Where(C("Id").Eq(2)).Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), res.Id)
+	require.NoError(t, NewDeleter[TestModel](db).Where(C("Id").Eq(1)).Exec(ctx).Err())
+	// 淘汰最久没有使用的 q1 并且关闭
+	_, err = NewSelector[TestModel](db).Where(C("Age").Eq(18)).Get(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, StmtCacheStats{
+		Size:      2,
+		Hits:      1,
+		Misses:    3,
+		Evictions: 1,
+	}, db.StmtCacheStats())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDBWithStmtCache_Tx(t *testing.T) {
+	db, err := Open("sqlite3", "file:"+t.Name()+"?mode=memory&cache=shared",
+		DBWithDialect(DialectSQLite), DBWithStmtCache(8))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	ctx := context.Background()
+	err = RawQuery[TestModel](db, "CREATE TABLE test_model (id INTEGER PRIMARY KEY, first_name TEXT, age INTEGER, last_name TEXT)").Exec(ctx).Err()
+	require.NoError(t, err)
+
+	err = db.DoTx(ctx, func(ctx context.Context, tx *Tx) error {
+		for i := 1; i <= 3; i++ {
+			if err := NewInserter[TestModel](tx).Values(&TestModel{Id: int64(i), Age: 18}).Exec(ctx).Err(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				res, err := NewSelector[TestModel](db).Where(C("Id").Eq(i)).Get(ctx)
+				if assert.NoError(t, err) {
+					assert.Equal(t, int64(i), res.Id)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	stats := db.StmtCacheStats()
+	// CREATE TABLE、INSERT 和 SELECT
+	assert.Equal(t, 3, stats.Size, fmt.Sprintf("%+v", stats))
+	assert.Equal(t, int64(0), stats.Evictions)
+}
+
+func TestDBWithStmtCache_Comment(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	// 模拟 sqlcommenter，每次追加不同的注释
+	var cnt int
+	comment := func(next Handler) Handler {
+		return func(ctx context.Context, qc *QueryContext) *QueryResult {
+			q, err := qc.Query()
+			if err == nil {
+				cnt++
+				q.SQL = fmt.Sprintf("%s /*traceparent='%d'*/;", q.SQL[:len(q.SQL)-1], cnt)
+			}
+			return next(ctx, qc)
+		}
+	}
+	db, err := OpenDB(mockDB, DBWithStmtCache(2), DBWithMiddlewares(comment))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	p := mock.ExpectPrepare("SELECT * FROM `test_model` WHERE `id` = ?;")
+	p.ExpectQuery().WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	p.ExpectQuery().WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	_, err = NewSelector[TestModel](db).Where(C("Id").Eq(1)).Get(ctx)
+	require.NoError(t, err)
+	_, err = NewSelector[TestModel](db).Where(C("Id").Eq(2)).Get(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, cnt)
+	assert.Equal(t, StmtCacheStats{Size: 1, Hits: 1, Misses: 1}, db.StmtCacheStats())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDBWithStmtCache_Disabled(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	db, err := OpenDB(mockDB, DBWithStmtCache(0))
+	require.NoError(t, err)
+
+	// 不预编译，直接执行
+	mock.ExpectExec("DELETE FROM `test_model` WHERE `id` = ?;").WithArgs(1).WillReturnResult(driver.RowsAffected(1))
+	require.NoError(t, NewDeleter[TestModel](db).Where(C("Id").Eq(1)).Exec(context.Background()).Err())
+	assert.Nil(t, db.stmtCache)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,219 @@
+package go_orm
+
+import (
+	"context"
+	"database/sql"
+	"github.com/Andras5014/go-orm/internal/errs"
+	"github.com/Andras5014/go-orm/model"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+type tenantKey struct{}
+
+// WithTenant 把租户 id 放入 context，默认的 TenantResolver 从这里读取
+func WithTenant(ctx context.Context, tenant any) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext 默认的 TenantResolver，读取 WithTenant 放入的租户 id
+func TenantFromContext(ctx context.Context) (any, bool) {
+	tenant := ctx.Value(tenantKey{})
+	return tenant, tenant != nil
+}
+
+// TenantResolver 从 context 中解析租户 id
+type TenantResolver func(ctx context.Context) (any, bool)
+
+// DBWithTenantResolver 指定从 context 中解析租户 id 的方法，默认为 TenantFromContext
+func DBWithTenantResolver(resolver TenantResolver) DBOption {
+	return func(db *DB) {
+		db.tenantResolver = resolver
+	}
+}
+
+// DBWithTenantSchema 每个租户一个 schema，表名前面会加上 schema(tenant)
+// 开启之后所有 Selector、Updater、Deleter 和 Inserter 都必须带上租户
+// From 只能指定单独的表名或者 CTE 的名字，JOIN 之类的写法返回 ErrTenantSchemaTable
+func DBWithTenantSchema(schema func(tenant any) string) DBOption {
+	return func(db *DB) {
+		db.tenantSchema = schema
+	}
+}
+
+// TenantDB 每个租户一个数据库，按照 context 中的租户路由到 resolve 返回的数据库
+// 所有租户共享同一份配置，例如方言、元数据和中间件，
+// 预编译语句和数据库绑定，开启 DBWithStmtCache 时每个数据库单独缓存
+type TenantDB struct {
+	core
+	// base OpenDB 得到的配置，DB 复制之后替换数据库
+	base    *DB
+	resolve func(ctx context.Context, tenant any) (*sql.DB, error)
+
+	mu         sync.Mutex
+	stmtCaches map[*sql.DB]*stmtCache
+}
+
+var _ Session = &TenantDB{}
+
+func OpenTenantDB(resolve func(ctx context.Context, tenant any) (*sql.DB, error), opts ...DBOption) (*TenantDB, error) {
+	db, err := OpenDB(nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &TenantDB{
+		core:    db.core,
+		base:    db,
+		resolve: resolve,
+	}, nil
+}
+
+// DB 返回当前租户的 DB，可以用来开启事务
+func (t *TenantDB) DB(ctx context.Context) (*DB, error) {
+	tenant, ok := t.tenantResolver(ctx)
+	if !ok {
+		return nil, errs.ErrMissingTenant
+	}
+	db, err := t.resolve(ctx, tenant)
+	if err != nil {
+		return nil, err
+	}
+	res := *t.base
+	res.db = db
+	if t.base.stmtCache != nil {
+		res.stmtCache = t.stmtCache(db)
+	}
+	return &res, nil
+}
+
+// stmtCache 返回数据库对应的预编译语句缓存，不存在时按照 base 的大小创建
+func (t *TenantDB) stmtCache(db *sql.DB) *stmtCache {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if c, ok := t.stmtCaches[db]; ok {
+		return c
+	}
+	if t.stmtCaches == nil {
+		t.stmtCaches = make(map[*sql.DB]*stmtCache)
+	}
+	c := newStmtCache(t.base.stmtCache.size)
+	t.stmtCaches[db] = c
+	return c
+}
+
+// Close 关闭所有缓存的预编译语句，resolve 返回的数据库由调用者关闭
+func (t *TenantDB) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for db, c := range t.stmtCaches {
+		c.close()
+		delete(t.stmtCaches, db)
+	}
+	return nil
+}
+
+func (t *TenantDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	db, err := t.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return db.BeginTx(ctx, opts)
+}
+
+func (t *TenantDB) getCore() core {
+	return t.core
+}
+
+func (t *TenantDB) queryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	db, err := t.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return db.queryContext(ctx, query, args...)
+}
+
+func (t *TenantDB) execContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	db, err := t.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return db.execContext(ctx, query, args...)
+}
+
+// tenantRequired 模型有租户字段或者按 schema 隔离租户时，查询必须带上租户
+func (b *builder) tenantRequired() bool {
+	return b.model.TenantField != nil || b.tenantSchema != nil
+}
+
+// bindTenant 从 context 中解析本次查询的租户
+func (b *builder) bindTenant(ctx context.Context) error {
+	b.tenant = nil
+	if !b.tenantRequired() {
+		return nil
+	}
+	tenant, ok := b.tenantResolver(ctx)
+	if !ok || tenant == nil {
+		return errs.ErrMissingTenant
+	}
+	b.tenant = tenant
+	return nil
+}
+
+func (b *builder) checkTenant() error {
+	if b.tenantRequired() && b.tenant == nil {
+		return errs.ErrMissingTenant
+	}
+	return nil
+}
+
+// quoteTable 写入表名，按 schema 隔离租户时加上租户的 schema
+func (b *builder) quoteTable(name string) {
+	if b.tenantSchema != nil {
+		b.quote(b.tenantSchema(b.tenant))
+		b.sb.WriteByte('.')
+	}
+	b.quote(name)
+}
+
+// buildTable 写入表名，table 是 From 指定的表，为空时使用模型的表名
+// 按 schema 隔离租户时 table 只能是单独的表名或者 CTE 的名字，表名会加上租户的 schema；
+// JOIN 或者带 schema 的表无法保证只访问租户自己的 schema，返回 ErrTenantSchemaTable
+func (b *builder) buildTable(table string) error {
+	if table == "" {
+		b.quoteTable(b.model.TableName)
+		return nil
+	}
+	if b.tenantSchema == nil {
+		// 自己指定表名，不会自动加引号，因为可能是 db.table 或者 JOIN 这种形式
+		b.sb.WriteString(table)
+		return nil
+	}
+	name := tableName(table)
+	if name == "" || strings.Contains(name, ".") {
+		return errs.ErrTenantSchemaTable
+	}
+	for _, c := range b.ctes {
+		if c.name == name {
+			b.quote(name)
+			return nil
+		}
+	}
+	b.quoteTable(name)
+	return nil
+}
+
+// fillTenant 把租户 id 写入实体的租户字段
+func fillTenant(m *model.Model, entity any, tenant any) error {
+	fd := m.TenantField
+	if fd == nil {
+		return nil
+	}
+	val := reflect.ValueOf(tenant)
+	// 和 setField 一样不在数字和字符串之间转换，Go 会把 7 转换成 "\a" 而不是 "7"
+	if !val.Type().ConvertibleTo(fd.Typ) || (val.Kind() == reflect.String) != (fd.Typ.Kind() == reflect.String) {
+		return errs.NewErrUnsupportedTenantType(tenant, fd.Typ)
+	}
+	reflect.ValueOf(entity).Elem().FieldByName(fd.GoName).Set(val.Convert(fd.Typ))
+	return nil
+}
@@ -0,0 +1,335 @@
+package go_orm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"testing"
+)
+
+type TenantModel struct {
+	Id       int64
+	TenantId int64 `orm:"tenant"`
+	Name     string
+}
+
+type StringTenantModel struct {
+	Id       int64
+	TenantId string `orm:"tenant"`
+}
+
+func TestTenant_Column(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	db, err := OpenDB(mockDB)
+	require.NoError(t, err)
+	ctx := WithTenant(context.Background(), 7)
+
+	testCases := []struct {
+		name    string
+		ctx     context.Context
+		mock    func()
+		exec    func(ctx context.Context) error
+		wantErr error
+	}{
+		{
+			name: "select",
+			ctx:  ctx,
+			mock: func() {
+				mock.ExpectQuery("SELECT * FROM `tenant_model` WHERE (`id` = ?) AND (`tenant_id` = ?);").
+					WithArgs(1, 7).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "tenant_id", "name"}).AddRow(1, 7, "a"))
+			},
+			exec: func(ctx context.Context) error {
+				_, err := NewSelector[TenantModel](db).Where(C("Id").Eq(1)).Get(ctx)
+				return err
+			},
+		},
+		{
+			name: "select without where",
+			ctx:  ctx,
+			mock: func() {
+				mock.ExpectQuery("SELECT * FROM `tenant_model` WHERE `tenant_id` = ?;").
+					WithArgs(7).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			},
+			exec: func(ctx context.Context) error {
+				_, err := NewSelector[TenantModel](db).GetMulti(ctx)
+				return err
+			},
+		},
+		{
+			name: "select missing tenant",
+			ctx:  context.Background(),
+			mock: func() {},
+			exec: func(ctx context.Context) error {
+				_, err := NewSelector[TenantModel](db).GetMulti(ctx)
+				return err
+			},
+			wantErr: ErrMissingTenant,
+		},
+		{
+			name: "update unscoped",
+			ctx:  ctx,
+			mock: func() {
+				mock.ExpectExec("UPDATE `tenant_model` SET `name` = ? WHERE `tenant_id` = ?;").
+					WithArgs("b", 7).
+					WillReturnResult(driver.RowsAffected(1))
+			},
+			exec: func(ctx context.Context) error {
+				return NewUpdater[TenantModel](db).Set(Assign("Name", "b")).Unscoped().Exec(ctx).Err()
+			},
+		},
+		{
+			name: "update missing tenant",
+			ctx:  context.Background(),
+			mock: func() {},
+			exec: func(ctx context.Context) error {
+				return NewUpdater[TenantModel](db).Set(Assign("Name", "b")).Exec(ctx).Err()
+			},
+			wantErr: ErrMissingTenant,
+		},
+		{
+			name: "delete",
+			ctx:  ctx,
+			mock: func() {
+				mock.ExpectExec("DELETE FROM `tenant_model` WHERE (`id` = ?) AND (`tenant_id` = ?);").
+					WithArgs(1, 7).
+					WillReturnResult(driver.RowsAffected(1))
+			},
+			exec: func(ctx context.Context) error {
+				return NewDeleter[TenantModel](db).Where(C("Id").Eq(1)).Exec(ctx).Err()
+			},
+		},
+		{
+			name: "delete missing tenant",
+			ctx:  context.Background(),
+			mock: func() {},
+			exec: func(ctx context.Context) error {
+				return NewDeleter[TenantModel](db).Exec(ctx).Err()
+			},
+			wantErr: ErrMissingTenant,
+		},
+		{
+			name: "insert",
+			ctx:  ctx,
+			mock: func() {
+				mock.ExpectExec("INSERT INTO `tenant_model` (`id`,`name`,`tenant_id`) VALUES (?,?,?);").
+					WithArgs(1, "a", 7).
+					WillReturnResult(driver.RowsAffected(1))
+			},
+			exec: func(ctx context.Context) error {
+				// 即便实体上写了别的租户也会被覆盖
+				return NewInserter[TenantModel](db).Columns("Id", "Name").
+					Values(&TenantModel{Id: 1, TenantId: 8, Name: "a"}).Exec(ctx).Err()
+			},
+		},
+		{
+			name: "insert missing tenant",
+			ctx:  context.Background(),
+			mock: func() {},
+			exec: func(ctx context.Context) error {
+				return NewInserter[TenantModel](db).Values(&TenantModel{Id: 1}).Exec(ctx).Err()
+			},
+			wantErr: ErrMissingTenant,
+		},
+		{
+			name: "insert invalid tenant type",
+			ctx:  WithTenant(context.Background(), "t1"),
+			mock: func() {},
+			exec: func(ctx context.Context) error {
+				return NewInserter[TenantModel](db).Values(&TenantModel{Id: 1}).Exec(ctx).Err()
+			},
+			wantErr: fmt.Errorf("orm: tenant t1 can not be converted to int64"),
+		},
+		{
+			name: "insert int tenant into string field",
+			ctx:  ctx,
+			mock: func() {},
+			exec: func(ctx context.Context) error {
+				return NewInserter[StringTenantModel](db).Values(&StringTenantModel{Id: 1}).Exec(ctx).Err()
+			},
+			wantErr: fmt.Errorf("orm: tenant 7 can not be converted to string"),
+		},
+		{
+			name: "upsert",
+			ctx:  ctx,
+			mock: func() {},
+			exec: func(ctx context.Context) error {
+				return NewInserter[TenantModel](db).Values(&TenantModel{Id: 1, Name: "a"}).
+					OnConflict().Update(Assign("Name", "b")).Exec(ctx).Err()
+			},
+			wantErr: ErrTenantUpsert,
+		},
+		{
+			name: "insert ignore",
+			ctx:  ctx,
+			mock: func() {
+				mock.ExpectExec("INSERT IGNORE INTO `tenant_model` (`id`,`tenant_id`,`name`) VALUES (?,?,?);").
+					WithArgs(1, 7, "a").
+					WillReturnResult(driver.RowsAffected(0))
+			},
+			exec: func(ctx context.Context) error {
+				return NewInserter[TenantModel](db).Values(&TenantModel{Id: 1, Name: "a"}).
+					OnConflict().DoNothing().Exec(ctx).Err()
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mock()
+			err := tc.exec(tc.ctx)
+			assert.Equal(t, tc.wantErr, err)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestTenant_Build(t *testing.T) {
+	db := memoryDB(t)
+	_, err := NewSelector[TenantModel](db).Build()
+	assert.Equal(t, ErrMissingTenant, err)
+}
+
+func TestTenant_Schema(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockDB, DBWithTenantSchema(func(tenant any) string {
+		return fmt.Sprintf("tenant_%v", tenant)
+	}))
+	require.NoError(t, err)
+	ctx := WithTenant(context.Background(), "acme")
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `tenant_acme`.`test_model` WHERE `id` = ?;")).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	_, err = NewSelector[TestModel](db).Where(C("Id").Eq(1)).Get(ctx)
+	require.NoError(t, err)
+
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `tenant_acme`.`test_model` (`id`,`first_name`,`age`,`last_name`) VALUES (?,?,?,?);")).
+		WillReturnResult(driver.RowsAffected(1))
+	err = NewInserter[TestModel](db).Values(&TestModel{Id: 1}).Exec(ctx).Err()
+	require.NoError(t, err)
+
+	err = NewDeleter[TestModel](db).Exec(context.Background()).Err()
+	assert.Equal(t, ErrMissingTenant, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTenant_SchemaFrom(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	db, err := OpenDB(mockDB, DBWithTenantSchema(func(tenant any) string {
+		return fmt.Sprintf("tenant_%v", tenant)
+	}))
+	require.NoError(t, err)
+	ctx := WithTenant(context.Background(), "acme")
+
+	// 单独的表名加上租户的 schema
+	mock.ExpectQuery("SELECT * FROM `tenant_acme`.`test_model`;").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	_, err = NewSelector[TestModel](db).From("`test_model`").Get(ctx)
+	require.NoError(t, err)
+
+	mock.ExpectExec("DELETE FROM `tenant_acme`.`other` WHERE `id` = ?;").
+		WithArgs(1).
+		WillReturnResult(driver.RowsAffected(1))
+	err = NewDeleter[TestModel](db).Form("other").Where(C("Id").Eq(1)).Exec(ctx).Err()
+	require.NoError(t, err)
+
+	// CTE 的名字不加 schema
+	mock.ExpectQuery("WITH `t` AS (SELECT * FROM `tenant_acme`.`test_model`) SELECT * FROM `t`;").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	_, err = NewSelector[TestModel](db).With("t", NewSelector[TestModel](db)).From("`t`").Get(ctx)
+	require.NoError(t, err)
+
+	// JOIN 和其它 schema 的表无法隔离租户
+	_, err = NewSelector[TestModel](db).From("`test_model` JOIN `other` ON `test_model`.`id` = `other`.`id`").Get(ctx)
+	assert.Equal(t, ErrTenantSchemaTable, err)
+	_, err = NewSelector[TestModel](db).From("`public`.`test_model`").Get(ctx)
+	assert.Equal(t, ErrTenantSchemaTable, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTenantDB(t *testing.T) {
+	dbs := make(map[string]*sql.DB, 2)
+	mocks := make(map[string]sqlmock.Sqlmock, 2)
+	for _, tenant := range []string{"a", "b"} {
+		mockDB, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		dbs[tenant] = mockDB
+		mocks[tenant] = mock
+	}
+	db, err := OpenTenantDB(func(ctx context.Context, tenant any) (*sql.DB, error) {
+		res, ok := dbs[tenant.(string)]
+		if !ok {
+			return nil, fmt.Errorf("unknown tenant %v", tenant)
+		}
+		return res, nil
+	})
+	require.NoError(t, err)
+
+	mocks["b"].ExpectExec("DELETE FROM `test_model` WHERE .*").WillReturnResult(driver.RowsAffected(1))
+	err = NewDeleter[TestModel](db).Where(C("Id").Eq(1)).Exec(WithTenant(context.Background(), "b")).Err()
+	require.NoError(t, err)
+
+	mocks["a"].ExpectBegin()
+	mocks["a"].ExpectQuery("SELECT .*").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mocks["a"].ExpectCommit()
+	ctx := WithTenant(context.Background(), "a")
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	_, err = NewSelector[TestModel](tx).Get(ctx)
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+
+	_, err = NewSelector[TestModel](db).Get(context.Background())
+	assert.Equal(t, ErrMissingTenant, err)
+	_, err = NewSelector[TestModel](db).Get(WithTenant(context.Background(), "c"))
+	assert.Equal(t, fmt.Errorf("unknown tenant c"), err)
+
+	for _, mock := range mocks {
+		require.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestTenantDB_StmtCache(t *testing.T) {
+	dbs := make(map[string]*sql.DB, 2)
+	mocks := make(map[string]sqlmock.Sqlmock, 2)
+	for _, tenant := range []string{"a", "b"} {
+		mockDB, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		dbs[tenant] = mockDB
+		mocks[tenant] = mock
+	}
+	db, err := OpenTenantDB(func(ctx context.Context, tenant any) (*sql.DB, error) {
+		return dbs[tenant.(string)], nil
+	}, DBWithStmtCache(8))
+	require.NoError(t, err)
+
+	// 相同的 SQL 在每个租户的数据库上分别预编译
+	for _, tenant := range []string{"a", "b"} {
+		mock := mocks[tenant]
+		prep := mock.ExpectPrepare("DELETE FROM `test_model` WHERE .*")
+		prep.ExpectExec().WithArgs(1).WillReturnResult(driver.RowsAffected(1))
+		prep.ExpectExec().WithArgs(1).WillReturnResult(driver.RowsAffected(1))
+		prep.WillBeClosed()
+		ctx := WithTenant(context.Background(), tenant)
+		for i := 0; i < 2; i++ {
+			err = NewDeleter[TestModel](db).Where(C("Id").Eq(1)).Exec(ctx).Err()
+			require.NoError(t, err)
+		}
+		tdb, err := db.DB(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, StmtCacheStats{Size: 1, Hits: 1, Misses: 1}, tdb.StmtCacheStats())
+	}
+	require.NoError(t, db.Close())
+	for _, mock := range mocks {
+		require.NoError(t, mock.ExpectationsWereMet())
+	}
+}
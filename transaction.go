@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"sync"
 )
 
 var (
@@ -22,22 +23,62 @@ type Tx struct {
 	db *DB
 	// 事务是否已经提交
 	done bool
+
+	mu sync.Mutex
+	// onCommit 提交成功之后执行的回调
+	onCommit []func()
 }
 
 func (t *Tx) getCore() core {
 	return t.db.core
 }
 func (t *Tx) queryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
-	return t.tx.QueryContext(ctx, query, args...)
+	cache := t.db.stmtCache
+	if cache == nil {
+		return t.tx.QueryContext(ctx, query, args...)
+	}
+	entry, err := cache.acquire(ctx, t.db.db, query)
+	if err != nil {
+		return nil, err
+	}
+	defer cache.release(entry)
+	// 事务中的语句在事务结束时关闭
+	return t.tx.StmtContext(ctx, entry.stmt).QueryContext(ctx, args...)
 }
 
 func (t *Tx) execContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
-	return t.tx.ExecContext(ctx, query, args...)
+	cache := t.db.stmtCache
+	if cache == nil {
+		return t.tx.ExecContext(ctx, query, args...)
+	}
+	entry, err := cache.acquire(ctx, t.db.db, query)
+	if err != nil {
+		return nil, err
+	}
+	defer cache.release(entry)
+	return t.tx.StmtContext(ctx, entry.stmt).ExecContext(ctx, args...)
+}
+
+// OnCommit 注册提交成功之后执行的回调，例如失效缓存，回滚或者提交失败时不执行
+func (t *Tx) OnCommit(fn func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onCommit = append(t.onCommit, fn)
 }
 
 func (t *Tx) Commit() error {
 	t.done = true
-	return t.tx.Commit()
+	if err := t.tx.Commit(); err != nil {
+		return err
+	}
+	t.mu.Lock()
+	fns := t.onCommit
+	t.onCommit = nil
+	t.mu.Unlock()
+	for _, fn := range fns {
+		fn()
+	}
+	return nil
 }
 func (t *Tx) Rollback() error {
 	t.done = true
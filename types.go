@@ -22,4 +22,7 @@ type QueryBuilder interface {
 type Query struct {
 	SQL  string
 	Args []any
+	// ArgColumns 每个参数对应的列名，不对应任何列的参数为空字符串，例如 LIMIT 的参数
+	// 由构造器生成，和 Args 一一对应，原生查询和改写过参数的查询可能为 nil
+	ArgColumns []string
 }
@@ -3,6 +3,9 @@ package go_orm
 import (
 	"context"
 	"github.com/Andras5014/go-orm/internal/errs"
+	"github.com/Andras5014/go-orm/model"
+	"reflect"
+	"slices"
 )
 
 type Updater[T any] struct {
@@ -11,7 +14,9 @@ type Updater[T any] struct {
 	assigns []Assignable
 	val     *T
 	where   []Predicate
-	sess    Session
+	// unscoped 不追加软删除等自动过滤条件
+	unscoped bool
+	sess     Session
 }
 
 func NewUpdater[T any](sess Session) *Updater[T] {
@@ -25,18 +30,28 @@ func NewUpdater[T any](sess Session) *Updater[T] {
 	}
 }
 
+// Build 在副本上构造 SQL，不会修改 Updater 本身，可以重复调用，也可以并发调用
 func (u *Updater[T]) Build() (*Query, error) {
+	b := *u
+	return b.build()
+}
+
+func (u *Updater[T]) build() (*Query, error) {
 	m, err := u.r.Get(new(T))
 	if err != nil {
 		return nil, err
 	}
 	u.model = m
+	if err = u.checkTenant(); err != nil {
+		return nil, err
+	}
 
+	if err = u.buildWith(); err != nil {
+		return nil, err
+	}
 	u.sb.WriteString("UPDATE ")
-	if u.table == "" {
-		u.quote(m.TableName)
-	} else {
-		u.sb.WriteString(u.table)
+	if err = u.buildTable(u.table); err != nil {
+		return nil, err
 	}
 
 	u.sb.WriteString(" SET ")
@@ -44,19 +59,36 @@ func (u *Updater[T]) Build() (*Query, error) {
 		return nil, errs.ErrNoUpdatedColumns
 	}
 
-	for i, s := range u.assigns {
+	assigns, err := blindIndexAssigns(m, u.assigns)
+	if err != nil {
+		return nil, err
+	}
+	assigns = autoUpdateAssigns(m, assigns, u.clock())
+	for i, s := range assigns {
 		if i > 0 {
 			u.sb.WriteByte(',')
 		}
 		switch v := s.(type) {
 		case Assignment:
-			fd, ok := m.FieldMap[v.col]
+			if err = u.buildAssignment(v); err != nil {
+				return nil, err
+			}
+		case Column:
+			// 使用实体中的值
+			if u.val == nil {
+				return nil, errs.NewErrUnsupportedAssignableType(s)
+			}
+			fd, ok := m.FieldMap[v.name]
 			if !ok {
-				return nil, errs.NewErrUnknownField(v.col)
+				return nil, errs.NewErrUnknownField(v.name)
+			}
+			val, err := u.creator(m, u.val).Field(fd.GoName)
+			if err != nil {
+				return nil, err
+			}
+			if err = u.buildAssignment(Assign(fd.GoName, val)); err != nil {
+				return nil, err
 			}
-			u.quote(fd.ColName)
-			u.sb.WriteString(" = ?")
-			u.addArg(v.val)
 		case RawExpr:
 			u.sb.WriteString(v.raw)
 			u.addArg(v.args...)
@@ -65,21 +97,26 @@ func (u *Updater[T]) Build() (*Query, error) {
 		}
 	}
 
-	if len(u.where) > 0 {
+	where := u.scope(u.where, u.unscoped, u.table)
+	if len(where) > 0 {
 		u.sb.WriteString(" WHERE ")
-		if err = u.buildPredicates(u.where); err != nil {
+		if err = u.buildPredicates(where); err != nil {
 			return nil, err
 		}
 	}
 
 	u.sb.WriteByte(';')
-	return &Query{
-		SQL:  u.sb.String(),
-		Args: u.args,
-	}, nil
+	return u.query(), nil
 
 }
 
+// Update 指定更新的实体，Set(C("FirstName")) 会使用实体中对应字段的值，
+// BeforeUpdate 和 AfterUpdate 也在这个实体上调用
+func (u *Updater[T]) Update(val *T) *Updater[T] {
+	u.val = val
+	return u
+}
+
 func (u *Updater[T]) Set(assignments ...Assignable) *Updater[T] {
 	u.assigns = append(u.assigns, assignments...)
 	return u
@@ -90,11 +127,179 @@ func (u *Updater[T]) Where(predicates Predicate) *Updater[T] {
 	return u
 }
 
+// With 添加公共表表达式 WITH name AS (q)，可以在子查询中把 name 当作表使用
+func (u *Updater[T]) With(name string, q QueryBuilder) *Updater[T] {
+	u.ctes = append(u.ctes, cte{name: name, query: q})
+	return u
+}
+
+// WithRecursive 添加递归公共表表达式 WITH RECURSIVE name AS (anchor UNION ALL recursive)
+func (u *Updater[T]) WithRecursive(name string, anchor, recursive QueryBuilder) *Updater[T] {
+	u.ctes = append(u.ctes, cte{name: name, query: anchor, recursive: recursive})
+	return u
+}
+
+// Unscoped 更新时不再自动过滤软删除的数据
+func (u *Updater[T]) Unscoped() *Updater[T] {
+	u.unscoped = true
+	return u
+}
+
+// AllowFullTableScan 显式允许没有 WHERE 的更新
+func (u *Updater[T]) AllowFullTableScan() *Updater[T] {
+	u.allowFullTableScan = true
+	return u
+}
+
+func (u *Updater[T]) Statement() StatementInfo {
+	m, _ := u.r.Get(new(T))
+	exprs := make([]Expression, 0, len(u.assigns)+len(u.where))
+	for _, a := range u.assigns {
+		if e, ok := a.(Expression); ok {
+			exprs = append(exprs, e)
+		}
+	}
+	for _, p := range u.where {
+		exprs = append(exprs, p)
+	}
+	return StatementInfo{
+		HasWhere:             len(u.where) > 0,
+		FullTableScanAllowed: u.allowFullTableScan,
+		Tables:               u.tables(m, u.table, exprs...),
+	}
+}
+
+// Clone 复制一个 Updater，副本和原来的 Updater 互不影响
+func (u *Updater[T]) Clone() *Updater[T] {
+	res := *u
+	res.assigns = slices.Clone(u.assigns)
+	res.where = slices.Clone(u.where)
+	res.ctes = slices.Clone(u.ctes)
+	return &res
+}
+
 func (u *Updater[T]) Exec(ctx context.Context) Result {
-	q, err := u.Build()
+	// 在副本上执行，同一个 Updater 可以并发执行
+	cp := *u
+	u = &cp
+	// 没有调用 Update 时钩子作用在零值实体上，但是 Set(C(...)) 依旧不能读取这个实体
+	entity := u.val
+	if entity == nil {
+		entity = new(T)
+	}
+	var err error
+	u.model, err = u.r.Get(entity)
 	if err != nil {
 		return Result{err: err}
 	}
-	res, err := u.sess.execContext(ctx, q.SQL, q.Args...)
-	return Result{err: err, res: res}
+	if err = u.beforeUpdate(ctx, entity); err != nil {
+		return Result{err: err}
+	}
+	if err = u.bindTenant(ctx); err != nil {
+		return Result{err: err}
+	}
+	res := exec(ctx, u.sess, u.core, &QueryContext{
+		Type:    "UPDATE",
+		Builder: u,
+		Model:   u.model,
+	})
+	r := Result{err: res.Err}
+	if res.Result != nil {
+		r = res.Result.(Result)
+	}
+	if r.err != nil {
+		return r
+	}
+	if err = afterUpdate(ctx, u.sess, entity); err != nil {
+		return Result{err: err, res: r.res}
+	}
+	return r
+}
+
+// beforeUpdate 调用 BeforeUpdate 钩子，钩子可以读取和修改要写入的值：
+// 调用前把字面量赋值写入实体，调用后钩子修改过的字段写回赋值，没有赋值的字段追加为新的赋值
+func (u *Updater[T]) beforeUpdate(ctx context.Context, entity *T) error {
+	if _, ok := any(entity).(BeforeUpdateHook); !ok {
+		return nil
+	}
+	val := reflect.ValueOf(entity).Elem()
+	for _, assign := range u.assigns {
+		if a, ok := assign.(Assignment); ok {
+			setField(val, u.model, a.col, a.val)
+		}
+	}
+	// 未导出的字段无法读取，不参与比较
+	before := make([]any, len(u.model.Fields))
+	for i, fd := range u.model.Fields {
+		if f := val.FieldByName(fd.GoName); f.CanInterface() {
+			before[i] = f.Interface()
+		}
+	}
+	if err := beforeUpdate(ctx, u.sess, entity); err != nil {
+		return err
+	}
+	changed := make(map[string]any, len(u.model.Fields))
+	for i, fd := range u.model.Fields {
+		f := val.FieldByName(fd.GoName)
+		if !f.CanInterface() {
+			continue
+		}
+		after := f.Interface()
+		if !reflect.DeepEqual(before[i], after) {
+			changed[fd.GoName] = after
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	assigns := make([]Assignable, 0, len(u.assigns)+len(changed))
+	for _, assign := range u.assigns {
+		switch a := assign.(type) {
+		case Assignment:
+			if v, ok := changed[a.col]; ok {
+				if _, isExpr := a.val.(Expression); !isExpr {
+					a.val = v
+				}
+				delete(changed, a.col)
+			}
+			assign = a
+		case Column:
+			// 构造时读取实体，已经是修改后的值
+			delete(changed, a.name)
+		}
+		assigns = append(assigns, assign)
+	}
+	for _, fd := range u.model.Fields {
+		if v, ok := changed[fd.GoName]; ok {
+			assigns = append(assigns, Assign(fd.GoName, v))
+		}
+	}
+	u.assigns = assigns
+	return nil
+}
+
+// setField 把赋值写入实体，类型不兼容的值（例如表达式）跳过
+func setField(val reflect.Value, m *model.Model, name string, v any) {
+	fd, ok := m.FieldMap[name]
+	if !ok {
+		return
+	}
+	field := val.FieldByName(fd.GoName)
+	if !field.CanSet() {
+		return
+	}
+	if v == nil {
+		switch field.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Interface:
+			field.Set(reflect.Zero(field.Type()))
+		}
+		return
+	}
+	rv := reflect.ValueOf(v)
+	switch {
+	case rv.Type().AssignableTo(field.Type()):
+		field.Set(rv)
+	case rv.Type().ConvertibleTo(field.Type()) && (rv.Kind() == reflect.String) == (field.Kind() == reflect.String):
+		field.Set(rv.Convert(field.Type()))
+	}
 }
@@ -1,9 +1,14 @@
 package go_orm
 
 import (
+	"context"
+	"database/sql"
 	"github.com/Andras5014/go-orm/internal/errs"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestUpdater_Build(t *testing.T) {
@@ -11,7 +16,7 @@ func TestUpdater_Build(t *testing.T) {
 	assert.NoError(t, err)
 	testCases := []struct {
 		name      string
-		u         *Updater[TestModel]
+		u         QueryBuilder
 		wantQuery *Query
 		wantErr   error
 	}{
@@ -25,8 +30,39 @@ func TestUpdater_Build(t *testing.T) {
 			u: NewUpdater[TestModel](db).
 				Set(Assign("FirstName", "newA"), Assign("LastName", "newB")),
 			wantQuery: &Query{
-				SQL:  "UPDATE `test_model` SET `first_name` = ?,`last_name` = ?;",
-				Args: []any{"newA", "newB"},
+				SQL:        "UPDATE `test_model` SET `first_name` = ?,`last_name` = ?;",
+				Args:       []any{"newA", "newB"},
+				ArgColumns: []string{"first_name", "last_name"},
+			},
+		},
+		{
+			name: "set expression",
+			u: NewUpdater[TestModel](db).
+				Set(Assign("Age", C("Age").Add(1)), Assign("FirstName", Upper(C("FirstName"))), Assign("LastName", C("FirstName"))).
+				Where(C("Id").Eq(1)),
+			wantQuery: &Query{
+				SQL:        "UPDATE `test_model` SET `age` = `age` + ?,`first_name` = UPPER(`first_name`),`last_name` = `first_name` WHERE `id` = ?;",
+				Args:       []any{1, 1},
+				ArgColumns: []string{"age", "id"},
+			},
+		},
+		{
+			name: "set expression invalid column",
+			u: NewUpdater[TestModel](db).
+				Set(Assign("Age", C("Invalid").Div(2))),
+			wantErr: errs.NewErrUnknownColumn("Invalid"),
+		},
+		{
+			name: "with",
+			u: NewUpdater[TestModel](db).
+				With("young", NewSelector[TestModel](db).Select(C("Id")).Where(C("Age").Lt(18))).
+				Set(Assign("FirstName", "kid")).
+				Where(C("Id").InQuery(NewSelector[TestModel](db).Select(C("Id")).From("`young`"))),
+			wantQuery: &Query{
+				SQL: "WITH `young` AS (SELECT `id` FROM `test_model` WHERE `age` < ?) UPDATE `test_model` SET `first_name` = ?" +
+					" WHERE `id` IN (SELECT `id` FROM `young`);",
+				Args:       []any{18, "kid"},
+				ArgColumns: []string{"age", "first_name"},
 			},
 		},
 		{
@@ -34,8 +70,9 @@ func TestUpdater_Build(t *testing.T) {
 			u: NewUpdater[TestModel](db).
 				Set(Raw("first_name = ?", "newA"), Raw("last_name = first_name")),
 			wantQuery: &Query{
-				SQL:  "UPDATE `test_model` SET first_name = ?,last_name = first_name;",
-				Args: []any{"newA"},
+				SQL:        "UPDATE `test_model` SET first_name = ?,last_name = first_name;",
+				Args:       []any{"newA"},
+				ArgColumns: []string{""},
 			},
 		},
 
@@ -45,12 +82,90 @@ func TestUpdater_Build(t *testing.T) {
 				Set(Assign("FirstName", "newA"), Assign("LastName", "newB")).
 				Where(C("Id").Eq(1)),
 			wantQuery: &Query{
-				SQL:  "UPDATE `test_model` SET `first_name` = ?,`last_name` = ? WHERE `id` = ?;",
-				Args: []any{"newA", "newB", 1},
+				SQL:        "UPDATE `test_model` SET `first_name` = ?,`last_name` = ? WHERE `id` = ?;",
+				Args:       []any{"newA", "newB", 1},
+				ArgColumns: []string{"first_name", "last_name", "id"},
+			},
+		},
+		{
+			name: "serializer",
+			u: NewUpdater[SerializerModel](db).
+				Set(Assign("Attrs", map[string]string{"a": "b"}), Assign("Tags", nil)),
+			wantQuery: &Query{
+				SQL:        "UPDATE `serializer_model` SET `attrs` = ?,`tags` = ?;",
+				Args:       []any{[]byte(`{"a":"b"}`), nil},
+				ArgColumns: []string{"attrs", "tags"},
+			},
+		},
+		{
+			name: "soft delete",
+			u: NewUpdater[SoftDeleteModel](db).
+				Set(Assign("FirstName", "newA")).
+				Where(C("Id").Eq(1)),
+			wantQuery: &Query{
+				SQL:        "UPDATE `soft_delete_model` SET `first_name` = ? WHERE (`id` = ?) AND (`deleted_at` IS NULL);",
+				Args:       []any{"newA", 1},
+				ArgColumns: []string{"first_name", "id"},
 			},
 		},
+		{
+			name: "soft delete unscoped",
+			u: NewUpdater[SoftDeleteModel](db).
+				Set(Assign("FirstName", "newA")).
+				Where(C("Id").Eq(1)).Unscoped(),
+			wantQuery: &Query{
+				SQL:        "UPDATE `soft_delete_model` SET `first_name` = ? WHERE `id` = ?;",
+				Args:       []any{"newA", 1},
+				ArgColumns: []string{"first_name", "id"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.u.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, q)
+		})
 	}
+}
 
+func TestUpdater_AutoTime(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	db, err := OpenDB(nil, DBWithDialect(DialectMySQL), DBWithClock(func() time.Time {
+		return now
+	}))
+	require.NoError(t, err)
+	testCases := []struct {
+		name      string
+		u         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "auto update time",
+			u: NewUpdater[AutoTimeModel](db).
+				Set(Assign("Id", 2)).Where(C("Id").Eq(1)),
+			wantQuery: &Query{
+				SQL:        "UPDATE `auto_time_model` SET `id` = ?,`updated_at` = ?,`updated_null` = ? WHERE `id` = ?;",
+				Args:       []any{2, now.Unix(), sql.NullTime{Time: now, Valid: true}, 1},
+				ArgColumns: []string{"id", "updated_at", "updated_null", "id"},
+			},
+		},
+		{
+			name: "explicitly assigned",
+			u: NewUpdater[AutoTimeModel](db).
+				Set(Assign("UpdatedAt", 10)),
+			wantQuery: &Query{
+				SQL:        "UPDATE `auto_time_model` SET `updated_at` = ?,`updated_null` = ?;",
+				Args:       []any{10, sql.NullTime{Time: now, Valid: true}},
+				ArgColumns: []string{"updated_at", "updated_null"},
+			},
+		},
+	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			q, err := tc.u.Build()
@@ -62,3 +177,50 @@ func TestUpdater_Build(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdater_Clone(t *testing.T) {
+	db := memoryDB(t)
+	base := NewUpdater[TestModel](db).Set(Assign("Age", 18))
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			q, err := base.Clone().Set(Assign("FirstName", "Tom")).Where(C("Id").Eq(i)).Build()
+			assert.NoError(t, err)
+			assert.Equal(t, &Query{
+				SQL:        "UPDATE `test_model` SET `age` = ?,`first_name` = ? WHERE `id` = ?;",
+				Args:       []any{18, "Tom", i},
+				ArgColumns: []string{"age", "first_name", "id"},
+			}, q)
+		}(i)
+	}
+	wg.Wait()
+	q, err := base.Build()
+	require.NoError(t, err)
+	assert.Equal(t, &Query{
+		SQL:        "UPDATE `test_model` SET `age` = ?;",
+		Args:       []any{18},
+		ArgColumns: []string{"age"},
+	}, q)
+}
+
+func TestUpdater_Expression_SQLite(t *testing.T) {
+	db, err := Open("sqlite3", "file:"+t.Name()+"?mode=memory&cache=shared", DBWithDialect(DialectSQLite))
+	require.NoError(t, err)
+	ctx := context.Background()
+	err = RawQuery[TestModel](db, "CREATE TABLE test_model (id INTEGER PRIMARY KEY, first_name TEXT, age INTEGER, last_name TEXT)").Exec(ctx).Err()
+	require.NoError(t, err)
+	err = NewInserter[TestModel](db).Values(&TestModel{Id: 1, FirstName: "Tom", Age: 10}).Exec(ctx).Err()
+	require.NoError(t, err)
+
+	err = NewUpdater[TestModel](db).
+		Set(Assign("Age", C("Age").Mul(2).Add(1)), Assign("LastName", Concat(Lower(C("FirstName")), "!"))).
+		Where(C("Id").Eq(1)).Exec(ctx).Err()
+	require.NoError(t, err)
+
+	res, err := NewSelector[TestModel](db).Where(C("Age").Sub(1).Eq(20)).Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int8(21), res.Age)
+	assert.Equal(t, &sql.NullString{String: "tom!", Valid: true}, res.LastName)
+}
@@ -0,0 +1,108 @@
+package go_orm
+
+import "strconv"
+
+// WindowSpec 窗口定义，例如
+// Window().PartitionBy(C("FirstName")).OrderBy(Desc("Age")).Rows(UnboundedPreceding(), CurrentRow())
+type WindowSpec struct {
+	partitionBys []Expression
+	orderBys     []OrderBy
+	frame        string
+}
+
+func Window() WindowSpec {
+	return WindowSpec{}
+}
+
+func (w WindowSpec) PartitionBy(exprs ...Expression) WindowSpec {
+	w.partitionBys = exprs
+	return w
+}
+
+func (w WindowSpec) OrderBy(orderBys ...OrderBy) WindowSpec {
+	w.orderBys = orderBys
+	return w
+}
+
+// Rows 指定窗口的范围 ROWS BETWEEN start AND end
+func (w WindowSpec) Rows(start, end FrameBound) WindowSpec {
+	w.frame = "ROWS BETWEEN " + string(start) + " AND " + string(end)
+	return w
+}
+
+// FrameBound 窗口范围的边界
+type FrameBound string
+
+func UnboundedPreceding() FrameBound {
+	return "UNBOUNDED PRECEDING"
+}
+
+func UnboundedFollowing() FrameBound {
+	return "UNBOUNDED FOLLOWING"
+}
+
+func CurrentRow() FrameBound {
+	return "CURRENT ROW"
+}
+
+// Preceding 当前行之前的 n 行
+func Preceding(n uint) FrameBound {
+	return FrameBound(strconv.FormatUint(uint64(n), 10) + " PRECEDING")
+}
+
+// Following 当前行之后的 n 行
+func Following(n uint) FrameBound {
+	return FrameBound(strconv.FormatUint(uint64(n), 10) + " FOLLOWING")
+}
+
+// WindowExpr 窗口函数，由 FuncExpr 或者 Aggregate 调用 Over 得到
+type WindowExpr struct {
+	fn     Expression
+	window WindowSpec
+	alias  string
+}
+
+func (WindowExpr) expr()       {}
+func (WindowExpr) selectable() {}
+
+// As 指定别名，别名是结果结构体的字段名时使用对应的列名，这样结果可以直接映射回结构体
+func (w WindowExpr) As(alias string) WindowExpr {
+	w.alias = alias
+	return w
+}
+
+func RowNumber() FuncExpr {
+	return Fn("ROW_NUMBER")
+}
+
+func Rank() FuncExpr {
+	return Fn("RANK")
+}
+
+func DenseRank() FuncExpr {
+	return Fn("DENSE_RANK")
+}
+
+// Lag 取窗口中之前的行，col 是字符串时表示字段名，args 依次是偏移量和默认值，都可以省略
+func Lag(col any, args ...any) FuncExpr {
+	return Fn("LAG", append([]any{columnOf(col)}, args...)...)
+}
+
+// Lead 取窗口中之后的行，col 是字符串时表示字段名，args 依次是偏移量和默认值，都可以省略
+func Lead(col any, args ...any) FuncExpr {
+	return Fn("LEAD", append([]any{columnOf(col)}, args...)...)
+}
+
+func (f FuncExpr) Over(w WindowSpec) WindowExpr {
+	return WindowExpr{
+		fn:     f,
+		window: w,
+	}
+}
+
+func (a Aggregate) Over(w WindowSpec) WindowExpr {
+	return WindowExpr{
+		fn:     a,
+		window: w,
+	}
+}